@@ -34,9 +34,15 @@ func (p *Program) String() string {
 	return out.String()
 }
 
-// 标识符
+// 标识符,Binding由resolver填写,求值器跳过声明所在作用域内层的作用域,为nil时从当前作用域逐层查找
 type Identifier struct {
-	Token token.Token
+	Token   token.Token
+	Binding *Binding
+}
+
+// 标识符引用的声明所在的作用域:Depth为向外的层数,求值器从该层开始按名字查找
+type Binding struct {
+	Depth int
 }
 
 func (i *Identifier) String() string {
@@ -74,6 +80,7 @@ func (sl *StrLiteral) String() string {
 
 // 数组字面量
 type ArrLiteral struct {
+	Token    token.Token //左中括号
 	Elements []Expression
 }
 
@@ -91,6 +98,7 @@ func (al *ArrLiteral) String() string {
 
 // 映射字面量
 type MapLiteral struct {
+	Token token.Token //左大括号
 	Pairs map[Expression]Expression
 }
 
@@ -107,16 +115,28 @@ func (ml *MapLiteral) String() string {
 }
 
 // 实现了String()方法,隐式实现了Statement接口
+// let或const语句,Const为true时绑定的名字不能在同一作用域中重新绑定
+// Type为类型注解,没有时为nil
 type LetStatement struct {
+	Token token.Token //let或const
 	Name  *Identifier
+	Type  TypeExpr
 	Value Expression
+	Const bool
 }
 
 func (ls *LetStatement) String() string {
 	var out bytes.Buffer
-	out.WriteString("let")
+	if ls.Const {
+		out.WriteString("const")
+	} else {
+		out.WriteString("let")
+	}
 	out.WriteString(" ")
 	out.WriteString(ls.Name.String())
+	if ls.Type != nil {
+		out.WriteString(": " + ls.Type.String())
+	}
 	out.WriteString(" = ")
 	out.WriteString(ls.Value.String())
 	out.WriteString(";")
@@ -124,8 +144,30 @@ func (ls *LetStatement) String() string {
 	return out.String()
 }
 
+// 导入语句,Alias为空时用路径的最后一段作为名字
+type ImportStatement struct {
+	Token token.Token
+	Path  *StrLiteral
+	Alias *Identifier
+}
+
+func (is *ImportStatement) String() string {
+	var out bytes.Buffer
+	out.WriteString("import")
+	out.WriteString(" ")
+	out.WriteString(`"` + is.Path.String() + `"`)
+	if is.Alias != nil {
+		out.WriteString(" as ")
+		out.WriteString(is.Alias.String())
+	}
+	out.WriteString(";")
+	out.WriteString("\n")
+	return out.String()
+}
+
 // 返回语句
 type ReturnStatement struct {
+	Token       token.Token
 	ReturnValue Expression
 }
 
@@ -139,6 +181,21 @@ func (rs *ReturnStatement) String() string {
 	return out.String()
 }
 
+// throw语句,抛出任意值
+type ThrowStatement struct {
+	Token token.Token
+	Value Expression
+}
+
+func (ts *ThrowStatement) String() string {
+	var out bytes.Buffer
+	out.WriteString("throw ")
+	out.WriteString(ts.Value.String())
+	out.WriteString(";")
+	out.WriteString("\n")
+	return out.String()
+}
+
 type ExpressionStatement struct {
 	Expr Expression
 }
@@ -181,6 +238,7 @@ func (ie *InfixExpression) String() string {
 
 // if表达式
 type IfExpression struct {
+	Token       token.Token
 	Condition   Expression
 	Consequence *BlockStatement
 	Alternative *BlockStatement
@@ -202,9 +260,38 @@ func (ie *IfExpression) String() string {
 	return out.String()
 }
 
+// try表达式,Catch和Finally至少有一个,Param为nil时不绑定捕获的错误
+type TryExpression struct {
+	Token   token.Token
+	Body    *BlockStatement
+	Param   *Identifier
+	Catch   *BlockStatement
+	Finally *BlockStatement
+}
+
+func (te *TryExpression) String() string {
+	var out bytes.Buffer
+	out.WriteString("try ")
+	out.WriteString(te.Body.String())
+	if te.Catch != nil {
+		out.WriteString(" catch ")
+		if te.Param != nil {
+			out.WriteString("(" + te.Param.String() + ") ")
+		}
+		out.WriteString(te.Catch.String())
+	}
+	if te.Finally != nil {
+		out.WriteString(" finally ")
+		out.WriteString(te.Finally.String())
+	}
+	return out.String()
+}
+
 // 语句块
 type BlockStatement struct {
+	Token      token.Token //左大括号
 	Statements []Statement
+	Rbrace     token.Token //右大括号
 }
 
 func (bs *BlockStatement) String() string {
@@ -219,28 +306,111 @@ func (bs *BlockStatement) String() string {
 }
 
 // 函数字面量
+// Defaults和ParamTypes与Parameters一一对应,没有默认值或类型注解的参数为nil,Rest为...rest参数
+// RestType是rest参数的类型,应为数组类型,ReturnType是返回值的类型
 type FunctionLiteral struct {
+	Token      token.Token //fn
 	Parameters []*Identifier
+	ParamTypes []TypeExpr
+	Defaults   []Expression
+	Rest       *Identifier
+	RestType   TypeExpr
+	ReturnType TypeExpr
 	Body       *BlockStatement
 }
 
 func (fl *FunctionLiteral) String() string {
 	var out bytes.Buffer
-	var params []string
-	for _, param := range fl.Parameters {
-		params = append(params, param.String())
-	}
 	out.WriteString("fn")
-	out.WriteString("(")
-	out.WriteString(strings.Join(params, ", "))
-	out.WriteString(")")
+	out.WriteString(fl.Signature())
 	out.WriteString(" ")
 	out.WriteString(fl.Body.String())
 	return out.String()
 }
 
-// 函数调用表达式
+// 带类型注解的参数列表和返回类型,如(x: int, y = 10) -> int
+func (fl *FunctionLiteral) Signature() string {
+	var out []string
+	for i, param := range fl.Parameters {
+		s := param.String()
+		if i < len(fl.ParamTypes) && fl.ParamTypes[i] != nil {
+			s += ": " + fl.ParamTypes[i].String()
+		}
+		if i < len(fl.Defaults) && fl.Defaults[i] != nil {
+			s += " = " + fl.Defaults[i].String()
+		}
+		out = append(out, s)
+	}
+	if fl.Rest != nil {
+		s := "..." + fl.Rest.String()
+		if fl.RestType != nil {
+			s += ": " + fl.RestType.String()
+		}
+		out = append(out, s)
+	}
+	sig := "(" + strings.Join(out, ", ") + ")"
+	if fl.ReturnType != nil {
+		sig += " -> " + fl.ReturnType.String()
+	}
+	return sig
+}
+
+// 具名函数声明fn name(params) { },顶层的声明在求值其他语句之前绑定
+type FunctionStatement struct {
+	Name     *Identifier
+	Function *FunctionLiteral
+}
+
+func (fs *FunctionStatement) String() string {
+	var out bytes.Buffer
+	out.WriteString("fn ")
+	out.WriteString(fs.Name.String())
+	out.WriteString(fs.Function.Signature())
+	out.WriteString(" ")
+	out.WriteString(fs.Function.Body.String())
+	out.WriteString("\n")
+	return out.String()
+}
+
+// 参数列表的字符串形式,如x, y = 10, ...rest
+func ParametersString(params []*Identifier, defaults []Expression, rest *Identifier) string {
+	var out []string
+	for i, param := range params {
+		if i < len(defaults) && defaults[i] != nil {
+			out = append(out, param.String()+" = "+defaults[i].String())
+		} else {
+			out = append(out, param.String())
+		}
+	}
+	if rest != nil {
+		out = append(out, "..."+rest.String())
+	}
+	return strings.Join(out, ", ")
+}
+
+// 调用时展开数组作为多个实参,如f(...arr)
+type SpreadExpression struct {
+	Token token.Token
+	Value Expression
+}
+
+func (se *SpreadExpression) String() string {
+	return "..." + se.Value.String()
+}
+
+// 按参数名传递的实参,如f(y: 2)
+type NamedArgument struct {
+	Name  *Identifier
+	Value Expression
+}
+
+func (na *NamedArgument) String() string {
+	return na.Name.String() + ": " + na.Value.String()
+}
+
+// 函数调用表达式,Arguments中可以有SpreadExpression和NamedArgument,NamedArgument都在最后
 type CallExpression struct {
+	Token     token.Token //左小括号
 	Function  Expression
 	Arguments []Expression
 }
@@ -260,6 +430,7 @@ func (ce *CallExpression) String() string {
 
 // 索引表达式
 type IndexExpression struct {
+	Token token.Token //左中括号
 	Left  Expression
 	Index Expression
 }
@@ -274,3 +445,175 @@ func (ie *IndexExpression) String() string {
 	out.WriteString(")")
 	return out.String()
 }
+
+// 成员访问表达式
+type SelectorExpression struct {
+	Token    token.Token
+	Left     Expression
+	Selector *Identifier
+}
+
+func (se *SelectorExpression) String() string {
+	var out bytes.Buffer
+	out.WriteString("(")
+	out.WriteString(se.Left.String())
+	out.WriteString(".")
+	out.WriteString(se.Selector.String())
+	out.WriteString(")")
+	return out.String()
+}
+
+// 结构体声明
+type StructStatement struct {
+	Token  token.Token
+	Name   *Identifier
+	Fields []*Identifier
+}
+
+func (ss *StructStatement) String() string {
+	var out bytes.Buffer
+	var fields []string
+	for _, f := range ss.Fields {
+		fields = append(fields, f.String())
+	}
+	out.WriteString("struct ")
+	out.WriteString(ss.Name.String())
+	out.WriteString(" { ")
+	out.WriteString(strings.Join(fields, ", "))
+	out.WriteString(" }")
+	out.WriteString("\n")
+	return out.String()
+}
+
+// 结构体字面量,Type求值为结构体类型时构造新值,为结构体值时复制并更新字段
+type StructLiteral struct {
+	Token  token.Token
+	Type   Expression
+	Fields []*Identifier
+	Values []Expression
+}
+
+func (sl *StructLiteral) String() string {
+	var out bytes.Buffer
+	var fields []string
+	for i, f := range sl.Fields {
+		fields = append(fields, f.String()+": "+sl.Values[i].String())
+	}
+	out.WriteString(sl.Type.String())
+	out.WriteString("{")
+	out.WriteString(strings.Join(fields, ", "))
+	out.WriteString("}")
+	return out.String()
+}
+
+// match表达式,依次尝试各分支,第一个匹配的分支的Body作为结果
+type MatchExpression struct {
+	Token  token.Token
+	Value  Expression
+	Arms   []*MatchArm
+	Rbrace token.Token //右大括号
+}
+
+func (me *MatchExpression) String() string {
+	var out bytes.Buffer
+	var arms []string
+	for _, arm := range me.Arms {
+		arms = append(arms, arm.String())
+	}
+	out.WriteString("match (")
+	out.WriteString(me.Value.String())
+	out.WriteString(") { ")
+	out.WriteString(strings.Join(arms, ", "))
+	out.WriteString(" }")
+	return out.String()
+}
+
+// match的一个分支,Guard为nil时没有if条件
+type MatchArm struct {
+	Token   token.Token //模式的第一个token
+	Pattern Pattern
+	Guard   Expression
+	Body    Expression
+}
+
+func (ma *MatchArm) String() string {
+	var out bytes.Buffer
+	out.WriteString(ma.Pattern.String())
+	if ma.Guard != nil {
+		out.WriteString(" if ")
+		out.WriteString(ma.Guard.String())
+	}
+	out.WriteString(" => ")
+	out.WriteString(ma.Body.String())
+	return out.String()
+}
+
+// match分支中的模式
+type Pattern interface {
+	Node
+}
+
+// 字面量模式,值与字面量相等时匹配
+type LiteralPattern struct {
+	Value Expression
+}
+
+func (lp *LiteralPattern) String() string {
+	return lp.Value.String()
+}
+
+// 通配符_,匹配任何值且不绑定
+type WildcardPattern struct {
+	Token token.Token
+}
+
+func (wp *WildcardPattern) String() string {
+	return "_"
+}
+
+// 绑定模式,匹配任何值并绑定到Name
+type BindingPattern struct {
+	Name *Identifier
+}
+
+func (bp *BindingPattern) String() string {
+	return bp.Name.String()
+}
+
+// 数组模式,HasRest时匹配长度不小于Elements的数组,剩余元素绑定到Rest(可以为nil)
+type ArrayPattern struct {
+	Token    token.Token
+	Elements []Pattern
+	HasRest  bool
+	Rest     *Identifier
+}
+
+func (ap *ArrayPattern) String() string {
+	var elements []string
+	for _, e := range ap.Elements {
+		elements = append(elements, e.String())
+	}
+	if ap.HasRest {
+		rest := "..."
+		if ap.Rest != nil {
+			rest += ap.Rest.String()
+		}
+		elements = append(elements, rest)
+	}
+	return "[" + strings.Join(elements, ", ") + "]"
+}
+
+// 映射模式,映射中含有所有的键且对应的值都匹配时匹配,多余的键不影响
+type MapPattern struct {
+	Token  token.Token
+	Keys   []Expression
+	Values []Pattern
+}
+
+func (mp *MapPattern) String() string {
+	var pairs []string
+	for i, key := range mp.Keys {
+		pairs = append(pairs, key.String()+": "+mp.Values[i].String())
+	}
+	return "{" + strings.Join(pairs, ", ") + "}"
+}
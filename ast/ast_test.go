@@ -144,3 +144,57 @@ func TestIfExpression_String(t *testing.T) {
 	fmt.Println(program.String())
 	fmt.Printf("%#v\n", if_stmt)
 }
+
+func TestDump(t *testing.T) {
+	program := &Program{
+		Statements: []Statement{
+			&LetStatement{
+				Name: &Identifier{Token: token.Token{Type: token.IDENT, Literal: "a"}},
+				Value: &ArrLiteral{Elements: []Expression{
+					&IntLiteral{Token: token.Token{Type: token.INT, Literal: "1"}, Value: 1},
+				}},
+			},
+		},
+	}
+	expected := `Program
+  Statements:
+    - LetStatement
+      Name: Identifier "a"
+      Value: ArrLiteral
+        Elements:
+          - IntLiteral "1"
+            Value: 1
+`
+	if Dump(program) != expected {
+		t.Errorf("Dump() wrong. got=%q", Dump(program))
+	}
+}
+
+func TestInspect(t *testing.T) {
+	a := &Identifier{Token: token.Token{Type: token.IDENT, Literal: "a", Line: 1, Column: 5}}
+	one := &IntLiteral{Token: token.Token{Type: token.INT, Literal: "1", Line: 1, Column: 9}, Value: 1}
+	sum := &InfixExpression{Token: token.Token{Type: token.PLUS, Literal: "+", Line: 1, Column: 11}, Left: one, Right: a}
+	program := &Program{Statements: []Statement{
+		&LetStatement{Token: token.Token{Type: token.LET, Literal: "let", Line: 1, Column: 1}, Name: a, Value: sum},
+		(*ExpressionStatement)(nil),
+	}}
+	var visited []string
+	Inspect(program, func(n Node) bool {
+		visited = append(visited, fmt.Sprintf("%T", n))
+		_, isInfix := n.(*InfixExpression)
+		return !isInfix
+	})
+	expected := "*ast.Program *ast.LetStatement *ast.Identifier *ast.InfixExpression"
+	if got := fmt.Sprint(visited); got != "["+expected+"]" {
+		t.Errorf("Inspect() wrong. got=%s", got)
+	}
+	if pos := Pos(sum); pos.Line != 1 || pos.Column != 9 {
+		t.Errorf("Pos() of infix should be its left operand. got=%d:%d", pos.Line, pos.Column)
+	}
+	if pos := Pos(program); pos.Column != 1 {
+		t.Errorf("Pos() of program should be its first statement. got=%d:%d", pos.Line, pos.Column)
+	}
+	if end := End(program); end.Line != 1 || end.Column != 9 {
+		t.Errorf("End() should be the last token in the program. got=%d:%d", end.Line, end.Column)
+	}
+}
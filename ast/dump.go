@@ -0,0 +1,82 @@
+package ast
+
+import (
+	"bytes"
+	"fmt"
+	"my-interpreter/token"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+var tokenType = reflect.TypeOf(token.Token{})
+
+// 以缩进的树形式输出语法树,每个节点一行,子节点缩进一级
+func Dump(node Node) string {
+	var out bytes.Buffer
+	dumpValue(&out, reflect.ValueOf(node), 0)
+	return out.String()
+}
+
+func dumpValue(out *bytes.Buffer, v reflect.Value, depth int) {
+	for v.Kind() == reflect.Interface || v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			out.WriteString("nil\n")
+			return
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		fmt.Fprintf(out, "%v\n", v.Interface())
+		return
+	}
+	out.WriteString(v.Type().Name())
+	if tok := v.FieldByName("Token"); tok.IsValid() && tok.Type() == tokenType {
+		//手工构造的节点可能没有token
+		if literal := tok.Interface().(token.Token).Literal; literal != "" {
+			fmt.Fprintf(out, " %q", literal)
+		}
+	}
+	out.WriteString("\n")
+	indent := strings.Repeat("  ", depth+1)
+	for i := 0; i < v.NumField(); i++ {
+		field, value := v.Type().Field(i), v.Field(i)
+		if !field.IsExported() || field.Type == tokenType || isEmpty(value) {
+			continue
+		}
+		switch value.Kind() {
+		case reflect.Slice:
+			fmt.Fprintf(out, "%s%s:\n", indent, field.Name)
+			for j := 0; j < value.Len(); j++ {
+				out.WriteString(indent + "  - ")
+				dumpValue(out, value.Index(j), depth+2)
+			}
+		case reflect.Map:
+			fmt.Fprintf(out, "%s%s:\n", indent, field.Name)
+			//map的遍历顺序不固定,按键的字符串形式排序
+			keys := value.MapKeys()
+			sort.Slice(keys, func(a, b int) bool {
+				return fmt.Sprint(keys[a].Interface()) < fmt.Sprint(keys[b].Interface())
+			})
+			for _, key := range keys {
+				out.WriteString(indent + "  key: ")
+				dumpValue(out, key, depth+2)
+				out.WriteString(indent + "  value: ")
+				dumpValue(out, value.MapIndex(key), depth+2)
+			}
+		default:
+			fmt.Fprintf(out, "%s%s: ", indent, field.Name)
+			dumpValue(out, value, depth+1)
+		}
+	}
+}
+
+func isEmpty(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Pointer, reflect.Interface, reflect.Slice, reflect.Map:
+		return v.IsNil() || (v.Kind() == reflect.Slice || v.Kind() == reflect.Map) && v.Len() == 0
+	}
+	return false
+}
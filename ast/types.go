@@ -0,0 +1,69 @@
+package ast
+
+import (
+	"bytes"
+	"my-interpreter/token"
+	"strings"
+)
+
+// 类型注解,只供typecheck使用,求值时忽略
+type TypeExpr interface {
+	Node
+	typeExpr()
+}
+
+// 具名类型,如int,string,any,表示任意函数的fn或结构体的名字
+type NamedType struct {
+	Token token.Token
+}
+
+func (nt *NamedType) typeExpr() {}
+func (nt *NamedType) String() string {
+	return nt.Token.Literal
+}
+
+// 数组类型[T]
+type ArrayType struct {
+	Token token.Token //左中括号
+	Elem  TypeExpr
+}
+
+func (at *ArrayType) typeExpr() {}
+func (at *ArrayType) String() string {
+	return "[" + at.Elem.String() + "]"
+}
+
+// 映射类型{K: V}
+type MapType struct {
+	Token token.Token //左大括号
+	Key   TypeExpr
+	Value TypeExpr
+}
+
+func (mt *MapType) typeExpr() {}
+func (mt *MapType) String() string {
+	return "{" + mt.Key.String() + ": " + mt.Value.String() + "}"
+}
+
+// 函数类型fn(T1, T2) -> R,Return为nil时返回值的类型不限
+type FunctionType struct {
+	Token  token.Token //fn
+	Params []TypeExpr
+	Return TypeExpr
+}
+
+func (ft *FunctionType) typeExpr() {}
+func (ft *FunctionType) String() string {
+	var out bytes.Buffer
+	var params []string
+	for _, p := range ft.Params {
+		params = append(params, p.String())
+	}
+	out.WriteString("fn(")
+	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(")")
+	if ft.Return != nil {
+		out.WriteString(" -> " + ft.Return.String())
+	}
+	return out.String()
+}
@@ -0,0 +1,259 @@
+package ast
+
+import (
+	"my-interpreter/token"
+	"reflect"
+	"sort"
+)
+
+// 按源码顺序深度优先遍历node及其子节点,f返回false时不再进入该节点的子节点
+// 声明中的名字,如let的Name和函数的参数,也作为*Identifier访问
+func Inspect(node Node, f func(Node) bool) {
+	if isNil(node) || !f(node) {
+		return
+	}
+	for _, child := range Children(node) {
+		Inspect(child, f)
+	}
+}
+
+// 直接子节点,按源码顺序排列,不含nil
+func Children(node Node) []Node {
+	var children []Node
+	add := func(nodes ...Node) {
+		for _, n := range nodes {
+			if !isNil(n) {
+				children = append(children, n)
+			}
+		}
+	}
+	switch node := node.(type) {
+	case *Program:
+		for _, s := range node.Statements {
+			add(s)
+		}
+	case *BlockStatement:
+		for _, s := range node.Statements {
+			add(s)
+		}
+	case *LetStatement:
+		add(node.Name, node.Type, node.Value)
+	case *ImportStatement:
+		add(node.Path, node.Alias)
+	case *ReturnStatement:
+		add(node.ReturnValue)
+	case *ThrowStatement:
+		add(node.Value)
+	case *ExpressionStatement:
+		add(node.Expr)
+	case *FunctionStatement:
+		add(node.Name, node.Function)
+	case *StructStatement:
+		add(node.Name)
+		for _, f := range node.Fields {
+			add(f)
+		}
+	case *ArrLiteral:
+		for _, e := range node.Elements {
+			add(e)
+		}
+	case *MapLiteral:
+		for _, key := range SortedKeys(node) {
+			add(key, node.Pairs[key])
+		}
+	case *PrefixExpression:
+		add(node.Right)
+	case *InfixExpression:
+		add(node.Left, node.Right)
+	case *IfExpression:
+		add(node.Condition, node.Consequence, node.Alternative)
+	case *TryExpression:
+		add(node.Body, node.Param, node.Catch, node.Finally)
+	case *FunctionLiteral:
+		for i, param := range node.Parameters {
+			add(param)
+			if i < len(node.ParamTypes) {
+				add(node.ParamTypes[i])
+			}
+			if i < len(node.Defaults) {
+				add(node.Defaults[i])
+			}
+		}
+		add(node.Rest, node.RestType, node.ReturnType, node.Body)
+	case *SpreadExpression:
+		add(node.Value)
+	case *NamedArgument:
+		add(node.Name, node.Value)
+	case *CallExpression:
+		add(node.Function)
+		for _, arg := range node.Arguments {
+			add(arg)
+		}
+	case *IndexExpression:
+		add(node.Left, node.Index)
+	case *SelectorExpression:
+		add(node.Left, node.Selector)
+	case *StructLiteral:
+		add(node.Type)
+		for i, f := range node.Fields {
+			add(f, node.Values[i])
+		}
+	case *MatchExpression:
+		add(node.Value)
+		for _, arm := range node.Arms {
+			add(arm)
+		}
+	case *MatchArm:
+		add(node.Pattern, node.Guard, node.Body)
+	case *LiteralPattern:
+		add(node.Value)
+	case *BindingPattern:
+		add(node.Name)
+	case *ArrayPattern:
+		for _, e := range node.Elements {
+			add(e)
+		}
+		add(node.Rest)
+	case *MapPattern:
+		for i, key := range node.Keys {
+			add(key, node.Values[i])
+		}
+	case *ArrayType:
+		add(node.Elem)
+	case *MapType:
+		add(node.Key, node.Value)
+	case *FunctionType:
+		for _, param := range node.Params {
+			add(param)
+		}
+		add(node.Return)
+	}
+	return children
+}
+
+// 映射字面量的键,按在源码中的位置排列
+func SortedKeys(m *MapLiteral) []Expression {
+	keys := make([]Expression, 0, len(m.Pairs))
+	for key := range m.Pairs {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := Pos(keys[i]), Pos(keys[j])
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+	return keys
+}
+
+// 节点在源码中开始的token,位置未知时Line为0
+func Pos(node Node) token.Token {
+	switch node := node.(type) {
+	case *Program:
+		if len(node.Statements) > 0 {
+			return Pos(node.Statements[0])
+		}
+	case *Identifier:
+		return node.Token
+	case *IntLiteral:
+		return node.Token
+	case *BoolLiteral:
+		return node.Token
+	case *StrLiteral:
+		return node.Token
+	case *ArrLiteral:
+		return node.Token
+	case *MapLiteral:
+		return node.Token
+	case *LetStatement:
+		return node.Token
+	case *ImportStatement:
+		return node.Token
+	case *ReturnStatement:
+		return node.Token
+	case *ThrowStatement:
+		return node.Token
+	case *ExpressionStatement:
+		return Pos(node.Expr)
+	case *FunctionStatement:
+		return node.Function.Token
+	case *StructStatement:
+		return node.Token
+	case *BlockStatement:
+		return node.Token
+	case *PrefixExpression:
+		return node.Token
+	case *InfixExpression:
+		return Pos(node.Left)
+	case *IfExpression:
+		return node.Token
+	case *TryExpression:
+		return node.Token
+	case *FunctionLiteral:
+		return node.Token
+	case *SpreadExpression:
+		return node.Token
+	case *NamedArgument:
+		return node.Name.Token
+	case *CallExpression:
+		return Pos(node.Function)
+	case *IndexExpression:
+		return Pos(node.Left)
+	case *SelectorExpression:
+		return Pos(node.Left)
+	case *StructLiteral:
+		return Pos(node.Type)
+	case *MatchExpression:
+		return node.Token
+	case *MatchArm:
+		return node.Token
+	case *LiteralPattern:
+		return Pos(node.Value)
+	case *WildcardPattern:
+		return node.Token
+	case *BindingPattern:
+		return node.Name.Token
+	case *ArrayPattern:
+		return node.Token
+	case *MapPattern:
+		return node.Token
+	case *NamedType:
+		return node.Token
+	case *ArrayType:
+		return node.Token
+	case *MapType:
+		return node.Token
+	case *FunctionType:
+		return node.Token
+	}
+	return token.Token{}
+}
+
+// 节点中位置最靠后的有记录的token,右小括号和右中括号等没有记录,所以只是近似的结束位置
+func End(node Node) token.Token {
+	end := Pos(node)
+	Inspect(node, func(n Node) bool {
+		tok := Pos(n)
+		switch n := n.(type) {
+		case *BlockStatement:
+			tok = n.Rbrace
+		case *MatchExpression:
+			tok = n.Rbrace
+		}
+		if tok.Line > end.Line || tok.Line == end.Line && tok.Column > end.Column {
+			end = tok
+		}
+		return true
+	})
+	return end
+}
+
+// 语法错误时语句列表中可能有值为nil的指针
+func isNil(node Node) bool {
+	if node == nil {
+		return true
+	}
+	v := reflect.ValueOf(node)
+	return v.Kind() == reflect.Pointer && v.IsNil()
+}
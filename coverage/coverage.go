@@ -0,0 +1,164 @@
+// coverage包在求值钩子上记录脚本中执行过的语句和if的分支,输出文本摘要,LCOV和带注释的HTML报告
+// run和test命令的-cover,-coverprofile和-coverhtml参数使用它
+package coverage
+
+import (
+	"my-interpreter/ast"
+	"my-interpreter/lexer"
+	"my-interpreter/object"
+	"my-interpreter/parser"
+	"os"
+	"sort"
+)
+
+// 语句或if表达式在源码中的位置
+type Pos struct {
+	Line   int
+	Column int
+}
+
+// 语句及其执行次数
+type Statement struct {
+	Pos
+	Count int64
+}
+
+// if表达式的两个分支各被选择的次数,没有else时Alternative是跳过的次数
+type Branch struct {
+	Pos
+	Consequence int64
+	Alternative int64
+}
+
+// 一个文件的覆盖情况,Files返回时语句和分支按位置排列
+type File struct {
+	Name       string
+	Statements []*Statement
+	Branches   []*Branch
+
+	statements map[Pos]*Statement
+	branches   map[Pos]*Branch
+}
+
+type Coverage struct {
+	files map[string]*File
+	order []string //按第一次遇到的顺序
+}
+
+func New() *Coverage {
+	return &Coverage{files: map[string]*File{}}
+}
+
+// 登记program中的所有语句和分支,没有执行过的也出现在报告中
+// 求值时遇到没有登记的文件会读取并解析它,所以导入的模块不需要登记
+func (c *Coverage) Add(file string, program *ast.Program) *File {
+	f := &File{Name: file, statements: map[Pos]*Statement{}, branches: map[Pos]*Branch{}}
+	if old, ok := c.files[file]; ok {
+		f = old
+	} else {
+		c.files[file] = f
+		c.order = append(c.order, file)
+	}
+	if program == nil {
+		return f
+	}
+	ast.Inspect(program, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.Program:
+			f.addStatements(n.Statements)
+		case *ast.BlockStatement:
+			f.addStatements(n.Statements)
+		case *ast.IfExpression:
+			f.branch(n)
+		}
+		return true
+	})
+	return f
+}
+
+// 函数声明本身不算语句,顶层的函数声明被提前求值时也不经过钩子
+func (f *File) addStatements(statements []ast.Statement) {
+	for _, stmt := range statements {
+		if _, ok := stmt.(*ast.FunctionStatement); !ok {
+			f.statement(stmt)
+		}
+	}
+}
+
+func (f *File) statement(stmt ast.Statement) *Statement {
+	tok := ast.Pos(stmt)
+	pos := Pos{tok.Line, tok.Column}
+	s, ok := f.statements[pos]
+	if !ok {
+		s = &Statement{Pos: pos}
+		f.statements[pos] = s
+		f.Statements = append(f.Statements, s)
+	}
+	return s
+}
+
+func (f *File) branch(node *ast.IfExpression) *Branch {
+	pos := Pos{node.Token.Line, node.Token.Column}
+	b, ok := f.branches[pos]
+	if !ok {
+		b = &Branch{Pos: pos}
+		f.branches[pos] = b
+		f.Branches = append(f.Branches, b)
+	}
+	return b
+}
+
+func (f *File) sort() {
+	sort.Slice(f.Statements, func(i, j int) bool { return f.Statements[i].Pos.before(f.Statements[j].Pos) })
+	sort.Slice(f.Branches, func(i, j int) bool { return f.Branches[i].Pos.before(f.Branches[j].Pos) })
+}
+
+func (p Pos) before(q Pos) bool {
+	return p.Line < q.Line || p.Line == q.Line && p.Column < q.Column
+}
+
+// 已登记的文件,按第一次遇到的顺序
+func (c *Coverage) Files() []*File {
+	files := make([]*File, 0, len(c.order))
+	for _, name := range c.order {
+		f := c.files[name]
+		f.sort()
+		files = append(files, f)
+	}
+	return files
+}
+
+// 找到求值中遇到的文件,没有登记时读取并登记
+func (c *Coverage) file(name string) *File {
+	if f, ok := c.files[name]; ok {
+		return f
+	}
+	var program *ast.Program
+	if data, err := os.ReadFile(name); err == nil {
+		p := parser.NewParser(lexer.NewLexer(string(data)))
+		if prog := p.ParseProgram(); len(p.Errors()) == 0 {
+			program = prog
+		}
+	}
+	return c.Add(name, program)
+}
+
+// Statement实现evaluator.Hook,语句的执行次数加一
+func (c *Coverage) Statement(file string, stmt ast.Statement, env *object.Environment) *object.Error {
+	c.file(file).statement(stmt).Count++
+	return nil
+}
+
+func (c *Coverage) Call(fn *object.Function, args []object.Object, env *object.Environment) {}
+
+func (c *Coverage) Return(fn *object.Function, result object.Object) {}
+
+// Branch实现evaluator.BranchHook,记录选择的分支
+func (c *Coverage) Branch(file string, node *ast.IfExpression, consequence bool) {
+	b := c.file(file).branch(node)
+	if consequence {
+		b.Consequence++
+	} else {
+		b.Alternative++
+	}
+}
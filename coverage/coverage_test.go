@@ -0,0 +1,153 @@
+package coverage
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	evaluator "my-interpreter/evaluator"
+	"my-interpreter/lexer"
+	"my-interpreter/object"
+	"my-interpreter/parser"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const src = `let abs = fn(x) {
+	if (x < 0) { return -x; }
+	x
+};
+fn never() {
+	prints("never")
+}
+abs(3);
+let y = if (abs(-2) > 1) { 1 } else { 2 };
+y`
+
+// 把files写到临时目录中,在覆盖率钩子下求值其中的main.mi
+func run(t *testing.T, files map[string]string) (*Coverage, string) {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	path := filepath.Join(dir, "main.mi")
+	p := parser.NewParser(lexer.NewLexer(files["main.mi"]))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatal(p.Errors())
+	}
+	cov := New()
+	cov.Add(path, program)
+	res := evaluator.EvalContext(context.Background(), program, object.NewEnvironment(), evaluator.Config{File: path, Hook: cov})
+	if errObj, ok := res.(*object.Error); ok {
+		t.Fatal(errObj.Msg)
+	}
+	return cov, path
+}
+
+func statements(f *File) string {
+	var res []string
+	for _, s := range f.Statements {
+		res = append(res, fmt.Sprintf("%d:%d=%d", s.Line, s.Column, s.Count))
+	}
+	return strings.Join(res, " ")
+}
+
+func TestCounts(t *testing.T) {
+	cov, path := run(t, map[string]string{"main.mi": src})
+	files := cov.Files()
+	if len(files) != 1 || files[0].Name != path {
+		t.Fatalf("files = %v", files)
+	}
+	want := "1:1=1 2:2=2 2:15=1 3:2=1 6:2=0 8:1=1 9:1=1 9:28=1 9:39=0 10:1=1"
+	if got := statements(files[0]); got != want {
+		t.Errorf("statements = %s, want %s", got, want)
+	}
+	branches := files[0].Branches
+	if len(branches) != 2 ||
+		*branches[0] != (Branch{Pos{2, 2}, 1, 1}) ||
+		*branches[1] != (Branch{Pos{9, 9}, 1, 0}) {
+		for _, b := range branches {
+			t.Logf("%+v", *b)
+		}
+		t.Error("unexpected branches")
+	}
+	if tot := files[0].Totals(); tot != (Totals{10, 8, 4, 3}) {
+		t.Errorf("totals = %+v", tot)
+	}
+}
+
+func TestImportedModule(t *testing.T) {
+	cov, _ := run(t, map[string]string{
+		"main.mi": "import \"m\";\nm.sign(1)",
+		"m.mi":    "let sign = fn(x) {\n\tif (x < 0) { -1 } else { 1 }\n};\nlet unused = fn() { 0 };",
+	})
+	files := cov.Files()
+	if len(files) != 2 || filepath.Base(files[1].Name) != "m.mi" {
+		t.Fatalf("files = %v", files)
+	}
+	//模块在第一次遇到时解析,没有执行的语句也要登记
+	want := "1:1=1 2:2=1 2:15=0 2:27=1 4:1=1 4:21=0"
+	if got := statements(files[1]); got != want {
+		t.Errorf("statements = %s, want %s", got, want)
+	}
+}
+
+func TestReports(t *testing.T) {
+	cov, path := run(t, map[string]string{"main.mi": src})
+
+	var buf bytes.Buffer
+	if err := cov.WriteSummary(&buf); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 || !strings.HasSuffix(lines[1], "statements 8/10  80.0%  branches 3/4  75.0%") {
+		t.Errorf("summary:\n%s", buf.String())
+	}
+
+	buf.Reset()
+	if err := cov.WriteLCOV(&buf); err != nil {
+		t.Fatal(err)
+	}
+	want := "SF:" + path + `
+BRDA:2,0,0,1
+BRDA:2,0,1,1
+BRDA:9,1,0,1
+BRDA:9,1,1,0
+BRF:4
+BRH:3
+DA:1,1
+DA:2,2
+DA:3,1
+DA:6,0
+DA:8,1
+DA:9,1
+DA:10,1
+LF:7
+LH:6
+end_of_record
+`
+	if buf.String() != want {
+		t.Errorf("lcov:\n%s\nwant:\n%s", buf.String(), want)
+	}
+
+	buf.Reset()
+	if err := cov.WriteHTML(&buf); err != nil {
+		t.Fatal(err)
+	}
+	html := buf.String()
+	for _, s := range []string{
+		`<tr class="covered" title="if at column 2: then 1, else 1"><td class="number">2</td><td class="count">2</td><td class="text">	if (x &lt; 0) { return -x; }</td></tr>`,
+		`<tr class="" title=""><td class="number">5</td><td class="count"></td><td class="text">fn never() {</td></tr>`,
+		`<tr class="uncovered" title=""><td class="number">6</td>`,
+		`<tr class="partial" title="if at column 9: then 1, else 0"><td class="number">9</td>`,
+	} {
+		if !strings.Contains(html, s) {
+			t.Errorf("html does not contain %s", s)
+		}
+	}
+}
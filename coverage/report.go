@@ -0,0 +1,238 @@
+package coverage
+
+import (
+	"bufio"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+// 执行过的和全部的语句数,分支数,每个if有两个分支
+type Totals struct {
+	Statements, StatementsHit int
+	Branches, BranchesHit     int
+}
+
+func (f *File) Totals() Totals {
+	var t Totals
+	for _, s := range f.Statements {
+		t.Statements++
+		if s.Count > 0 {
+			t.StatementsHit++
+		}
+	}
+	for _, b := range f.Branches {
+		t.Branches += 2
+		t.BranchesHit += hit(b.Consequence) + hit(b.Alternative)
+	}
+	return t
+}
+
+func (t *Totals) add(u Totals) {
+	t.Statements += u.Statements
+	t.StatementsHit += u.StatementsHit
+	t.Branches += u.Branches
+	t.BranchesHit += u.BranchesHit
+}
+
+func hit(count int64) int {
+	if count > 0 {
+		return 1
+	}
+	return 0
+}
+
+func percent(hit, total int) string {
+	if total == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.1f%%", float64(hit)*100/float64(total))
+}
+
+// 每个文件一行的文本摘要,最后一行是总计
+func (c *Coverage) WriteSummary(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	var total Totals
+	row := func(name string, t Totals) {
+		fmt.Fprintf(tw, "%s\tstatements %d/%d\t%s\tbranches %d/%d\t%s\n", name,
+			t.StatementsHit, t.Statements, percent(t.StatementsHit, t.Statements),
+			t.BranchesHit, t.Branches, percent(t.BranchesHit, t.Branches))
+	}
+	for _, f := range c.Files() {
+		t := f.Totals()
+		total.add(t)
+		row(f.Name, t)
+	}
+	row("total", total)
+	return tw.Flush()
+}
+
+// 一行源码的覆盖情况,同一行有多个语句时取最大的执行次数
+type line struct {
+	statements int
+	hit        int
+	count      int64
+	branches   []*Branch
+}
+
+func (f *File) lines() map[int]*line {
+	lines := map[int]*line{}
+	get := func(n int) *line {
+		l, ok := lines[n]
+		if !ok {
+			l = &line{}
+			lines[n] = l
+		}
+		return l
+	}
+	for _, s := range f.Statements {
+		l := get(s.Line)
+		l.statements++
+		l.hit += hit(s.Count)
+		l.count = max(l.count, s.Count)
+	}
+	for _, b := range f.Branches {
+		l := get(b.Line)
+		l.branches = append(l.branches, b)
+	}
+	return lines
+}
+
+// 行的状态,用作HTML中的class,不是语句或分支所在的行返回空字符串
+func (l *line) class() string {
+	arms, armsHit := 0, 0
+	for _, b := range l.branches {
+		arms += 2
+		armsHit += hit(b.Consequence) + hit(b.Alternative)
+	}
+	switch {
+	case l.statements+arms == 0:
+		return ""
+	case l.hit+armsHit == 0:
+		return "uncovered"
+	case l.hit == l.statements && armsHit == arms:
+		return "covered"
+	}
+	return "partial"
+}
+
+// 以LCOV的tracefile格式写出,genhtml等工具可以直接读取
+func (c *Coverage) WriteLCOV(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	for _, f := range c.Files() {
+		fmt.Fprintf(bw, "SF:%s\n", f.Name)
+		for i, b := range f.Branches {
+			for arm, count := range []int64{b.Consequence, b.Alternative} {
+				taken := "-"
+				if b.Consequence+b.Alternative > 0 {
+					taken = fmt.Sprint(count)
+				}
+				fmt.Fprintf(bw, "BRDA:%d,%d,%d,%s\n", b.Line, i, arm, taken)
+			}
+		}
+		t := f.Totals()
+		fmt.Fprintf(bw, "BRF:%d\nBRH:%d\n", t.Branches, t.BranchesHit)
+		lines := f.lines()
+		written := map[int]bool{}
+		found, hitLines := 0, 0
+		for _, s := range f.Statements {
+			if written[s.Line] {
+				continue
+			}
+			written[s.Line] = true
+			count := lines[s.Line].count
+			fmt.Fprintf(bw, "DA:%d,%d\n", s.Line, count)
+			found++
+			hitLines += hit(count)
+		}
+		fmt.Fprintf(bw, "LF:%d\nLH:%d\nend_of_record\n", found, hitLines)
+	}
+	return bw.Flush()
+}
+
+type htmlLine struct {
+	Number int
+	Class  string
+	Count  string
+	Title  string
+	Text   string
+}
+
+type htmlFile struct {
+	Name       string
+	Statements string
+	Branches   string
+	Lines      []htmlLine
+	Err        string
+}
+
+var htmlTemplate = template.Must(template.New("coverage").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>coverage</title>
+<style>
+body { font-family: sans-serif; }
+table.summary td, table.summary th { padding: 2px 12px; text-align: left; }
+table.source { border-collapse: collapse; font-family: monospace; white-space: pre; }
+table.source td { padding: 0 8px; }
+td.number, td.count { text-align: right; color: #888; }
+tr.covered td.text { background: #cfc; }
+tr.uncovered td.text { background: #fcc; }
+tr.partial td.text { background: #ffc; }
+</style>
+</head>
+<body>
+<table class="summary">
+<tr><th>file</th><th>statements</th><th>branches</th></tr>
+{{range .}}<tr><td><a href="#{{.Name}}">{{.Name}}</a></td><td>{{.Statements}}</td><td>{{.Branches}}</td></tr>
+{{end}}</table>
+{{range .}}<h2 id="{{.Name}}">{{.Name}}</h2>
+{{if .Err}}<p>{{.Err}}</p>
+{{else}}<table class="source">
+{{range .Lines}}<tr class="{{.Class}}" title="{{.Title}}"><td class="number">{{.Number}}</td><td class="count">{{.Count}}</td><td class="text">{{.Text}}</td></tr>
+{{end}}</table>
+{{end}}{{end}}</body>
+</html>
+`))
+
+// 写出带注释的源码,执行过的行为绿色,没有执行的为红色,部分执行或只走了一个分支的为黄色
+// 源码从磁盘读取,读取失败的文件只有摘要
+func (c *Coverage) WriteHTML(w io.Writer) error {
+	var files []htmlFile
+	for _, f := range c.Files() {
+		t := f.Totals()
+		hf := htmlFile{
+			Name:       f.Name,
+			Statements: fmt.Sprintf("%d/%d %s", t.StatementsHit, t.Statements, percent(t.StatementsHit, t.Statements)),
+			Branches:   fmt.Sprintf("%d/%d %s", t.BranchesHit, t.Branches, percent(t.BranchesHit, t.Branches)),
+		}
+		data, err := os.ReadFile(f.Name)
+		if err != nil {
+			hf.Err = err.Error()
+			files = append(files, hf)
+			continue
+		}
+		lines := f.lines()
+		for i, text := range strings.Split(strings.TrimSuffix(string(data), "\n"), "\n") {
+			hl := htmlLine{Number: i + 1, Text: text}
+			if l, ok := lines[i+1]; ok {
+				hl.Class = l.class()
+				if l.statements > 0 {
+					hl.Count = fmt.Sprint(l.count)
+				}
+				var titles []string
+				for _, b := range l.branches {
+					titles = append(titles, fmt.Sprintf("if at column %d: then %d, else %d", b.Column, b.Consequence, b.Alternative))
+				}
+				hl.Title = strings.Join(titles, "; ")
+			}
+			hf.Lines = append(hf.Lines, hl)
+		}
+		files = append(files, hf)
+	}
+	return htmlTemplate.Execute(w, files)
+}
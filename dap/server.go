@@ -0,0 +1,423 @@
+// dap包实现调试适配器协议,让编辑器通过debugger包调试脚本
+// 只有一个线程,ID为1
+package dap
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"my-interpreter/ast"
+	"my-interpreter/debugger"
+	evaluator "my-interpreter/evaluator"
+	"my-interpreter/lexer"
+	"my-interpreter/object"
+	"my-interpreter/parser"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+const threadID = 1
+
+type server struct {
+	in  *bufio.Reader
+	mu  sync.Mutex //保护out和seq,事件和程序的输出在其它goroutine中发送
+	out io.Writer
+	seq int
+
+	d           *debugger.Debugger
+	lineBase    int //客户端的行号从几开始
+	columnBase  int
+	program     *ast.Program
+	file        string
+	stopOnEntry bool
+	started     bool
+	done        chan struct{} //程序结束后关闭
+	after       func()        //发送回复之后执行,用于回复之后才能发送的事件
+	refs        []any         //variablesReference-1对应的环境或值,程序继续运行时清空
+}
+
+// 在in和out上运行调试适配器,收到disconnect请求或in结束时返回
+// programOutput不为nil时,从中读到的内容作为程序的输出发给客户端
+func Serve(in io.Reader, out io.Writer, programOutput io.Reader) error {
+	s := &server{in: bufio.NewReader(in), out: out, d: debugger.New(), lineBase: 1, columnBase: 1, done: make(chan struct{})}
+	if programOutput != nil {
+		go s.forward(programOutput)
+	}
+	defer s.terminate()
+	for {
+		req, err := readRequest(s.in)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		if req.Type != "request" {
+			continue
+		}
+		if err := s.handle(req); err != nil {
+			return err
+		}
+		if req.Command == "disconnect" {
+			return nil
+		}
+	}
+}
+
+type handler func(s *server, args json.RawMessage) (any, error)
+
+var requests = map[string]handler{
+	"initialize":        (*server).initialize,
+	"launch":            (*server).launch,
+	"setBreakpoints":    (*server).setBreakpoints,
+	"configurationDone": (*server).configurationDone,
+	"threads":           (*server).threads,
+	"stackTrace":        (*server).stackTrace,
+	"scopes":            (*server).scopes,
+	"variables":         (*server).variables,
+	"evaluate":          (*server).evaluate,
+	"continue":          resume((*debugger.Debugger).Continue),
+	"next":              resume((*debugger.Debugger).StepOver),
+	"stepIn":            resume((*debugger.Debugger).StepIn),
+	"stepOut":           resume((*debugger.Debugger).StepOut),
+	"pause":             (*server).pause,
+	"terminate":         (*server).terminateRequest,
+	"disconnect":        (*server).terminateRequest,
+}
+
+// 处理一条请求,只有写回复失败时返回错误
+func (s *server) handle(req *request) error {
+	resp := &response{Type: "response", RequestSeq: req.Seq, Command: req.Command}
+	fn, ok := requests[req.Command]
+	if !ok {
+		resp.Message = "unsupported request: " + req.Command
+		return s.send(resp)
+	}
+	body, err := fn(s, req.Arguments)
+	if err != nil {
+		resp.Message = err.Error()
+		return s.send(resp)
+	}
+	resp.Success, resp.Body = true, body
+	if err := s.send(resp); err != nil {
+		return err
+	}
+	if s.after != nil {
+		after := s.after
+		s.after = nil
+		after()
+	}
+	return nil
+}
+
+// 发送回复或事件,设置消息的序号
+func (s *server) send(msg any) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.seq++
+	switch msg := msg.(type) {
+	case *response:
+		msg.Seq = s.seq
+	case *event:
+		msg.Seq = s.seq
+	}
+	return writeMessage(s.out, msg)
+}
+
+func (s *server) event(name string, body any) {
+	s.send(&event{Type: "event", Event: name, Body: body})
+}
+
+func decode(args json.RawMessage, v any) error {
+	if len(args) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(args, v); err != nil {
+		return fmt.Errorf("invalid arguments: %w", err)
+	}
+	return nil
+}
+
+func (s *server) initialize(args json.RawMessage) (any, error) {
+	var a InitializeArguments
+	if err := decode(args, &a); err != nil {
+		return nil, err
+	}
+	if a.LinesStartAt1 != nil && !*a.LinesStartAt1 {
+		s.lineBase = 0
+	}
+	if a.ColumnsStartAt1 != nil && !*a.ColumnsStartAt1 {
+		s.columnBase = 0
+	}
+	s.after = func() { s.event("initialized", nil) }
+	return Capabilities{
+		SupportsConfigurationDoneRequest: true,
+		SupportsConditionalBreakpoints:   true,
+		SupportsEvaluateForHovers:        true,
+		SupportsTerminateRequest:         true,
+	}, nil
+}
+
+// 读取并解析程序,等到configurationDone之后才开始运行
+func (s *server) launch(args json.RawMessage) (any, error) {
+	var a LaunchArguments
+	if err := decode(args, &a); err != nil {
+		return nil, err
+	}
+	if s.program != nil {
+		return nil, errors.New("program already launched")
+	}
+	data, err := os.ReadFile(a.Program)
+	if err != nil {
+		return nil, err
+	}
+	p := parser.NewParser(lexer.NewLexer(string(data)))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, fmt.Errorf("%s: %s", a.Program, strings.Join(p.Errors(), "; "))
+	}
+	s.program, s.file, s.stopOnEntry = program, a.Program, a.StopOnEntry
+	return nil, nil
+}
+
+// 替换一个文件中的所有断点
+func (s *server) setBreakpoints(args json.RawMessage) (any, error) {
+	var a SetBreakpointsArguments
+	if err := decode(args, &a); err != nil {
+		return nil, err
+	}
+	s.d.ClearBreakpoints(a.Source.Path)
+	breakpoints := []Breakpoint{}
+	for _, sbp := range a.Breakpoints {
+		line := sbp.Line + 1 - s.lineBase
+		res := Breakpoint{Source: a.Source, Line: sbp.Line}
+		if bp, err := s.d.SetBreakpoint(a.Source.Path, line, sbp.Condition); err != nil {
+			res.Message = err.Error()
+		} else {
+			res.ID, res.Verified = bp.ID, true
+		}
+		breakpoints = append(breakpoints, res)
+	}
+	return map[string]any{"breakpoints": breakpoints}, nil
+}
+
+func (s *server) configurationDone(args json.RawMessage) (any, error) {
+	if s.program == nil {
+		return nil, errors.New("no program launched")
+	}
+	if s.started {
+		return nil, nil
+	}
+	s.started = true
+	s.after = func() {
+		cfg := evaluator.Config{Importer: evaluator.NewImporterFromEnv(), File: s.file}
+		s.d.Start(s.program, object.NewEnvironment(), cfg, s.stopOnEntry)
+		go s.watch()
+	}
+	return nil, nil
+}
+
+// 把调试器的事件转换为协议的事件
+func (s *server) watch() {
+	for ev := range s.d.Events() {
+		if ev.Stop != nil {
+			stopped := StoppedEvent{Reason: ev.Stop.Reason, ThreadID: threadID, AllThreadsStopped: true}
+			if ev.Stop.Breakpoint != nil {
+				stopped.HitBreakpointIDs = []int{ev.Stop.Breakpoint.ID}
+			}
+			s.event("stopped", stopped)
+			continue
+		}
+		code := 0
+		if errObj, ok := ev.Result.(*object.Error); ok {
+			code = 1
+			if errObj.Kind != object.CANCELED {
+				s.event("output", OutputEvent{Category: "stderr", Output: s.describe(errObj)})
+			}
+		}
+		s.event("exited", ExitedEvent{ExitCode: code})
+		s.event("terminated", nil)
+		close(s.done)
+		return
+	}
+}
+
+// 错误的位置,消息和调用栈
+func (s *server) describe(errObj *object.Error) string {
+	var b strings.Builder
+	file := s.file
+	if errObj.File != "" {
+		file = errObj.File
+	}
+	if errObj.Line > 0 {
+		fmt.Fprintf(&b, "%s:%d:%d: %s\n", file, errObj.Line, errObj.Column, errObj.Msg)
+	} else {
+		fmt.Fprintf(&b, "%s: %s\n", file, errObj.Msg)
+	}
+	for _, frame := range errObj.Trace {
+		fmt.Fprintf(&b, "  in %s\n", frame)
+	}
+	return b.String()
+}
+
+// 把程序的输出作为output事件发送
+func (s *server) forward(r io.Reader) {
+	buf := make([]byte, 4096)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			s.event("output", OutputEvent{Category: "stdout", Output: string(buf[:n])})
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (s *server) threads(args json.RawMessage) (any, error) {
+	return map[string]any{"threads": []Thread{{ID: threadID, Name: "main"}}}, nil
+}
+
+// 帧的ID为其在调用栈中的下标加一
+func (s *server) stackTrace(args json.RawMessage) (any, error) {
+	var a StackTraceArguments
+	if err := decode(args, &a); err != nil {
+		return nil, err
+	}
+	frames := s.d.Stack()
+	total := len(frames)
+	frames = frames[min(a.StartFrame, total):]
+	if a.Levels > 0 && a.Levels < len(frames) {
+		frames = frames[:a.Levels]
+	}
+	stackFrames := []StackFrame{}
+	for i, f := range frames {
+		sf := StackFrame{ID: a.StartFrame + i + 1, Name: f.Name, Line: f.Line - 1 + s.lineBase, Column: f.Column - 1 + s.columnBase}
+		if f.File != "" {
+			sf.Source = &Source{Name: filepath.Base(f.File), Path: f.File}
+		}
+		stackFrames = append(stackFrames, sf)
+	}
+	return map[string]any{"stackFrames": stackFrames, "totalFrames": total}, nil
+}
+
+func (s *server) frame(id int) (debugger.Frame, error) {
+	frames := s.d.Stack()
+	if id < 1 || id > len(frames) {
+		return debugger.Frame{}, debugger.ErrNoFrame
+	}
+	return frames[id-1], nil
+}
+
+// 为环境或有成员的值分配variablesReference
+func (s *server) reference(v any) int {
+	s.refs = append(s.refs, v)
+	return len(s.refs)
+}
+
+func (s *server) scopes(args json.RawMessage) (any, error) {
+	var a ScopesArguments
+	if err := decode(args, &a); err != nil {
+		return nil, err
+	}
+	f, err := s.frame(a.FrameID)
+	if err != nil {
+		return nil, err
+	}
+	scopes := []Scope{}
+	for _, scope := range debugger.Scopes(f.Env) {
+		hint := ""
+		if scope.Name == "Locals" {
+			hint = "locals"
+		}
+		scopes = append(scopes, Scope{Name: scope.Name, PresentationHint: hint, VariablesReference: s.reference(scope.Env)})
+	}
+	return map[string]any{"scopes": scopes}, nil
+}
+
+func (s *server) variables(args json.RawMessage) (any, error) {
+	var a VariablesArguments
+	if err := decode(args, &a); err != nil {
+		return nil, err
+	}
+	if a.VariablesReference < 1 || a.VariablesReference > len(s.refs) {
+		return nil, fmt.Errorf("invalid variablesReference %d", a.VariablesReference)
+	}
+	var vars []debugger.Variable
+	switch v := s.refs[a.VariablesReference-1].(type) {
+	case *object.Environment:
+		vars = debugger.Variables(v)
+	case object.Object:
+		vars = debugger.Members(v)
+	}
+	variables := []Variable{}
+	for _, v := range vars {
+		variables = append(variables, Variable{
+			Name: v.Name, Value: debugger.Summary(v.Value), Type: string(v.Value.Type()), VariablesReference: s.children(v.Value),
+		})
+	}
+	return map[string]any{"variables": variables}, nil
+}
+
+// 有成员的值可以展开
+func (s *server) children(obj object.Object) int {
+	if len(debugger.Members(obj)) == 0 {
+		return 0
+	}
+	return s.reference(obj)
+}
+
+func (s *server) evaluate(args json.RawMessage) (any, error) {
+	var a EvaluateArguments
+	if err := decode(args, &a); err != nil {
+		return nil, err
+	}
+	frame := 0
+	if a.FrameID > 0 {
+		frame = a.FrameID - 1
+	}
+	res, err := s.d.Evaluate(a.Expression, frame)
+	if err != nil {
+		return nil, err
+	}
+	if errObj, ok := res.(*object.Error); ok {
+		return nil, errors.New(errObj.Msg)
+	}
+	return EvaluateResponse{Result: debugger.Summary(res), Type: string(res.Type()), VariablesReference: s.children(res)}, nil
+}
+
+// 让程序继续运行的请求,之前分配的variablesReference失效
+// 回复之后才继续,保证stopped事件在回复之后
+func resume(fn func(d *debugger.Debugger) error) handler {
+	return func(s *server, args json.RawMessage) (any, error) {
+		if !s.d.Stopped() {
+			return nil, debugger.ErrNotStopped
+		}
+		s.refs = nil
+		s.after = func() { fn(s.d) }
+		return map[string]any{"allThreadsContinued": true}, nil
+	}
+}
+
+func (s *server) pause(args json.RawMessage) (any, error) {
+	s.after = s.d.Pause
+	return nil, nil
+}
+
+func (s *server) terminateRequest(args json.RawMessage) (any, error) {
+	s.terminate()
+	return nil, nil
+}
+
+// 结束程序并等待watch发出结束的事件
+func (s *server) terminate() {
+	if !s.started {
+		return
+	}
+	s.d.Terminate()
+	<-s.done
+}
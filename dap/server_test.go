@@ -0,0 +1,278 @@
+package dap
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 通过管道与调试适配器通信的客户端
+type client struct {
+	t        *testing.T
+	in       *io.PipeWriter
+	messages chan map[string]any
+	pending  []map[string]any //等待回复时收到的事件
+	seq      int
+	done     chan error
+}
+
+func newClient(t *testing.T, programOutput io.Reader) *client {
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	c := &client{t: t, in: inW, messages: make(chan map[string]any, 100), done: make(chan error, 1)}
+	go func() {
+		c.done <- Serve(inR, outW, programOutput)
+		outW.Close()
+	}()
+	go func() {
+		r := bufio.NewReader(outR)
+		for {
+			body, err := readBody(r)
+			if err != nil {
+				close(c.messages)
+				return
+			}
+			var msg map[string]any
+			if err := json.Unmarshal(body, &msg); err != nil {
+				t.Error(err)
+			}
+			c.messages <- msg
+		}
+	}()
+	return c
+}
+
+func (c *client) send(command string, args any) int {
+	c.t.Helper()
+	c.seq++
+	msg := map[string]any{"seq": c.seq, "type": "request", "command": command}
+	if args != nil {
+		msg["arguments"] = args
+	}
+	if err := writeMessage(c.in, msg); err != nil {
+		c.t.Fatal(err)
+	}
+	return c.seq
+}
+
+func (c *client) next() map[string]any {
+	c.t.Helper()
+	select {
+	case msg, ok := <-c.messages:
+		if !ok {
+			c.t.Fatal("server closed the connection")
+		}
+		return msg
+	case <-time.After(5 * time.Second):
+		c.t.Fatal("timed out waiting for a message")
+	}
+	return nil
+}
+
+// 跳过其它消息,直到名为name的事件
+func (c *client) event(name string) map[string]any {
+	c.t.Helper()
+	for {
+		var msg map[string]any
+		if len(c.pending) != 0 {
+			msg, c.pending = c.pending[0], c.pending[1:]
+		} else {
+			msg = c.next()
+		}
+		if msg["type"] == "event" && msg["event"] == name {
+			body, _ := msg["body"].(map[string]any)
+			return body
+		}
+	}
+}
+
+// 发送请求并等待其回复,之间收到的事件留给event
+func (c *client) response(command string, args any) map[string]any {
+	c.t.Helper()
+	seq := c.send(command, args)
+	for {
+		msg := c.next()
+		if msg["type"] == "response" && msg["request_seq"] == float64(seq) {
+			return msg
+		}
+		c.pending = append(c.pending, msg)
+	}
+}
+
+func (c *client) request(command string, args any) map[string]any {
+	c.t.Helper()
+	msg := c.response(command, args)
+	if msg["success"] != true {
+		c.t.Fatalf("%s failed: %v", command, msg["message"])
+	}
+	body, _ := msg["body"].(map[string]any)
+	return body
+}
+
+func (c *client) requestError(command string, args any) string {
+	c.t.Helper()
+	msg := c.response(command, args)
+	if msg["success"] == true {
+		c.t.Fatalf("%s succeeded, want an error", command)
+	}
+	return msg["message"].(string)
+}
+
+func writeScript(t *testing.T, src string) string {
+	path := filepath.Join(t.TempDir(), "main.mi")
+	if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+const script = `fn add(a, b) {
+	let s = a + b;
+	s
+}
+let x = [add(1, 2), {"k": 4}];
+add(x[0], 10)`
+
+func TestDebugSession(t *testing.T) {
+	path := writeScript(t, script)
+	c := newClient(t, nil)
+	caps := c.request("initialize", map[string]any{"clientID": "test", "linesStartAt1": true})
+	if caps["supportsConditionalBreakpoints"] != true {
+		t.Errorf("capabilities = %v", caps)
+	}
+	c.event("initialized")
+	c.request("launch", map[string]any{"program": path})
+	bps := c.request("setBreakpoints", map[string]any{
+		"source":      map[string]any{"path": path},
+		"breakpoints": []any{map[string]any{"line": 2, "condition": "a == 3"}, map[string]any{"line": 3, "condition": "a =="}},
+	})["breakpoints"].([]any)
+	if bps[0].(map[string]any)["verified"] != true || bps[1].(map[string]any)["verified"] != false {
+		t.Errorf("breakpoints = %v", bps)
+	}
+	c.request("configurationDone", nil)
+
+	stopped := c.event("stopped")
+	if stopped["reason"] != "breakpoint" || stopped["threadId"] != float64(1) {
+		t.Errorf("stopped = %v", stopped)
+	}
+	threads := c.request("threads", nil)["threads"].([]any)
+	if len(threads) != 1 {
+		t.Errorf("threads = %v", threads)
+	}
+	frames := c.request("stackTrace", map[string]any{"threadId": 1})["stackFrames"].([]any)
+	var names []string
+	for _, f := range frames {
+		f := f.(map[string]any)
+		names = append(names, f["name"].(string))
+	}
+	if strings.Join(names, " ") != "add <main>" || frames[0].(map[string]any)["line"] != float64(2) {
+		t.Errorf("stackFrames = %v", frames)
+	}
+
+	//main帧的Globals中x可以展开
+	scopes := c.request("scopes", map[string]any{"frameId": 2})["scopes"].([]any)
+	globals := scopes[0].(map[string]any)
+	if len(scopes) != 1 || globals["name"] != "Globals" {
+		t.Fatalf("scopes = %v", scopes)
+	}
+	vars := c.request("variables", map[string]any{"variablesReference": globals["variablesReference"]})["variables"].([]any)
+	x := vars[1].(map[string]any)
+	if x["name"] != "x" || x["value"] != `[3, {k: 4}]` || x["type"] != "ARRAY" {
+		t.Fatalf("variables = %v", vars)
+	}
+	elems := c.request("variables", map[string]any{"variablesReference": x["variablesReference"]})["variables"].([]any)
+	if len(elems) != 2 || elems[1].(map[string]any)["variablesReference"] == float64(0) {
+		t.Errorf("elements of x = %v", elems)
+	}
+
+	res := c.request("evaluate", map[string]any{"expression": "a * b", "frameId": 1})
+	if res["result"] != "30" || res["type"] != "INTEGER" {
+		t.Errorf("evaluate = %v", res)
+	}
+	if msg := c.requestError("evaluate", map[string]any{"expression": "nope", "frameId": 1}); !strings.Contains(msg, "nope") {
+		t.Errorf("evaluate error = %q", msg)
+	}
+
+	c.request("next", map[string]any{"threadId": 1})
+	if stopped := c.event("stopped"); stopped["reason"] != "step" {
+		t.Errorf("stopped = %v", stopped)
+	}
+	c.request("stepOut", map[string]any{"threadId": 1})
+	c.event("stopped")
+	frames = c.request("stackTrace", map[string]any{"threadId": 1})["stackFrames"].([]any)
+	if len(frames) != 1 || frames[0].(map[string]any)["line"] != float64(6) {
+		t.Errorf("stackFrames after stepOut = %v", frames)
+	}
+	c.request("continue", map[string]any{"threadId": 1})
+	if exited := c.event("exited"); exited["exitCode"] != float64(0) {
+		t.Errorf("exited = %v", exited)
+	}
+	c.event("terminated")
+	c.request("disconnect", nil)
+	if err := <-c.done; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRuntimeErrorAndOutput(t *testing.T) {
+	path := writeScript(t, "prints(1)\nlet f = fn() { 1 + true };\nf()")
+	outR, outW := io.Pipe()
+	c := newClient(t, outR)
+	c.request("initialize", nil)
+	if msg := c.requestError("configurationDone", nil); msg == "" {
+		t.Error("configurationDone before launch has no error message")
+	}
+	if msg := c.requestError("launch", map[string]any{"program": path + ".missing"}); msg == "" {
+		t.Error("launching a missing file has no error message")
+	}
+	c.request("launch", map[string]any{"program": path, "stopOnEntry": true})
+	c.request("configurationDone", nil)
+	if stopped := c.event("stopped"); stopped["reason"] != "entry" {
+		t.Errorf("stopped = %v", stopped)
+	}
+	outW.Write([]byte("1\n"))
+	if output := c.event("output"); output["category"] != "stdout" || output["output"] != "1\n" {
+		t.Errorf("output = %v", output)
+	}
+	c.request("continue", map[string]any{"threadId": 1})
+	output := c.event("output")
+	if output["category"] != "stderr" || !strings.Contains(output["output"].(string), "main.mi:2:") {
+		t.Errorf("output = %v", output)
+	}
+	if exited := c.event("exited"); exited["exitCode"] != float64(1) {
+		t.Errorf("exited = %v", exited)
+	}
+	c.requestError("continue", map[string]any{"threadId": 1})
+	if msg := c.requestError("bogus", nil); !strings.Contains(msg, "bogus") {
+		t.Errorf("unsupported request error = %q", msg)
+	}
+	c.in.Close()
+	if err := <-c.done; err != nil {
+		t.Fatal(err)
+	}
+	outW.Close()
+}
+
+func TestDisconnectWhileStopped(t *testing.T) {
+	path := writeScript(t, "fn spin(n) {\n\tspin(n + 1)\n}\nspin(0)")
+	c := newClient(t, nil)
+	c.request("initialize", nil)
+	c.request("launch", map[string]any{"program": path})
+	c.request("configurationDone", nil)
+	c.request("pause", map[string]any{"threadId": 1})
+	if stopped := c.event("stopped"); stopped["reason"] != "pause" {
+		t.Errorf("stopped = %v", stopped)
+	}
+	c.send("disconnect", nil)
+	if exited := c.event("exited"); exited["exitCode"] != float64(1) {
+		t.Errorf("exited = %v", exited)
+	}
+	if err := <-c.done; err != nil {
+		t.Fatal(err)
+	}
+}
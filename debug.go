@@ -0,0 +1,62 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"my-interpreter/dap"
+	"my-interpreter/debugger"
+	evaluator "my-interpreter/evaluator"
+	"my-interpreter/object"
+	"os"
+)
+
+// 交互式调试脚本,或者用-dap在标准输入输出上运行调试适配器
+func debugCommand(args []string) int {
+	flags := flag.NewFlagSet("debug", flag.ContinueOnError)
+	useDAP := flags.Bool("dap", false, "start a debug adapter on stdin and stdout")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *useDAP {
+		if flags.NArg() != 0 {
+			fmt.Fprint(os.Stderr, usage)
+			return 2
+		}
+		return serveDAP()
+	}
+	if flags.NArg() != 1 {
+		fmt.Fprint(os.Stderr, usage)
+		return 2
+	}
+	path := flags.Arg(0)
+	program, ok := loadFile(path)
+	if !ok {
+		return 1
+	}
+	cfg := evaluator.Config{Importer: evaluator.NewImporterFromEnv(), File: path}
+	res := debugger.Interact(program, object.NewEnvironment(), cfg, os.Stdin, os.Stdout)
+	if errObj, ok := res.(*object.Error); ok && errObj.Kind == object.CANCELED {
+		//用quit结束了程序
+		return 1
+	}
+	return report(path, res)
+}
+
+// 标准输出用于协议,脚本的输出改为写到管道,由适配器作为output事件发送
+func serveDAP() int {
+	stdout := os.Stdout
+	r, w, err := os.Pipe()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	os.Stdout = w
+	err = dap.Serve(os.Stdin, stdout, r)
+	os.Stdout = stdout
+	w.Close()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
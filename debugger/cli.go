@@ -0,0 +1,244 @@
+package debugger
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"my-interpreter/ast"
+	evaluator "my-interpreter/evaluator"
+	"my-interpreter/object"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// 交互式调试会话的状态
+type cli struct {
+	d       *Debugger
+	in      *bufio.Scanner
+	out     io.Writer
+	file    string
+	frame   int                 //print和locals使用的帧,最内层为0
+	sources map[string][]string //按文件缓存的源码行
+}
+
+// 调试器命令,run返回true时程序继续运行
+type cliCommand struct {
+	name  string
+	alias string
+	args  string
+	usage string
+	run   func(c *cli, arg string) bool
+}
+
+var cliCommands []cliCommand
+
+func init() {
+	//在init中赋值,避免help引用cliCommands时的初始化循环
+	cliCommands = []cliCommand{
+		{"help", "h", "", "show this help", (*cli).help},
+		{"break", "b", "[file:]line [if cond]", "set a breakpoint, optionally with a condition", (*cli).setBreak},
+		{"delete", "d", "id", "delete a breakpoint", (*cli).deleteBreak},
+		{"breakpoints", "bl", "", "list breakpoints", (*cli).listBreaks},
+		{"continue", "c", "", "run until the next breakpoint", resume((*Debugger).Continue)},
+		{"step", "s", "", "run to the next line, entering calls", resume((*Debugger).StepIn)},
+		{"next", "n", "", "run to the next line, stepping over calls", resume((*Debugger).StepOver)},
+		{"out", "o", "", "run until the current function returns", resume((*Debugger).StepOut)},
+		{"stack", "bt", "", "show the call stack", (*cli).showStack},
+		{"frame", "f", "n", "select frame n of the call stack for print and locals", (*cli).selectFrame},
+		{"locals", "l", "", "show the environment chain of the selected frame", (*cli).showLocals},
+		{"print", "p", "expr", "evaluate expr in the selected frame", (*cli).print},
+		{"quit", "q", "", "stop the program and exit", (*cli).quit},
+	}
+}
+
+// 在调试器中运行program,从in读取命令,在第一条语句前停下
+// 返回程序的求值结果,in结束时结束程序
+func Interact(program *ast.Program, env *object.Environment, cfg evaluator.Config, in io.Reader, out io.Writer) object.Object {
+	c := &cli{d: New(), in: bufio.NewScanner(in), out: out, file: cfg.File, sources: map[string][]string{}}
+	c.d.Start(program, env, cfg, true)
+	for ev := range c.d.Events() {
+		if ev.Stop == nil {
+			return ev.Result
+		}
+		c.stopped(ev.Stop)
+		if !c.prompt() {
+			c.d.Terminate()
+		}
+	}
+	return nil
+}
+
+func (c *cli) stopped(stop *Stop) {
+	c.frame = 0
+	top := stop.Frames[0]
+	switch stop.Reason {
+	case ReasonBreakpoint:
+		fmt.Fprintf(c.out, "breakpoint %d, ", stop.Breakpoint.ID)
+	case ReasonPause:
+		fmt.Fprint(c.out, "paused, ")
+	}
+	fmt.Fprintf(c.out, "%s at %s:%d\n", top.Name, top.File, top.Line)
+	if line, ok := c.sourceLine(top.File, top.Line); ok {
+		fmt.Fprintf(c.out, "%5d  %s\n", top.Line, line)
+	}
+}
+
+// 读取并执行命令,直到程序继续运行时返回true,输入结束时返回false
+func (c *cli) prompt() bool {
+	for {
+		fmt.Fprint(c.out, "(debug) ")
+		if !c.in.Scan() {
+			fmt.Fprintln(c.out)
+			return false
+		}
+		line := strings.TrimSpace(c.in.Text())
+		if line == "" {
+			continue
+		}
+		if c.runCommand(line) {
+			return true
+		}
+	}
+}
+
+func (c *cli) runCommand(line string) bool {
+	name, arg, _ := strings.Cut(line, " ")
+	arg = strings.TrimSpace(arg)
+	for _, cmd := range cliCommands {
+		if cmd.name != name && cmd.alias != name {
+			continue
+		}
+		if cmd.args != "" && !strings.HasPrefix(cmd.args, "[") && arg == "" {
+			fmt.Fprintf(c.out, "usage: %s %s\n", cmd.name, cmd.args)
+			return false
+		}
+		return cmd.run(c, arg)
+	}
+	fmt.Fprintf(c.out, "unknown command: %s, type help for a list of commands\n", name)
+	return false
+}
+
+func (c *cli) help(arg string) bool {
+	for _, cmd := range cliCommands {
+		fmt.Fprintf(c.out, "  %-28s %s\n", fmt.Sprintf("%s, %s %s", cmd.name, cmd.alias, cmd.args), cmd.usage)
+	}
+	return false
+}
+
+// break 12, break lib.mi:3, break 7 if n == 0
+func (c *cli) setBreak(arg string) bool {
+	location, cond, _ := strings.Cut(arg, " if ")
+	location = strings.TrimSpace(location)
+	file := c.file
+	if i := strings.LastIndex(location, ":"); i >= 0 {
+		file, location = location[:i], location[i+1:]
+	}
+	line, err := strconv.Atoi(location)
+	if err != nil || line < 1 {
+		fmt.Fprintf(c.out, "invalid line: %s\n", location)
+		return false
+	}
+	bp, err := c.d.SetBreakpoint(file, line, strings.TrimSpace(cond))
+	if err != nil {
+		fmt.Fprintf(c.out, "invalid condition: %s\n", err)
+		return false
+	}
+	fmt.Fprintf(c.out, "breakpoint %d at %s:%d\n", bp.ID, bp.File, bp.Line)
+	return false
+}
+
+func (c *cli) deleteBreak(arg string) bool {
+	id, err := strconv.Atoi(arg)
+	if err != nil || !c.d.ClearBreakpoint(id) {
+		fmt.Fprintf(c.out, "no breakpoint %s\n", arg)
+	}
+	return false
+}
+
+func (c *cli) listBreaks(arg string) bool {
+	for _, bp := range c.d.Breakpoints() {
+		fmt.Fprintf(c.out, "%d  %s:%d", bp.ID, bp.File, bp.Line)
+		if bp.Condition != "" {
+			fmt.Fprintf(c.out, " if %s", bp.Condition)
+		}
+		fmt.Fprintln(c.out)
+	}
+	return false
+}
+
+// 让程序继续运行的命令
+func resume(fn func(d *Debugger) error) func(c *cli, arg string) bool {
+	return func(c *cli, arg string) bool {
+		if err := fn(c.d); err != nil {
+			fmt.Fprintln(c.out, err)
+			return false
+		}
+		return true
+	}
+}
+
+func (c *cli) showStack(arg string) bool {
+	for i, f := range c.d.Stack() {
+		mark := " "
+		if i == c.frame {
+			mark = "*"
+		}
+		fmt.Fprintf(c.out, "%s#%d  %s at %s:%d\n", mark, i, f.Name, f.File, f.Line)
+	}
+	return false
+}
+
+func (c *cli) selectFrame(arg string) bool {
+	n, err := strconv.Atoi(arg)
+	if err != nil || n < 0 || n >= len(c.d.Stack()) {
+		fmt.Fprintf(c.out, "no frame %s\n", arg)
+		return false
+	}
+	c.frame = n
+	return false
+}
+
+func (c *cli) showLocals(arg string) bool {
+	frames := c.d.Stack()
+	for _, scope := range Scopes(frames[c.frame].Env) {
+		fmt.Fprintf(c.out, "%s:\n", scope.Name)
+		for _, v := range Variables(scope.Env) {
+			fmt.Fprintf(c.out, "  %s = %s\n", v.Name, Summary(v.Value))
+		}
+	}
+	return false
+}
+
+func (c *cli) print(arg string) bool {
+	res, err := c.d.Evaluate(arg, c.frame)
+	if err != nil {
+		fmt.Fprintln(c.out, err)
+		return false
+	}
+	if res != nil {
+		fmt.Fprintln(c.out, res.Inspect())
+	}
+	return false
+}
+
+func (c *cli) quit(arg string) bool {
+	c.d.Terminate()
+	return true
+}
+
+// 源码中的第line行,读取失败时返回false
+func (c *cli) sourceLine(file string, line int) (string, bool) {
+	lines, ok := c.sources[file]
+	if !ok {
+		data, err := os.ReadFile(file)
+		if err == nil {
+			lines = strings.Split(string(data), "\n")
+		}
+		c.sources[file] = lines
+	}
+	if line < 1 || line > len(lines) {
+		return "", false
+	}
+	return strings.TrimRight(lines[line-1], "\r"), true
+}
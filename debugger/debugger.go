@@ -0,0 +1,403 @@
+// debugger包在求值钩子上实现调试器:行断点,条件断点,单步执行,查看调用栈和环境
+// 求值在单独的goroutine中进行,停下时通过Events发出事件,调用Continue等方法后继续
+package debugger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"my-interpreter/ast"
+	evaluator "my-interpreter/evaluator"
+	"my-interpreter/lexer"
+	"my-interpreter/object"
+	"my-interpreter/parser"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// 调用栈中的一帧
+type Frame struct {
+	Name   string           //函数名,顶层为<main>,匿名函数为<anonymous>
+	Fn     *object.Function //顶层为nil
+	Env    *object.Environment
+	File   string
+	Line   int //正在求值的语句的位置,还没有求值语句时为0
+	Column int
+
+	last ast.Statement //该帧中最近求值的语句
+}
+
+// 断点,Condition不为空时只有条件成立才停下
+type Breakpoint struct {
+	ID        int
+	File      string
+	Line      int
+	Condition string
+
+	cond *ast.Program
+}
+
+// 停下的原因
+const (
+	ReasonEntry      = "entry"
+	ReasonBreakpoint = "breakpoint"
+	ReasonStep       = "step"
+	ReasonPause      = "pause"
+)
+
+// 程序停下时的状态
+type Stop struct {
+	Reason     string
+	Breakpoint *Breakpoint //因断点停下时不为nil
+	Frames     []Frame     //调用栈,最内层在前
+}
+
+// 求值停下或结束,Stop为nil表示结束,Result为求值结果
+type Event struct {
+	Stop   *Stop
+	Result object.Object
+}
+
+type stepMode int
+
+const (
+	run stepMode = iota
+	stepIn
+	stepOver
+	stepOut
+)
+
+var (
+	ErrNotStopped = errors.New("program is not stopped")
+	ErrNoFrame    = errors.New("no such frame")
+)
+
+type Debugger struct {
+	mu          sync.Mutex
+	breakpoints []*Breakpoint
+	nextID      int
+	frames      []*Frame //最外层在前
+	mode        stepMode
+	depth       int //开始单步时的栈深度
+	pause       bool
+	stopped     bool
+	terminated  bool
+	cancel      context.CancelFunc
+	events      chan Event
+	resume      chan struct{}
+}
+
+func New() *Debugger {
+	//程序停下后等待继续,所以最多只有一个未读的事件
+	return &Debugger{events: make(chan Event, 1), resume: make(chan struct{})}
+}
+
+// 在新的goroutine中求值program,stopOnEntry为true时在第一条语句前停下
+// cfg.Hook会被替换为调试器
+func (d *Debugger) Start(program *ast.Program, env *object.Environment, cfg evaluator.Config, stopOnEntry bool) {
+	ctx, cancel := context.WithCancel(context.Background())
+	d.mu.Lock()
+	d.cancel = cancel
+	d.frames = []*Frame{{Name: "<main>", Env: env, File: cfg.File}}
+	if stopOnEntry {
+		d.mode = stepIn
+	}
+	d.mu.Unlock()
+	cfg.Hook = d
+	go func() {
+		defer cancel()
+		res := evaluator.EvalContext(ctx, program, env, cfg)
+		d.events <- Event{Result: res}
+	}()
+}
+
+// 程序停下和结束的事件,程序结束后不再有事件
+func (d *Debugger) Events() <-chan Event {
+	return d.events
+}
+
+// 在file的第line行设置断点,条件有语法错误时返回错误
+func (d *Debugger) SetBreakpoint(file string, line int, condition string) (*Breakpoint, error) {
+	bp := &Breakpoint{File: file, Line: line, Condition: condition}
+	if condition != "" {
+		program, err := parse(condition)
+		if err != nil {
+			return nil, err
+		}
+		bp.cond = program
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.nextID++
+	bp.ID = d.nextID
+	d.breakpoints = append(d.breakpoints, bp)
+	return bp, nil
+}
+
+// 删除断点,断点不存在时返回false
+func (d *Debugger) ClearBreakpoint(id int) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for i, bp := range d.breakpoints {
+		if bp.ID == id {
+			d.breakpoints = append(d.breakpoints[:i], d.breakpoints[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// 删除file中的所有断点
+func (d *Debugger) ClearBreakpoints(file string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var kept []*Breakpoint
+	for _, bp := range d.breakpoints {
+		if !sameFile(bp.File, file) {
+			kept = append(kept, bp)
+		}
+	}
+	d.breakpoints = kept
+}
+
+func (d *Debugger) Breakpoints() []*Breakpoint {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]*Breakpoint{}, d.breakpoints...)
+}
+
+// 继续运行直到断点
+func (d *Debugger) Continue() error {
+	return d.resumeWith(run)
+}
+
+// 运行到下一行,进入调用的函数
+func (d *Debugger) StepIn() error {
+	return d.resumeWith(stepIn)
+}
+
+// 运行到当前函数的下一行或者返回,不进入调用的函数
+func (d *Debugger) StepOver() error {
+	return d.resumeWith(stepOver)
+}
+
+// 运行到当前函数返回,停在调用方
+func (d *Debugger) StepOut() error {
+	return d.resumeWith(stepOut)
+}
+
+func (d *Debugger) resumeWith(mode stepMode) error {
+	d.mu.Lock()
+	if !d.stopped {
+		d.mu.Unlock()
+		return ErrNotStopped
+	}
+	d.mode, d.depth, d.stopped = mode, len(d.frames), false
+	d.mu.Unlock()
+	d.resume <- struct{}{}
+	return nil
+}
+
+// 程序是否停下等待继续
+func (d *Debugger) Stopped() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.stopped
+}
+
+// 在下一条语句前停下
+func (d *Debugger) Pause() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.pause = true
+}
+
+// 结束求值,程序以CANCELED错误结束
+func (d *Debugger) Terminate() {
+	d.mu.Lock()
+	d.terminated = true
+	stopped := d.stopped
+	d.stopped = false
+	if d.cancel != nil {
+		d.cancel()
+	}
+	d.mu.Unlock()
+	if stopped {
+		d.resume <- struct{}{}
+	}
+}
+
+// 当前的调用栈,最内层在前,只有停下时才稳定
+func (d *Debugger) Stack() []Frame {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.stack()
+}
+
+func (d *Debugger) stack() []Frame {
+	frames := make([]Frame, len(d.frames))
+	for i, f := range d.frames {
+		frames[len(frames)-1-i] = *f
+	}
+	return frames
+}
+
+// 在调用栈第frame帧(最内层为0)的环境中求值src,只能在停下时调用
+func (d *Debugger) Evaluate(src string, frame int) (object.Object, error) {
+	d.mu.Lock()
+	if !d.stopped {
+		d.mu.Unlock()
+		return nil, ErrNotStopped
+	}
+	if frame < 0 || frame >= len(d.frames) {
+		d.mu.Unlock()
+		return nil, ErrNoFrame
+	}
+	env := d.frames[len(d.frames)-1-frame].Env
+	d.mu.Unlock()
+	program, err := parse(src)
+	if err != nil {
+		return nil, err
+	}
+	return evaluate(program, env), nil
+}
+
+// 求值表达式和条件时的步数限制,避免死循环卡住调试器
+const evalSteps = 1_000_000
+
+func evaluate(program *ast.Program, env *object.Environment) object.Object {
+	return evaluator.EvalContext(context.Background(), program, env, evaluator.Config{Limits: evaluator.Limits{MaxSteps: evalSteps}})
+}
+
+func parse(src string) (*ast.Program, error) {
+	p := parser.NewParser(lexer.NewLexer(src))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, fmt.Errorf("%s", strings.Join(p.Errors(), "; "))
+	}
+	return program, nil
+}
+
+// Statement实现evaluator.Hook,需要时停下等待继续
+func (d *Debugger) Statement(file string, stmt ast.Statement, env *object.Environment) *object.Error {
+	d.mu.Lock()
+	if d.terminated {
+		d.mu.Unlock()
+		return canceled()
+	}
+	top := d.frames[len(d.frames)-1]
+	pos := ast.Pos(stmt)
+	//同一行中后面的语句不算新的一行,循环回到前面的语句时算
+	newLine := top.last == nil || file != top.File || pos.Line != top.Line ||
+		pos.Column <= top.Column
+	top.File, top.Line, top.Column, top.Env, top.last = file, pos.Line, pos.Column, env, stmt
+	stop := d.check(newLine)
+	if stop == nil {
+		d.mu.Unlock()
+		return nil
+	}
+	if d.wait(stop) {
+		return canceled()
+	}
+	return nil
+}
+
+// 发出停下的事件并等待继续,调用方持有锁,返回时已释放
+// 求值被结束时返回true
+func (d *Debugger) wait(stop *Stop) bool {
+	d.pause, d.stopped = false, true
+	stop.Frames = d.stack()
+	d.mu.Unlock()
+
+	d.events <- Event{Stop: stop}
+	<-d.resume
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.terminated
+}
+
+// 是否需要在当前语句前停下,调用方持有锁
+func (d *Debugger) check(newLine bool) *Stop {
+	if d.pause {
+		return &Stop{Reason: ReasonPause}
+	}
+	if !newLine {
+		return nil
+	}
+	switch {
+	case d.mode == stepIn,
+		d.mode == stepOver && len(d.frames) <= d.depth,
+		d.mode == stepOut && len(d.frames) < d.depth:
+		reason := ReasonStep
+		if d.depth == 0 {
+			reason = ReasonEntry
+		}
+		return &Stop{Reason: reason}
+	}
+	top := d.frames[len(d.frames)-1]
+	for _, bp := range d.breakpoints {
+		if bp.Line != top.Line || !sameFile(bp.File, top.File) {
+			continue
+		}
+		if bp.cond != nil && !truthy(evaluate(bp.cond, top.Env)) {
+			continue
+		}
+		return &Stop{Reason: ReasonBreakpoint, Breakpoint: bp}
+	}
+	return nil
+}
+
+// Call实现evaluator.Hook,压入新的帧
+func (d *Debugger) Call(fn *object.Function, args []object.Object, env *object.Environment) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	name := fn.Name
+	if name == "" {
+		name = "<anonymous>"
+	}
+	d.frames = append(d.frames, &Frame{Name: name, Fn: fn, Env: env, File: fn.File})
+}
+
+// Return实现evaluator.Hook,弹出函数的帧
+// 单步执行时开始单步的函数返回后,停在调用方正在求值的语句
+func (d *Debugger) Return(fn *object.Function, result object.Object) {
+	d.mu.Lock()
+	if len(d.frames) > 1 {
+		d.frames = d.frames[:len(d.frames)-1]
+	}
+	//尾调用时马上会进入下一个函数,不停下
+	if result == nil || d.mode == run || d.terminated || len(d.frames) >= d.depth {
+		d.mu.Unlock()
+		return
+	}
+	d.wait(&Stop{Reason: ReasonStep})
+}
+
+func canceled() *object.Error {
+	return &object.Error{Kind: object.CANCELED, Msg: "evaluation canceled"}
+}
+
+// 错误,false和nil以外的值都为真
+func truthy(obj object.Object) bool {
+	switch obj := obj.(type) {
+	case *object.Error, *object.Null:
+		return false
+	case *object.Boolean:
+		return obj.Value
+	}
+	return true
+}
+
+// 比较两个路径是否指向同一文件,空路径只与空路径相同
+func sameFile(a, b string) bool {
+	if a == b {
+		return true
+	}
+	if a == "" || b == "" {
+		return false
+	}
+	absA, errA := filepath.Abs(a)
+	absB, errB := filepath.Abs(b)
+	return errA == nil && errB == nil && absA == absB
+}
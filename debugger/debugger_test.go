@@ -0,0 +1,255 @@
+package debugger
+
+import (
+	evaluator "my-interpreter/evaluator"
+	"my-interpreter/object"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+const script = `fn add(a, b) {
+	let s = a + b;
+	s
+}
+let x = add(1, 2);
+let y = add(x, 10);
+y`
+
+func start(t *testing.T, src string, stopOnEntry bool, breakpoints ...func(d *Debugger)) *Debugger {
+	t.Helper()
+	program, err := parse(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := New()
+	for _, set := range breakpoints {
+		set(d)
+	}
+	d.Start(program, object.NewEnvironment(), evaluator.Config{File: "main.mi"}, stopOnEntry)
+	return d
+}
+
+func next(t *testing.T, d *Debugger) Event {
+	t.Helper()
+	select {
+	case ev := <-d.Events():
+		return ev
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the debugger")
+		return Event{}
+	}
+}
+
+// 检查程序停在line行,调用栈中的函数名依次为names
+func expectStop(t *testing.T, d *Debugger, reason string, line int, names ...string) *Stop {
+	t.Helper()
+	ev := next(t, d)
+	if ev.Stop == nil {
+		t.Fatalf("program finished with %v, want a stop at line %d", ev.Result, line)
+	}
+	var got []string
+	for _, f := range ev.Stop.Frames {
+		got = append(got, f.Name)
+	}
+	if ev.Stop.Reason != reason || ev.Stop.Frames[0].Line != line || strings.Join(got, " ") != strings.Join(names, " ") {
+		t.Fatalf("stopped for %s at line %d in %v, want %s at line %d in %v",
+			ev.Stop.Reason, ev.Stop.Frames[0].Line, got, reason, line, names)
+	}
+	return ev.Stop
+}
+
+func expectResult(t *testing.T, d *Debugger, want string) {
+	t.Helper()
+	ev := next(t, d)
+	if ev.Stop != nil {
+		t.Fatalf("stopped at line %d, want the program to finish", ev.Stop.Frames[0].Line)
+	}
+	if ev.Result.Inspect() != want {
+		t.Fatalf("result = %s, want %s", ev.Result.Inspect(), want)
+	}
+}
+
+func TestBreakpoints(t *testing.T) {
+	var bp *Breakpoint
+	d := start(t, script, false, func(d *Debugger) {
+		var err error
+		if bp, err = d.SetBreakpoint("main.mi", 2, "a == 3"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := d.SetBreakpoint("other.mi", 5, ""); err != nil {
+			t.Fatal(err)
+		}
+	})
+	stop := expectStop(t, d, ReasonBreakpoint, 2, "add", "<main>")
+	if stop.Breakpoint != bp {
+		t.Errorf("stopped at breakpoint %v, want %v", stop.Breakpoint, bp)
+	}
+	for _, tt := range []struct {
+		src   string
+		frame int
+		want  string
+	}{
+		{"a + b", 0, "13"},
+		{"x", 1, "3"},
+		{"y", 1, "identifier not found: y"},
+	} {
+		res, err := d.Evaluate(tt.src, tt.frame)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(res.Inspect(), tt.want) {
+			t.Errorf("Evaluate(%q, %d) = %s, want %s", tt.src, tt.frame, res.Inspect(), tt.want)
+		}
+	}
+	if _, err := d.Evaluate("1 +", 0); err == nil {
+		t.Error("Evaluate with a syntax error succeeded")
+	}
+	if _, err := d.Evaluate("1", 5); err != ErrNoFrame {
+		t.Errorf("Evaluate in frame 5 returned %v, want ErrNoFrame", err)
+	}
+	if _, err := d.SetBreakpoint("main.mi", 1, "a =="); err == nil {
+		t.Error("SetBreakpoint with an invalid condition succeeded")
+	}
+	if err := d.Continue(); err != nil {
+		t.Fatal(err)
+	}
+	expectResult(t, d, "13")
+	if err := d.Continue(); err != ErrNotStopped {
+		t.Errorf("Continue after the program finished returned %v, want ErrNotStopped", err)
+	}
+}
+
+func TestStepping(t *testing.T) {
+	d := start(t, script, true)
+	expectStop(t, d, ReasonEntry, 5, "<main>")
+	steps := []struct {
+		step   func() error
+		line   int
+		frames []string
+	}{
+		{d.StepIn, 2, []string{"add", "<main>"}},
+		{d.StepOver, 3, []string{"add", "<main>"}},
+		{d.StepOut, 5, []string{"<main>"}},
+		{d.StepOver, 6, []string{"<main>"}},
+		{d.StepOver, 7, []string{"<main>"}},
+	}
+	for _, step := range steps {
+		if err := step.step(); err != nil {
+			t.Fatal(err)
+		}
+		expectStop(t, d, ReasonStep, step.line, step.frames...)
+	}
+	if err := d.StepIn(); err != nil {
+		t.Fatal(err)
+	}
+	expectResult(t, d, "13")
+}
+
+func TestScopes(t *testing.T) {
+	src := `let g = 1;
+let mk = fn(x) {
+	fn(y) {
+		x + y
+	}
+};
+mk(2)(3)`
+	d := start(t, src, false, func(d *Debugger) { d.SetBreakpoint("main.mi", 4, "") })
+	stop := expectStop(t, d, ReasonBreakpoint, 4, "<anonymous>", "<main>")
+	var got []string
+	for _, scope := range Scopes(stop.Frames[0].Env) {
+		var vars []string
+		for _, v := range Variables(scope.Env) {
+			vars = append(vars, v.Name+"="+v.Value.Inspect())
+		}
+		got = append(got, scope.Name+"["+strings.Join(vars, " ")+"]")
+	}
+	want := "Locals[y=3] Closure[x=2] Globals[g=1 mk=fn"
+	if joined := strings.Join(got, " "); !strings.HasPrefix(joined, want) {
+		t.Errorf("scopes = %s, want prefix %s", joined, want)
+	}
+	d.Continue()
+	expectResult(t, d, "5")
+}
+
+func TestMembers(t *testing.T) {
+	program, _ := parse(`struct P { x, y }; [{"b": 2, "a": 1}, P{x: 1, y: [2]}, 3]`)
+	elems := Members(evaluator.Eval(program, object.NewEnvironment()))
+	want := []string{"a=1 b=2", "x=1 y=[2]", ""}
+	for i, elem := range elems {
+		var got []string
+		for _, v := range Members(elem.Value) {
+			got = append(got, v.Name+"="+v.Value.Inspect())
+		}
+		if elem.Name != strconv.Itoa(i) || strings.Join(got, " ") != want[i] {
+			t.Errorf("Members(%s) = %v, want %s", elem.Value.Inspect(), got, want[i])
+		}
+	}
+}
+
+func TestPauseAndTerminate(t *testing.T) {
+	d := start(t, "fn spin(n) {\n\tspin(n + 1)\n}\nspin(0)", false)
+	d.Pause()
+	//暂停时程序可能还没有进入spin
+	if ev := next(t, d); ev.Stop == nil || ev.Stop.Reason != ReasonPause {
+		t.Fatalf("got %+v, want a pause", ev)
+	}
+	if err := d.Continue(); err != nil {
+		t.Fatal(err)
+	}
+	d.Terminate()
+	ev := next(t, d)
+	errObj, ok := ev.Result.(*object.Error)
+	if ev.Stop != nil || !ok || errObj.Kind != object.CANCELED {
+		t.Fatalf("terminated program finished with %v, want a CANCELED error", ev.Result)
+	}
+}
+
+func TestInteract(t *testing.T) {
+	input := strings.Join([]string{
+		"break 2 if b == 10",
+		"breakpoints",
+		"c",
+		"stack",
+		"p s",
+		"n",
+		"p s",
+		"f 1",
+		"locals",
+		"bogus",
+		"c",
+	}, "\n")
+	var out strings.Builder
+	program, _ := parse(script)
+	res := Interact(program, object.NewEnvironment(), evaluator.Config{File: "main.mi"}, strings.NewReader(input), &out)
+	if res.Inspect() != "13" {
+		t.Errorf("result = %s, want 13", res.Inspect())
+	}
+	for _, want := range []string{
+		"<main> at main.mi:5",
+		"breakpoint 1 at main.mi:2",
+		"1  main.mi:2 if b == 10",
+		"breakpoint 1, add at main.mi:2",
+		"*#0  add at main.mi:2\n #1  <main> at main.mi:6",
+		"(debug) identifier not found: s",
+		"add at main.mi:3\n(debug) 13",
+		"Globals:\n  add = fn add(a, b) {...}\n  x = 3\n",
+		"unknown command: bogus",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output does not contain %q:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestInteractQuit(t *testing.T) {
+	var out strings.Builder
+	program, _ := parse(script)
+	res := Interact(program, object.NewEnvironment(), evaluator.Config{File: "main.mi"}, strings.NewReader("q"), &out)
+	if errObj, ok := res.(*object.Error); !ok || errObj.Kind != object.CANCELED {
+		t.Errorf("result = %v, want a CANCELED error", res)
+	}
+}
+
+var _ evaluator.Hook = (*Debugger)(nil)
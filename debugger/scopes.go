@@ -0,0 +1,83 @@
+package debugger
+
+import (
+	"my-interpreter/object"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// 环境链中的一层
+type Scope struct {
+	Name string //最内层为Locals,最外层为Globals,中间为Closure
+	Env  *object.Environment
+}
+
+// env及其外层环境,从内到外排列
+func Scopes(env *object.Environment) []Scope {
+	var scopes []Scope
+	for e := env; e != nil; e = e.Outer() {
+		name := "Closure"
+		switch {
+		case e.Outer() == nil:
+			name = "Globals"
+		case e == env:
+			name = "Locals"
+		}
+		scopes = append(scopes, Scope{Name: name, Env: e})
+	}
+	return scopes
+}
+
+// 一个绑定或者值的成员
+type Variable struct {
+	Name  string
+	Value object.Object
+	Const bool
+}
+
+// env当前一层中的绑定,按名字排列
+func Variables(env *object.Environment) []Variable {
+	var vars []Variable
+	for _, name := range env.Names() {
+		value, _ := env.Get(name)
+		vars = append(vars, Variable{Name: name, Value: value, Const: env.IsConst(name)})
+	}
+	return vars
+}
+
+// 数组的元素,映射的键值对,结构体的字段和模块导出的名字,其它值没有成员
+// 映射按键的Inspect()排列
+func Members(obj object.Object) []Variable {
+	var vars []Variable
+	switch obj := obj.(type) {
+	case *object.Array:
+		for i, elem := range obj.Elements {
+			vars = append(vars, Variable{Name: strconv.Itoa(i), Value: elem})
+		}
+	case *object.Map:
+		for _, pair := range obj.Mappings {
+			vars = append(vars, Variable{Name: pair.Key.Inspect(), Value: pair.Value})
+		}
+		sort.Slice(vars, func(i, j int) bool { return vars[i].Name < vars[j].Name })
+	case *object.Struct:
+		for i, field := range obj.StructType.Fields {
+			vars = append(vars, Variable{Name: field, Value: obj.Values[i]})
+		}
+	case *object.Module:
+		for _, name := range obj.Exports() {
+			value, _ := obj.Member(name)
+			vars = append(vars, Variable{Name: name, Value: value})
+		}
+	}
+	return vars
+}
+
+// 值的单行表示,函数等多行的值只保留第一行
+func Summary(obj object.Object) string {
+	s := obj.Inspect()
+	if first, _, ok := strings.Cut(s, "\n"); ok {
+		return first + "...}"
+	}
+	return s
+}
@@ -0,0 +1,120 @@
+package builtins
+
+import (
+	"fmt"
+	"my-interpreter/object"
+	"strings"
+)
+
+// 断言失败的错误,可以被try捕获,测试运行器把它报告为失败而不是出错
+func assertionError(message object.Object, format string, a ...any) *object.Error {
+	msg := fmt.Sprintf(format, a...)
+	if message != nil {
+		msg = fmt.Sprintf("%s: %s", message.Inspect(), msg)
+	}
+	return &object.Error{Kind: object.ASSERTION_ERROR, Msg: msg}
+}
+
+// 可选的最后一个参数
+func optional(params []object.Object, i int) object.Object {
+	if i < len(params) {
+		return params[i]
+	}
+	return nil
+}
+
+// assert(condition)或者assert(condition, message)
+func assert(params ...object.Object) object.Object {
+	if len(params) != 1 && len(params) != 2 {
+		return newError("wrong number of arguments. got=%d, want=1 or 2", len(params))
+	}
+	if !isTruthy(params[0]) {
+		return assertionError(optional(params, 1), "assertion failed")
+	}
+	return Nil
+}
+
+// assertEq(actual, expected)或者assertEq(actual, expected, message),按==比较
+func assertEq(params ...object.Object) object.Object {
+	if len(params) != 2 && len(params) != 3 {
+		return newError("wrong number of arguments. got=%d, want=2 or 3", len(params))
+	}
+	actual, expected := params[0], params[1]
+	if object.Equal(actual, expected) {
+		return Nil
+	}
+	got, want := actual.Inspect(), expected.Inspect()
+	if got == want {
+		//比如1和"1"
+		got += " (" + string(actual.Type()) + ")"
+		want += " (" + string(expected.Type()) + ")"
+	}
+	return assertionError(optional(params, 2), "values are not equal (-want +got):\n%s", diffLines(want, got))
+}
+
+// assertThrows(function)或者assertThrows(function, message),不带参数调用function
+func assertThrows(call object.Caller, params ...object.Object) object.Object {
+	if len(params) != 1 && len(params) != 2 {
+		return newError("wrong number of arguments. got=%d, want=1 or 2", len(params))
+	}
+	switch params[0].(type) {
+	case *object.Function, *object.Builtins:
+	default:
+		return newError("argument to `assertThrows` must be FUNCTION, got %s", params[0].Type())
+	}
+	var want string
+	if len(params) == 2 {
+		msg, ok := params[1].(*object.String)
+		if !ok {
+			return newError("message to `assertThrows` must be STRING, got %s", params[1].Type())
+		}
+		want = msg.Value
+	}
+	err, ok := call(params[0]).(*object.Error)
+	if !ok {
+		return assertionError(nil, "expected an error to be thrown")
+	}
+	if !err.Catchable() {
+		return err
+	}
+	if !strings.Contains(err.Msg, want) {
+		return assertionError(nil, "expected an error containing %q, got %q", want, err.Msg)
+	}
+	return caughtValue(err)
+}
+
+// 逐行比较,-开头的行只在want中,+开头的只在got中,相同的行以两个空格开头
+func diffLines(want, got string) string {
+	a, b := strings.Split(want, "\n"), strings.Split(got, "\n")
+	//lcs[i][j]为a[i:]和b[j:]的最长公共子序列的长度
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	var out []string
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			out = append(out, "  "+a[i])
+			i++
+			j++
+		case j == len(b) || i < len(a) && lcs[i+1][j] >= lcs[i][j+1]:
+			out = append(out, "- "+a[i])
+			i++
+		default:
+			out = append(out, "+ "+b[j])
+			j++
+		}
+	}
+	return strings.Join(out, "\n")
+}
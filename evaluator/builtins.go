@@ -3,9 +3,75 @@ package builtins
 import (
 	"fmt"
 	"my-interpreter/object"
+	"sort"
 )
 
+func init() {
+	for name, b := range builtins {
+		b.Name = name
+	}
+}
+
+// 所有内置函数的名字,按字典序排列
+func BuiltinNames() []string {
+	names := make([]string, 0, len(builtins))
+	for name := range builtins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// 内置函数的签名,供lint检查参数个数和编辑器显示,MaxArgs为-1时参数个数不限
+type Signature struct {
+	Params  string
+	Doc     string //显示给用户的说明,与错误消息一样使用英文
+	MinArgs int
+	MaxArgs int
+}
+
+func (s Signature) String() string {
+	return "(" + s.Params + ")"
+}
+
+// 按名字查找内置函数的签名
+func BuiltinSignature(name string) (Signature, bool) {
+	sig, ok := signatures[name]
+	return sig, ok
+}
+
+var signatures = map[string]Signature{
+	"error":    {Params: "message, data?", Doc: "Creates an error value that can be thrown.", MinArgs: 1, MaxArgs: 2},
+	"len":      {Params: "value", Doc: "Returns the number of bytes in a string or elements in an array.", MinArgs: 1, MaxArgs: 1},
+	"first":    {Params: "array", Doc: "Returns the first element of an array, or null if it is empty.", MinArgs: 1, MaxArgs: 1},
+	"last":     {Params: "array", Doc: "Returns the last element of an array, or null if it is empty.", MinArgs: 1, MaxArgs: 1},
+	"push":     {Params: "array, element", Doc: "Returns a new array with element appended.", MinArgs: 2, MaxArgs: 2},
+	"pop":      {Params: "array", Doc: "Returns a new array without the last element.", MinArgs: 1, MaxArgs: 1},
+	"freeze":   {Params: "value", Doc: "Returns a deeply immutable copy of value.", MinArgs: 1, MaxArgs: 1},
+	"isFrozen": {Params: "value", Doc: "Reports whether value is immutable. Values other than arrays, maps and structs are always immutable.", MinArgs: 1, MaxArgs: 1},
+	"prints":   {Params: "...values", Doc: "Prints each value on its own line.", MinArgs: 0, MaxArgs: -1},
+
+	"assert":       {Params: "condition, message?", Doc: "Fails if condition is false.", MinArgs: 1, MaxArgs: 2},
+	"assertEq":     {Params: "actual, expected, message?", Doc: "Fails if actual and expected differ, showing the difference.", MinArgs: 2, MaxArgs: 3},
+	"assertThrows": {Params: "function, message?", Doc: "Calls function and fails if it does not throw, or if the error message does not contain message. Returns the caught error.", MinArgs: 1, MaxArgs: 2},
+}
+
 var builtins = map[string]*object.Builtins{
+	// error(message)或者error(message, data),创建可以throw的错误值
+	"error": {Fn: func(params ...object.Object) object.Object {
+		if len(params) != 1 && len(params) != 2 {
+			return newError("wrong number of arguments. got=%d, want=1 or 2", len(params))
+		}
+		msg, ok := params[0].(*object.String)
+		if !ok {
+			return newError("argument to `error` must be STRING, got %s", params[0].Type())
+		}
+		res := &object.ErrorValue{Kind: object.USER_ERROR, Msg: msg.Value, Data: Nil}
+		if len(params) == 2 {
+			res.Data = params[1]
+		}
+		return res
+	}},
 	// len(string)或者len(array)
 	"len": {Fn: func(params ...object.Object) object.Object {
 		if len(params) != 1 {
@@ -28,7 +94,7 @@ var builtins = map[string]*object.Builtins{
 			return newError("wrong number of arguments. got=%d, want=1", len(params))
 		}
 		if params[0].Type() != object.ARRAY {
-			return newError("argument to `first` must be INTEGER, got %s", params[0].Type())
+			return newError("argument to `first` must be ARRAY, got %s", params[0].Type())
 		}
 		arr := params[0].(*object.Array)
 		if len(arr.Elements) > 0 {
@@ -42,7 +108,7 @@ var builtins = map[string]*object.Builtins{
 			return newError("wrong number of arguments. got=%d, want=1", len(params))
 		}
 		if params[0].Type() != object.ARRAY {
-			return newError("argument to `last` must be INTEGER, got %s", params[0].Type())
+			return newError("argument to `last` must be ARRAY, got %s", params[0].Type())
 		}
 		arr := params[0].(*object.Array)
 		if len(arr.Elements) > 0 {
@@ -77,11 +143,28 @@ var builtins = map[string]*object.Builtins{
 		}
 		arr := params[0].(*object.Array)
 		length := len(arr.Elements)
+		if length == 0 {
+			return Nil
+		}
 
 		newArr := make([]object.Object, length-1)
 		copy(newArr, arr.Elements[:length-1])
 		return &object.Array{Elements: newArr}
 	}},
+	// freeze(value),返回深层不可变的副本
+	"freeze": {Fn: func(params ...object.Object) object.Object {
+		if len(params) != 1 {
+			return newError("wrong number of arguments. got=%d, want=1", len(params))
+		}
+		return object.Freeze(params[0])
+	}},
+	// isFrozen(value)
+	"isFrozen": {Fn: func(params ...object.Object) object.Object {
+		if len(params) != 1 {
+			return newError("wrong number of arguments. got=%d, want=1", len(params))
+		}
+		return nativeBool2BooleanObject(object.IsFrozen(params[0]))
+	}},
 	// prints(任意数量任何类型的数据)
 	"prints": {Fn: func(params ...object.Object) object.Object {
 		for _, param := range params {
@@ -89,4 +172,13 @@ var builtins = map[string]*object.Builtins{
 		}
 		return Nil
 	}},
+
+	"assert":   {Fn: assert},
+	"assertEq": {Fn: assertEq},
+	// 直接调用Fn时不能回调,只检查参数
+	"assertThrows": {Fn: func(params ...object.Object) object.Object {
+		return assertThrows(func(object.Object, ...object.Object) object.Object {
+			return newError("assertThrows cannot call functions here")
+		}, params...)
+	}, Call: assertThrows},
 }
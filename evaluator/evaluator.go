@@ -1,6 +1,7 @@
 package builtins
 
 import (
+	"context"
 	"fmt"
 	"my-interpreter/ast"
 	"my-interpreter/object"
@@ -13,20 +14,108 @@ var (
 )
 
 func Eval(node ast.Node, env *object.Environment) object.Object {
+	return EvalContext(context.Background(), node, env, Config{})
+}
+
+// 在ctx结束或超出cfg.Limits时停止求值,返回对应种类的错误
+func EvalContext(ctx context.Context, node ast.Node, env *object.Environment, cfg Config) object.Object {
+	st, cancel := newState(ctx, cfg)
+	defer cancel()
+	if err := st.checkContext(); err != nil {
+		return err
+	}
+	return st.result(eval(st, node, env))
+}
+
+// 供宿主程序调用脚本函数或内置函数
+func ApplyFunction(fn object.Object, args []object.Object) object.Object {
+	return ApplyFunctionContext(context.Background(), fn, args, Config{})
+}
+
+func ApplyFunctionContext(ctx context.Context, fn object.Object, args []object.Object, cfg Config) object.Object {
+	st, cancel := newState(ctx, cfg)
+	defer cancel()
+	if err := st.checkContext(); err != nil {
+		return err
+	}
+	return st.result(applyFunction(st, fn, args, nil))
+}
+
+// 求值一个节点,新产生的错误记下该节点的位置
+func eval(st *state, node ast.Node, env *object.Environment) object.Object {
+	res := evalNode(st, node, env)
+	if err, ok := res.(*object.Error); ok && err.Line == 0 {
+		if tok, ok := nodeToken(node); ok {
+			st.locate(err, tok)
+		}
+	}
+	if st.alloc != nil {
+		st.allocated(node, res)
+	}
+	return res
+}
+
+func evalNode(st *state, node ast.Node, env *object.Environment) object.Object {
+	if err := st.step(); err != nil {
+		return err
+	}
 	switch node := node.(type) {
 	case *ast.Program:
-		return evalProgram(node, env)
+		return evalProgram(st, node, env)
 
 	case *ast.BlockStatement:
-		return evalBlockStatement(node, env)
+		return evalBlockStatement(st, node, env)
 
 	case *ast.ExpressionStatement:
-		return Eval(node.Expr, env)
+		return eval(st, node.Expr, env)
 
 	case *ast.ReturnStatement:
-		val := Eval(node.ReturnValue, env)
+		val := eval(st, node.ReturnValue, env)
+		if isError(val) {
+			return val
+		}
 		return &object.Return{Value: val}
 
+	case *ast.ImportStatement:
+		return evalImportStatement(st, node, env)
+
+	case *ast.ThrowStatement:
+		return evalThrowStatement(st, node, env)
+
+	case *ast.StructStatement:
+		if err := checkConst(st, env, node.Name); err != nil {
+			return err
+		}
+		structType := &object.StructType{Name: node.Name.Token.Literal}
+		for _, f := range node.Fields {
+			structType.Fields = append(structType.Fields, f.Token.Literal)
+		}
+		env.Set(structType.Name, structType)
+
+	case *ast.FunctionStatement:
+		if err := checkConst(st, env, node.Name); err != nil {
+			return err
+		}
+		env.Set(node.Name.Token.Literal, newFunction(st, node.Function, env, node.Name.Token.Literal))
+
+	case *ast.LetStatement:
+		if err := checkConst(st, env, node.Name); err != nil {
+			return err
+		}
+		val := eval(st, node.Value, env)
+		if isError(val) {
+			return val
+		}
+		if _, ok := node.Value.(*ast.FunctionLiteral); ok {
+			//let f = fn() {}中的函数以f为名
+			val.(*object.Function).Name = node.Name.Token.Literal
+		}
+		if node.Const {
+			env.SetConst(node.Name.Token.Literal, val)
+		} else {
+			env.Set(node.Name.Token.Literal, val)
+		}
+
 	case *ast.IntLiteral:
 		return &object.Integer{Value: node.Value}
 
@@ -37,59 +126,129 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		return nativeBool2BooleanObject(node.Value)
 
 	case *ast.ArrLiteral:
-		elements := evalExpressions(node.Elements, env)
-		if len(elements) == 1 {
+		elements := evalExpressions(st, node.Elements, env)
+		if len(elements) == 1 && isError(elements[0]) {
 			return elements[0]
 		}
-		return &object.Array{Elements: elements}
+		return st.checkSize(&object.Array{Elements: elements})
 
 	case *ast.MapLiteral:
-		return evalMapLiteral(node, env)
+		return evalMapLiteral(st, node, env)
 
 	case *ast.Identifier:
 		return evalIdentifier(node, env)
 
 	case *ast.IfExpression:
-		return evalIfExpr(node, env)
+		return evalIfExpr(st, node, env)
+
+	case *ast.TryExpression:
+		return evalTryExpr(st, node, env)
+
+	case *ast.MatchExpression:
+		return evalMatchExpr(st, node, env, false)
 
 	case *ast.PrefixExpression:
-		right := Eval(node.Right, env)
+		right := eval(st, node.Right, env)
+		if isError(right) {
+			return right
+		}
 		return evalPrefixExpr(node.Token.Literal, right)
 
 	case *ast.InfixExpression:
-		left := Eval(node.Left, env)
-		right := Eval(node.Right, env)
-		return evalInfixExpr(node.Token.Literal, left, right)
+		left := eval(st, node.Left, env)
+		if isError(left) {
+			return left
+		}
+		right := eval(st, node.Right, env)
+		if isError(right) {
+			return right
+		}
+		return st.checkSize(evalInfixExpr(node.Token.Literal, left, right))
 
 	case *ast.CallExpression:
-		function := Eval(node.Function, env)
-		args := evalExpressions(node.Arguments, env)
-		if len(args) == 1 {
-			return args[0]
+		function := eval(st, node.Function, env)
+		if isError(function) {
+			return function
+		}
+		args, named, err := evalArguments(st, node.Arguments, env)
+		if err != nil {
+			return err
+		}
+		res := applyFunction(st, function, args, named)
+		if st.alloc != nil {
+			st.allocatedBy(node.Token, function, args, res)
 		}
-		return applyFunction(function, args)
+		return res
 
 	case *ast.IndexExpression:
-		left := Eval(node.Left, env)
-		index := Eval(node.Index, env)
+		left := eval(st, node.Left, env)
+		if isError(left) {
+			return left
+		}
+		index := eval(st, node.Index, env)
+		if isError(index) {
+			return index
+		}
 		return evalIndexExpr(left, index)
 
-	case *ast.FunctionLiteral:
-		params := node.Parameters
-		body := node.Body
-		return &object.Function{
-			Parameters: params,
-			Body:       body,
-			Env:        env,
+	case *ast.SelectorExpression:
+		left := eval(st, node.Left, env)
+		if isError(left) {
+			return left
 		}
+		return evalSelectorExpr(left, node.Selector.Token.Literal)
+
+	case *ast.StructLiteral:
+		return evalStructLiteral(st, node, env)
+
+	case *ast.FunctionLiteral:
+		return newFunction(st, node, env, "")
 	}
 	return nil
 }
 
-func evalProgram(program *ast.Program, env *object.Environment) object.Object {
+// 当前作用域中name已是常量时返回错误
+func checkConst(st *state, env *object.Environment, name *ast.Identifier) *object.Error {
+	if !env.IsConst(name.Token.Literal) {
+		return nil
+	}
+	err := newError("cannot reassign constant %s", name)
+	st.locate(err, name.Token)
+	return err
+}
+
+func newFunction(st *state, node *ast.FunctionLiteral, env *object.Environment, name string) *object.Function {
+	return &object.Function{
+		Name:       name,
+		File:       st.file,
+		Parameters: node.Parameters,
+		Defaults:   node.Defaults,
+		Rest:       node.Rest,
+		Body:       node.Body,
+		Env:        env,
+		Token:      node.Token,
+	}
+}
+
+// 顶层的具名函数先于其他语句绑定,所以可以在声明之前调用,也可以互相递归
+func evalProgram(st *state, program *ast.Program, env *object.Environment) object.Object {
 	var res object.Object
 	for _, statement := range program.Statements {
-		res = Eval(statement, env)
+		if fn, ok := statement.(*ast.FunctionStatement); ok {
+			if err := checkConst(st, env, fn.Name); err != nil {
+				return err
+			}
+			env.Set(fn.Name.Token.Literal, newFunction(st, fn.Function, env, fn.Name.Token.Literal))
+		}
+	}
+	for _, statement := range program.Statements {
+		if _, ok := statement.(*ast.FunctionStatement); ok {
+			continue
+		}
+		if err := st.statement(statement, env); err != nil {
+			return err
+		}
+		res = eval(st, statement, env)
 		switch obj := res.(type) {
 		case *object.Return:
 			return obj.Value
@@ -100,10 +259,13 @@ func evalProgram(program *ast.Program, env *object.Environment) object.Object {
 	return res
 }
 
-func evalBlockStatement(block *ast.BlockStatement, env *object.Environment) object.Object {
+func evalBlockStatement(st *state, block *ast.BlockStatement, env *object.Environment) object.Object {
 	var res object.Object
 	for _, statement := range block.Statements {
-		res = Eval(statement, env)
+		if err := st.statement(statement, env); err != nil {
+			return err
+		}
+		res = eval(st, statement, env)
 		if res != nil {
 			typ := res.Type()
 			if typ == object.RETURN || typ == object.ERROR {
@@ -125,48 +287,105 @@ func evalIndexExpr(left, index object.Object) object.Object {
 	}
 }
 
+// m.name读取映射中的字符串键,mod.name读取模块导出的绑定
+func evalSelectorExpr(left object.Object, name string) object.Object {
+	switch left := left.(type) {
+	case *object.Map:
+		return evalMapIndex(left, &object.String{Value: name})
+	case *object.Struct:
+		if val, ok := left.Field(name); ok {
+			return val
+		}
+		return newError("unknown field %s in struct %s", name, left.StructType.Name)
+	case *object.ErrorValue:
+		if val, ok := left.Field(name); ok {
+			return val
+		}
+		return newError("error has no field %s", name)
+	case *object.Module:
+		if val, ok := left.Member(name); ok {
+			return val
+		}
+		return newError("module %s has no exported member %s", left.Name, name)
+	default:
+		return newError("selector not supported: %s", left.Type())
+	}
+}
+
 func evalArrIndex(array object.Object, index object.Object) object.Object {
 	arr := array.(*object.Array)
 	idx := index.(*object.Integer).Value
 	length := int64(len(arr.Elements))
 	if idx < 0 || idx >= length {
-		return newError("index out of range")
+		return Nil
 	}
 	return arr.Elements[idx]
 }
 
 func evalMapIndex(m, index object.Object) object.Object {
 	obj := m.(*object.Map)
-	//将index断言为Hashable,即可哈希
-	i, err := index.(object.Hashable)
-	if !err {
-		return newError("unhashable type : %s", index.Type())
-	}
-	/*
-		Type assertions are used to check that a variable is of some type and return the underlying interface value.
-		Type assertions work only for interfaces.
-		For example, in the following code: 'var x interface{} = 42 t := x.(int)',
-		'x' has the 'interface{}' type with the underlying int value ('42'),
-		'int' is the concrete type that we want to check.
-		If we print 't', the output will be '42'.
-		Changing of the concrete type to 'string' ('t := x.(string)')
-		will cause a runtime panic. Type assertions can return two values.
-		For example, the expression 't, ok := x.(int)' has the boolean 'ok' that
-		returns 'true' if the assertion is correct.
-		If 'ok' is 'false', 't' is set to a zero value and no panic occurs.
-	*/
-	pairs, ok := obj.Mappings[i.Hash()]
+	if !object.IsHashable(index) {
+		return newError("unusable as hash key: %s", index.Type())
+	}
+	pair, ok := obj.Get(index)
 	if !ok {
 		//没找到
 		return Nil
 	}
-	return pairs.Value
+	return pair.Value
 }
 
-func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Object {
+// 按名字传递的实参
+type namedArg struct {
+	name  string
+	value object.Object
+}
+
+// 求值调用的实参,展开...arr,按名传递的实参单独返回
+func evalArguments(st *state, exps []ast.Expression, env *object.Environment) ([]object.Object, []namedArg, object.Object) {
+	var args []object.Object
+	var named []namedArg
+	for _, exp := range exps {
+		switch exp := exp.(type) {
+		case *ast.SpreadExpression:
+			val := eval(st, exp.Value, env)
+			if isError(val) {
+				return nil, nil, val
+			}
+			arr, ok := val.(*object.Array)
+			if !ok {
+				err := newError("cannot spread %s", val.Type())
+				st.locate(err, exp.Token)
+				return nil, nil, err
+			}
+			args = append(args, arr.Elements...)
+		case *ast.NamedArgument:
+			name := exp.Name.Token.Literal
+			for _, arg := range named {
+				if arg.name == name {
+					return nil, nil, newError("argument for parameter %s given more than once", name)
+				}
+			}
+			val := eval(st, exp.Value, env)
+			if isError(val) {
+				return nil, nil, val
+			}
+			named = append(named, namedArg{name: name, value: val})
+		default:
+			val := eval(st, exp, env)
+			if isError(val) {
+				return nil, nil, val
+			}
+			args = append(args, val)
+		}
+	}
+	return args, named, nil
+}
+
+func evalExpressions(st *state, exps []ast.Expression, env *object.Environment) []object.Object {
 	var res []object.Object
 	for _, exp := range exps {
-		evaluated := Eval(exp, env)
+		evaluated := eval(st, exp, env)
 		if isError(evaluated) {
 			return []object.Object{evaluated}
 		}
@@ -184,19 +403,22 @@ func isTruthy(obj object.Object) bool {
 	case Nil:
 		return false
 	default:
-		return false
+		return true
 	}
 }
 
-func evalIfExpr(node *ast.IfExpression, env *object.Environment) object.Object {
-	condf := Eval(node.Condition, env)
+func evalIfExpr(st *state, node *ast.IfExpression, env *object.Environment) object.Object {
+	condf := eval(st, node.Condition, env)
 	if isError(condf) {
 		return condf
 	}
+	if st.branch != nil {
+		st.branch.Branch(st.file, node, isTruthy(condf))
+	}
 	if isTruthy(condf) {
-		return Eval(node.Consequence, env)
+		return eval(st, node.Consequence, env)
 	} else if node.Alternative != nil {
-		return Eval(node.Alternative, env)
+		return eval(st, node.Alternative, env)
 	} else {
 		//如果else语句的Alternative为空
 		return Nil
@@ -228,7 +450,7 @@ func evalBangOperatorExpr(right object.Object) object.Object {
 	case False:
 		return True
 	case Nil:
-		return False
+		return True
 	default:
 		return False
 	}
@@ -239,7 +461,7 @@ func evalMinusOperatorExpr(right object.Object) object.Object {
 		return newError("unknown operator: -%s", right.Type())
 	}
 	val := right.(*object.Integer).Value
-	return &object.Integer{Value: val}
+	return &object.Integer{Value: -val}
 }
 
 func evalInfixExpr(op string, left, right object.Object) object.Object {
@@ -249,11 +471,11 @@ func evalInfixExpr(op string, left, right object.Object) object.Object {
 	case left.Type() == right.Type() && left.Type() == object.STRING:
 		return evalStringInfixExpr(op, left, right)
 	case op == "==":
-		return nativeBool2BooleanObject(left == right)
+		return nativeBool2BooleanObject(object.Equal(left, right))
 	case op == "!=":
-		return nativeBool2BooleanObject(left != right)
+		return nativeBool2BooleanObject(!object.Equal(left, right))
 	case left.Type() != right.Type():
-		return newError("type mismatch :%s %s %s", left.Type(), op, right.Type())
+		return newError("type mismatch: %s %s %s", left.Type(), op, right.Type())
 	default:
 		return newError("unknown operator: %s %s %s", left.Type(), op, right.Type())
 	}
@@ -261,7 +483,7 @@ func evalInfixExpr(op string, left, right object.Object) object.Object {
 
 func evalStringInfixExpr(op string, left, right object.Object) object.Object {
 	if op != "+" {
-		return newError("unknown operator :%s %s %s", left.Type(), op, right.Type())
+		return newError("unknown operator: %s %s %s", left.Type(), op, right.Type())
 	}
 	lft := left.(*object.String).Value
 	rgt := right.(*object.String).Value
@@ -273,28 +495,37 @@ func evalIntInfixExpr(op string, left, right object.Object) object.Object {
 	rgt := right.(*object.Integer).Value
 	switch op {
 	case "+":
-		return &object.Integer{Value: rgt + lft}
+		return &object.Integer{Value: lft + rgt}
 	case "-":
-		return &object.Integer{Value: rgt - lft}
+		return &object.Integer{Value: lft - rgt}
 	case "*":
-		return &object.Integer{Value: rgt * lft}
+		return &object.Integer{Value: lft * rgt}
 	case "/":
-		return &object.Integer{Value: rgt / lft}
+		if rgt == 0 {
+			return newError("division by zero")
+		}
+		return &object.Integer{Value: lft / rgt}
 	case ">":
-		return &object.Boolean{Value: rgt > lft}
+		return nativeBool2BooleanObject(lft > rgt)
 	case "<":
-		return &object.Boolean{Value: rgt < lft}
+		return nativeBool2BooleanObject(lft < rgt)
 	case "==":
-		return &object.Boolean{Value: rgt == lft}
+		return nativeBool2BooleanObject(lft == rgt)
 	case "!=":
-		return &object.Boolean{Value: rgt != lft}
+		return nativeBool2BooleanObject(lft != rgt)
 	default:
 		return newError("unknown operator: %s %s %s", left.Type(), op, right.Type())
 	}
 }
 
 func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object {
-	if val, ok := env.Get(node.Token.Literal); ok {
+	depth := 0
+	if node.Binding != nil {
+		//内层的作用域中没有声明这个名字,不必查找
+		//声明所在的作用域中没有找到时(如只在未执行的分支中绑定)继续向外查找
+		depth = node.Binding.Depth
+	}
+	if val, ok := env.GetFrom(depth, node.Token.Literal); ok {
 		return val
 	}
 	if val, ok := builtins[node.Token.Literal]; ok {
@@ -303,25 +534,144 @@ func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object
 	return newError("identifier not found: %s", node.Token.Literal)
 }
 
-func applyFunction(fn object.Object, args []object.Object) object.Object {
+func applyFunction(st *state, fn object.Object, args []object.Object, named []namedArg) object.Object {
 	switch fn := fn.(type) {
 	case *object.Builtins:
-		return fn.Fn(args...)
+		if len(named) > 0 {
+			return newError("builtin function does not accept named arguments")
+		}
+		var res object.Object
+		if fn.Call != nil {
+			call := func(f object.Object, args ...object.Object) object.Object {
+				return applyFunction(st, f, args, nil)
+			}
+			res = st.checkSize(fn.Call(call, args...))
+		} else {
+			res = st.checkSize(fn.Fn(args...))
+		}
+		if st.trace != nil {
+			st.trace.Builtin(fn, args, res)
+		}
+		return res
 	case *object.Function:
-		extendedEnv := extendFunctionEnv(fn, args)
-		evaluated := Eval(fn.Body, extendedEnv)
-		return unwrapFunctionReturn(evaluated)
+		if err := st.enter(); err != nil {
+			return err
+		}
+		defer st.leave()
+		//蹦床:尾调用在这里循环执行,不增加Go的栈深度
+		caller := st.file
+		for {
+			//默认值和函数体在定义函数的文件中求值
+			st.file = fn.File
+			extendedEnv, err := extendFunctionEnv(st, fn, args, named)
+			if err != nil {
+				st.file = caller
+				return err
+			}
+			if st.hook != nil {
+				st.hook.Call(fn, args, extendedEnv)
+			}
+			evaluated := unwrapFunctionReturn(evalFunctionBody(st, fn.Body, extendedEnv, true))
+			st.file = caller
+			if err, ok := evaluated.(*object.Error); ok {
+				addTraceFrame(err, fn)
+			}
+			tc, ok := evaluated.(*tailCall)
+			if st.hook != nil {
+				if ok {
+					st.hook.Return(fn, nil)
+				} else {
+					st.hook.Return(fn, evaluated)
+				}
+			}
+			if !ok {
+				return evaluated
+			}
+			next, ok := tc.fn.(*object.Function)
+			if !ok {
+				return applyFunction(st, tc.fn, tc.args, tc.named)
+			}
+			fn, args, named = next, tc.args, tc.named
+		}
+	case nil:
+		//宿主通过ApplyFunctionContext传入了nil
+		return newError("not a function: nil")
 	default:
 		return newError("unknown function: %s", fn.Type())
 	}
 }
 
-func extendFunctionEnv(fn *object.Function, args []object.Object) *object.Environment {
+// 先按位置绑定,再按名字绑定,多余的位置实参放入...rest,仍未绑定的参数取默认值
+// 默认值在函数的新环境中求值,可以引用前面的参数
+func extendFunctionEnv(st *state, fn *object.Function, args []object.Object, named []namedArg) (*object.Environment, object.Object) {
 	env := object.NewEnclosedEnvironment(fn.Env)
-	for paramIdx, param := range fn.Parameters {
-		env.Set(param.Token.Literal, args[paramIdx])
+	bound := make([]bool, len(fn.Parameters))
+	for i, arg := range args {
+		if i >= len(fn.Parameters) {
+			break
+		}
+		env.Set(fn.Parameters[i].Token.Literal, arg)
+		bound[i] = true
+	}
+	if len(args) > len(fn.Parameters) && fn.Rest == nil {
+		return nil, newError("too many arguments: got=%d, want at most %d", len(args), len(fn.Parameters))
+	}
+	if fn.Rest != nil {
+		var rest []object.Object
+		if len(args) > len(fn.Parameters) {
+			rest = append(rest, args[len(fn.Parameters):]...)
+		}
+		env.Set(fn.Rest.Token.Literal, &object.Array{Elements: rest})
 	}
-	return env
+	for _, arg := range named {
+		i := paramIndex(fn, arg.name)
+		if i < 0 {
+			return nil, newError("unknown parameter %s", arg.name)
+		}
+		if bound[i] {
+			return nil, newError("argument for parameter %s given more than once", arg.name)
+		}
+		env.Set(arg.name, arg.value)
+		bound[i] = true
+	}
+	for i, param := range fn.Parameters {
+		if bound[i] {
+			continue
+		}
+		if i >= len(fn.Defaults) || fn.Defaults[i] == nil {
+			return nil, newError("missing argument for parameter %s", param)
+		}
+		val := eval(st, fn.Defaults[i], env)
+		if isError(val) {
+			return nil, val
+		}
+		env.Set(param.Token.Literal, val)
+	}
+	return env, nil
+}
+
+// 调用栈很深时只记录最内层的这么多个函数
+const maxTraceFrames = 32
+
+func addTraceFrame(err *object.Error, fn *object.Function) {
+	if len(err.Trace) >= maxTraceFrames {
+		return
+	}
+	name := fn.Name
+	if name == "" {
+		name = "<anonymous>"
+	}
+	tok := fn.Token
+	err.Trace = append(err.Trace, fmt.Sprintf("%s (%d:%d)", name, tok.Line, tok.Column))
+}
+
+func paramIndex(fn *object.Function, name string) int {
+	for i, param := range fn.Parameters {
+		if param.Token.Literal == name {
+			return i
+		}
+	}
+	return -1
 }
 
 func unwrapFunctionReturn(obj object.Object) object.Object {
@@ -331,32 +681,74 @@ func unwrapFunctionReturn(obj object.Object) object.Object {
 	return obj
 }
 
-func evalMapLiteral(m *ast.MapLiteral, env *object.Environment) object.Object {
-	res := make(map[object.HashKey]*object.Pair)
+// Point{x: 1}构造新的结构体,p{x: 1}复制p并更新字段,未给出的字段为null
+func evalStructLiteral(st *state, node *ast.StructLiteral, env *object.Environment) object.Object {
+	typ := eval(st, node.Type, env)
+	if isError(typ) {
+		return typ
+	}
+	var res *object.Struct
+	switch typ := typ.(type) {
+	case *object.StructType:
+		res = &object.Struct{StructType: typ, Values: make([]object.Object, len(typ.Fields))}
+		for i := range res.Values {
+			res.Values[i] = Nil
+		}
+	case *object.Struct:
+		res = &object.Struct{StructType: typ.StructType, Values: append([]object.Object{}, typ.Values...)}
+	default:
+		return newError("not a struct type: %s", typ.Type())
+	}
+	seen := map[string]bool{}
+	for i, field := range node.Fields {
+		name := field.Token.Literal
+		if seen[name] {
+			return newError("duplicate field %s in struct literal", name)
+		}
+		seen[name] = true
+		idx := -1
+		for j, f := range res.StructType.Fields {
+			if f == name {
+				idx = j
+			}
+		}
+		if idx < 0 {
+			return newError("unknown field %s in struct %s", name, res.StructType.Name)
+		}
+		val := eval(st, node.Values[i], env)
+		if isError(val) {
+			return val
+		}
+		res.Values[idx] = val
+	}
+	return res
+}
+
+func evalMapLiteral(st *state, m *ast.MapLiteral, env *object.Environment) object.Object {
+	res := object.NewMap()
 	for keyNode, valNode := range m.Pairs {
-		key := Eval(keyNode, env)
+		key := eval(st, keyNode, env)
 		if isError(key) {
 			return key
 		}
-		hashKey, ok := key.(object.Hashable)
-		if !ok {
-			return newError("unhashable type as hash key: %s", key.Type())
+		if !object.IsHashable(key) {
+			return newError("unusable as hash key: %s", key.Type())
 		}
-		val := Eval(valNode, env)
+		val := eval(st, valNode, env)
 		if isError(val) {
 			return val
 		}
-		hashed := hashKey.Hash()
-		res[hashed] = &object.Pair{
-			Key:   key,
-			Value: val,
-		}
+		res.Set(key, val)
 	}
-	return &object.Map{Mappings: res}
+	return st.checkSize(res)
 }
 
 func newError(format string, a ...any) *object.Error {
-	return &object.Error{Msg: fmt.Sprintf(format, a...)}
+	return &object.Error{Kind: object.RUNTIME_ERROR, Msg: fmt.Sprintf(format, a...)}
+}
+
+func newLimitError(kind object.ErrorKind, format string, a ...any) *object.Error {
+	return &object.Error{Kind: kind, Msg: fmt.Sprintf(format, a...)}
 }
 
 func isError(obj object.Object) bool {
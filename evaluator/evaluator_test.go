@@ -1,10 +1,13 @@
 package builtins
 
 import (
+	"context"
 	"my-interpreter/lexer"
 	"my-interpreter/object"
 	"my-interpreter/parser"
+	"strings"
 	"testing"
+	"time"
 )
 
 func testEval(input string) object.Object {
@@ -322,6 +325,11 @@ func TestFunctionApplication(t *testing.T) {
 	for _, tt := range tests {
 		testIntegerObject(t, testEval(tt.input), tt.expected)
 	}
+
+	res := ApplyFunctionContext(context.Background(), nil, nil, Config{})
+	if errObj, ok := res.(*object.Error); !ok || errObj.Msg != "not a function: nil" {
+		t.Errorf("applying nil: expected an error. got=%T(%+v)", res, res)
+	}
 }
 
 func TestEnclosingEnvironments(t *testing.T) {
@@ -435,6 +443,30 @@ func TestBuiltinFunctions(t *testing.T) {
 	}
 }
 
+func TestBuiltinSignatures(t *testing.T) {
+	for _, name := range BuiltinNames() {
+		sig, ok := BuiltinSignature(name)
+		if !ok {
+			t.Errorf("builtin %s has no signature", name)
+			continue
+		}
+		if sig.MinArgs > 0 {
+			//少于MinArgs个参数时应报告参数个数错误
+			args := make([]object.Object, sig.MinArgs-1)
+			res, ok := builtins[name].Fn(args...).(*object.Error)
+			if !ok || !strings.HasPrefix(res.Msg, "wrong number of arguments") {
+				t.Errorf("%s%s: expected arity error with %d args. got=%v", name, sig, len(args), res)
+			}
+		}
+	}
+	//signatures和builtins分开维护,两者的键必须相同
+	for name := range signatures {
+		if _, ok := builtins[name]; !ok {
+			t.Errorf("signature for unknown builtin %s", name)
+		}
+	}
+}
+
 func TestArrayLiterals(t *testing.T) {
 	input := `[1, 2 * 2, 3 + 3]`
 
@@ -597,3 +629,577 @@ func TestHashIndexExpressions(t *testing.T) {
 		}
 	}
 }
+
+func TestLimits(t *testing.T) {
+	const exponential = `let f = fn(n) { if (n == 0) { 0 } else { f(n - 1) + f(n - 1) } }; f(40);`
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	tests := []struct {
+		input    string
+		ctx      context.Context
+		limits   Limits
+		expected object.ErrorKind
+	}{
+		{"let f = fn(x) { 1 + f(x + 1) }; f(0);", context.Background(), Limits{MaxDepth: 100}, object.DEPTH_LIMIT},
+		{exponential, context.Background(), Limits{MaxSteps: 1000}, object.STEP_LIMIT},
+		{exponential, context.Background(), Limits{Timeout: 20 * time.Millisecond}, object.TIMEOUT},
+		{exponential, canceled, Limits{}, object.CANCELED},
+		{"[1, 2, 3, 4]", context.Background(), Limits{MaxCollection: 3}, object.SIZE_LIMIT},
+		{"push([1, 2, 3], 4)", context.Background(), Limits{MaxCollection: 3}, object.SIZE_LIMIT},
+		{`"ab" + "cd"`, context.Background(), Limits{MaxCollection: 3}, object.SIZE_LIMIT},
+		{`{1: 1, 2: 2, 3: 3, 4: 4}`, context.Background(), Limits{MaxCollection: 3}, object.SIZE_LIMIT},
+		{"1 + true", context.Background(), Limits{}, object.RUNTIME_ERROR},
+	}
+	for _, tt := range tests {
+		program := parser.NewParser(lexer.NewLexer(tt.input)).ParseProgram()
+		evaluated := EvalContext(tt.ctx, program, object.NewEnvironment(), Config{Limits: tt.limits})
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("%q: no error object returned. got=%T(%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if errObj.Kind != tt.expected {
+			t.Errorf("%q: wrong error kind. expected=%s, got=%s (%s)", tt.input, tt.expected, errObj.Kind, errObj.Msg)
+		}
+	}
+
+	//未超出限制时结果不受影响
+	program := parser.NewParser(lexer.NewLexer("let f = fn(n) { if (n == 0) { 0 } else { 1 + f(n - 1) } }; f(50);")).ParseProgram()
+	testIntegerObject(t, EvalContext(context.Background(), program, object.NewEnvironment(), Config{Limits: Limits{MaxDepth: 51, MaxSteps: 10000}}), 50)
+}
+
+func TestTailCalls(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{`let countdown = fn(n) { if (n == 0) { 0 } else { countdown(n - 1) } }; countdown(100000);`, 0},
+		{`let sum = fn(n, acc) { if (n == 0) { return acc; } return sum(n - 1, acc + n); }; sum(100000, 0);`, 5000050000},
+		{`let isEven = fn(n) { if (n == 0) { true } else { isOdd(n - 1) } };
+let isOdd = fn(n) { if (n == 0) { false } else { isEven(n - 1) } };
+if (isEven(100001)) { 1 } else { 2 }`, 2},
+		{`let f = fn(n) {
+	if (n > 0) {
+		return f(n - 1);
+	}
+	let g = fn(x) { x * 2 };
+	g(21)
+};
+f(100000)`, 42},
+		//尾位置上调用内置函数
+		{`let f = fn(arr) { len(arr) }; f([1, 2, 3])`, 3},
+		//不在尾位置的调用照常返回
+		{`let f = fn(n) { if (n == 0) { 0 } else { 1 + f(n - 1) } }; f(100)`, 100},
+	}
+	for _, tt := range tests {
+		program := parser.NewParser(lexer.NewLexer(tt.input)).ParseProgram()
+		//尾调用不增加调用深度,深度限制远小于递归次数也能完成
+		evaluated := EvalContext(context.Background(), program, object.NewEnvironment(), Config{Limits: Limits{MaxDepth: 101}})
+		testIntegerObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestSelectorExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected any
+	}{
+		{`{"foo": 5}.foo`, 5},
+		{`{"foo": 5}.bar`, nil},
+		{`let config = {"a": {"b": {"c": {"d": 4}}}}; config.a.b.c.d`, 4},
+		{`let config = {"a": {"b": [1, 2, 3]}}; config.a.b[1]`, 2},
+		{`let point = {"x": 3, "norm": fn(p) { p.x * p.x }}; point.norm(point)`, 9},
+		{`let counter = {"add": fn(a, b) { a + b }}; counter.add(1, 2) * 2`, 6},
+		{`{"f": fn() { {"g": fn() { 7 }} }}.f().g()`, 7},
+		{`{1: 5}.x`, nil},
+		{`let m = {"a": 1}; m.a.b`, "selector not supported: INTEGER"},
+		{`[1, 2].len`, "selector not supported: ARRAY"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok || errObj.Msg != expected {
+				t.Errorf("%q: expected error %q. got=%T(%+v)", tt.input, expected, evaluated, evaluated)
+			}
+		default:
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestStructs(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected any
+	}{
+		{`struct Point { x, y }; let p = Point{x: 1, y: 2}; p.x + p.y`, 3},
+		{`struct Point { x, y }; Point{y: 2}.x`, nil},
+		{`struct Point { x, y }; let p = Point{x: 1, y: 2}; let q = p{y: 5}; p.y * 10 + q.y`, 25},
+		{`struct Point { x, y }; Point{x: 1, y: 2} == Point{x: 1, y: 2}`, true},
+		{`struct Point { x, y }; Point{x: 1, y: 2} == Point{x: 1, y: 3}`, false},
+		{`struct Point { x, y }; Point{x: 1, y: 2} != Point{x: 1, y: 3}`, true},
+		{`struct A { v }; struct B { v }; A{v: 1} == B{v: 1}`, false},
+		{`struct Line { from, to }; struct Point { x, y };
+let l = Line{from: Point{x: 0, y: 0}, to: Point{x: 3, y: 4}};
+l == Line{from: Point{x: 0, y: 0}, to: Point{x: 3, y: 4}}`, true},
+		{`struct Point { x, y }; Point{x: 1, z: 2}`, "unknown field z in struct Point"},
+		{`struct Point { x, y }; Point{x: 1}.z`, "unknown field z in struct Point"},
+		{`struct Point { x, y }; let p = Point{}; p{w: 1}`, "unknown field w in struct Point"},
+		{`struct Point { x, y }; Point{x: 1, x: 2}`, "duplicate field x in struct literal"},
+		{`let p = 5; p{x: 1}`, "not a struct type: INTEGER"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok || errObj.Msg != expected {
+				t.Errorf("%q: expected error %q. got=%T(%+v)", tt.input, expected, evaluated, evaluated)
+			}
+		default:
+			testNullObject(t, evaluated)
+		}
+	}
+
+	evaluated := testEval(`struct Point { x, y }; Point{y: "b", x: 1}`)
+	if evaluated.Inspect() != `Point{x: 1, y: b}` {
+		t.Errorf("Inspect() wrong. got=%q", evaluated.Inspect())
+	}
+	evaluated = testEval(`struct Point { x, y }; Point`)
+	if evaluated.Inspect() != `struct Point { x, y }` {
+		t.Errorf("Inspect() wrong. got=%q", evaluated.Inspect())
+	}
+}
+
+func TestStructuralEquality(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected any
+	}{
+		{`[1, 2] == [1, 2]`, true},
+		{`[1, [2, "a"]] == [1, [2, "a"]]`, true},
+		{`[1, 2] == [2, 1]`, false},
+		{`[1, 2] != [1, 2, 3]`, true},
+		{`{"a": [1], "b": 2} == {"b": 2, "a": [1]}`, true},
+		{`{"a": 1} == {"a": 2}`, false},
+		{`(1 < 2) == true`, true},
+		{`1 == "1"`, false},
+		{`let f = fn() { 1 }; f == f`, true},
+		{`fn() { 1 } == fn() { 1 }`, false},
+		{`let m = {[1, 2]: "pair", [1]: "one"}; m[[1, 2]]`, "pair"},
+		{`let m = {[1, 2]: "pair"}; m[[2, 1]]`, nil},
+		{`let m = {[[1], "a"]: 5}; m[[[1], "a"]]`, 5},
+		{`{[1, {}]: 1}`, "unusable as hash key: ARRAY"},
+		{`{"a": 1}[[fn() { 1 }]]`, "unusable as hash key: ARRAY"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			if str, ok := evaluated.(*object.String); ok {
+				if str.Value != expected {
+					t.Errorf("%q: expected %q. got=%q", tt.input, expected, str.Value)
+				}
+				continue
+			}
+			errObj, ok := evaluated.(*object.Error)
+			if !ok || errObj.Msg != expected {
+				t.Errorf("%q: expected error %q. got=%T(%+v)", tt.input, expected, evaluated, evaluated)
+			}
+		default:
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestTryCatch(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected any
+	}{
+		{`try { 1 } catch (e) { 2 }`, 1},
+		{`try { 1 + true } catch (e) { 2 }`, 2},
+		{`try { throw 5 } catch (e) { e + 1 }`, 6},
+		{`try { throw error("bad", 7) } catch (e) { e.data }`, 7},
+		{`try { throw error("bad") } catch (e) { e.message }`, "bad"},
+		{`try { throw error("bad") } catch (e) { e.kind }`, "USER_ERROR"},
+		{`try { [1][true] } catch (e) { e.kind }`, "RUNTIME_ERROR"},
+		{`try { len(1) } catch (e) { e.message }`, "argument to `len` not supported, got INTEGER"},
+		{`try { throw error("x") } catch { 3 }`, 3},
+		{`let r = []; try { 1 } finally { let r = [1] }; len(r)`, 1},
+		{`try { throw 1 } catch (e) { 2 } finally { 3 }`, 2},
+		{`try { throw 1 } finally { 3 }`, "uncaught exception: 1"},
+		{`try { 1 } finally { 1 + true }`, "type mismatch: INTEGER + BOOLEAN"},
+		{`try { 1 } catch (e) { 2 }; e`, "identifier not found: e"},
+		{`let f = fn() { try { return 1 } finally { 2 }; 3 }; f()`, 1},
+		{`let f = fn() { try { 1 } finally { return 2 } }; f()`, 2},
+		{`let f = fn(x) { if (x == 0) { throw error("zero") } 10 / x }; try { f(0) } catch (e) { e.message }`, "zero"},
+		{`try { try { throw 1 } catch (e) { throw e + 1 } } catch (e) { e }`, 2},
+		{`try { throw error("a") } catch (e) { try { throw e } catch (inner) { inner == e } }`, true},
+		{`let total = 0; let safe = fn(x) { try { 10 / x } catch (e) { 0 } };
+let sum = fn(arr, acc) { if (len(arr) == 0) { acc } else { sum(pop(arr), acc + safe(last(arr))) } };
+sum([1, "a", 2], 0)`, 15},
+		{`throw error("top")`, "top"},
+		{`error(1)`, "argument to `error` must be STRING, got INTEGER"},
+		{`try { 1 } catch (e) { 2 }.foo`, "selector not supported: INTEGER"},
+		{`try { throw error("a") } catch (e) { e.foo }`, "error has no field foo"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			if str, ok := evaluated.(*object.String); ok {
+				if str.Value != expected {
+					t.Errorf("%q: expected %q. got=%q", tt.input, expected, str.Value)
+				}
+				continue
+			}
+			errObj, ok := evaluated.(*object.Error)
+			if !ok || errObj.Msg != expected {
+				t.Errorf("%q: expected error %q. got=%T(%+v)", tt.input, expected, evaluated, evaluated)
+			}
+		}
+	}
+}
+
+func TestAssertions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected any
+	}{
+		{`assert(true); assertEq([1, {"a": 2}], [1, {"a": 2}]); 1`, 1},
+		{`assert(1 > 2)`, "assertion failed"},
+		{`assert(false, "must hold")`, "must hold: assertion failed"},
+		{`assertEq(1 + 1, 3)`, "values are not equal (-want +got):\n- 3\n+ 2"},
+		{`assertEq(1, "1", "types")`, "types: values are not equal (-want +got):\n- 1 (STRING)\n+ 1 (INTEGER)"},
+		{`try { assert(false) } catch (e) { e.kind }`, "ASSERTION_ERROR"},
+		{`assertThrows(fn() { throw error("boom") }).message`, "boom"},
+		{`assertThrows(fn() { 1 + true }, "mismatch").kind`, "RUNTIME_ERROR"},
+		{`assertThrows(fn() { assert(false) }).kind`, "ASSERTION_ERROR"},
+		{`assertThrows(fn() { 1 })`, "expected an error to be thrown"},
+		{`assertThrows(fn() { throw error("boom") }, "bang")`, `expected an error containing "bang", got "boom"`},
+		{`assertThrows(1)`, "argument to `assertThrows` must be FUNCTION, got INTEGER"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			if str, ok := evaluated.(*object.String); ok {
+				if str.Value != expected {
+					t.Errorf("%q: expected %q. got=%q", tt.input, expected, str.Value)
+				}
+				continue
+			}
+			errObj, ok := evaluated.(*object.Error)
+			if !ok || errObj.Msg != expected {
+				t.Errorf("%q: expected error %q. got=%T(%+v)", tt.input, expected, evaluated, evaluated)
+			}
+		}
+	}
+
+	//超出资源限制不算抛出错误
+	program := parser.NewParser(lexer.NewLexer(`let f = fn(x) { 1 + f(x) }; assertThrows(fn() { f(1) })`)).ParseProgram()
+	res := EvalContext(context.Background(), program, object.NewEnvironment(), Config{Limits: Limits{MaxDepth: 20}})
+	if errObj, ok := res.(*object.Error); !ok || errObj.Kind != object.DEPTH_LIMIT {
+		t.Errorf("expected DEPTH_LIMIT error. got=%T(%+v)", res, res)
+	}
+}
+
+func TestDiffLines(t *testing.T) {
+	got := diffLines("a\nb\nc\nd", "a\nc\nx\nd")
+	want := "  a\n- b\n  c\n+ x\n  d"
+	if got != want {
+		t.Errorf("diff:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestErrorPositions(t *testing.T) {
+	tests := []struct {
+		input        string
+		line, column int
+	}{
+		{"let a = 1;\nlet b = a + true;", 2, 11},
+		{"let f = fn() {\n  throw error(\"x\")\n};\nf()", 2, 3},
+		{"let f = fn() { len(1) };\n\nf()", 1, 19},
+		{"[1, 2][\"a\"]", 1, 7},
+		{"x", 1, 1},
+	}
+	for _, tt := range tests {
+		errObj, ok := testEval(tt.input).(*object.Error)
+		if !ok {
+			t.Errorf("%q: expected error", tt.input)
+			continue
+		}
+		if errObj.Line != tt.line || errObj.Column != tt.column {
+			t.Errorf("%q: expected position %d:%d. got=%d:%d", tt.input, tt.line, tt.column, errObj.Line, errObj.Column)
+		}
+	}
+
+	caught := testEval("try {\n  1 + true\n} catch (e) { [e.line, e.column] }")
+	arr, ok := caught.(*object.Array)
+	if !ok || len(arr.Elements) != 2 {
+		t.Fatalf("expected array. got=%T(%+v)", caught, caught)
+	}
+	testIntegerObject(t, arr.Elements[0], 2)
+	testIntegerObject(t, arr.Elements[1], 5)
+}
+
+func TestLimitErrorsNotCaught(t *testing.T) {
+	program := parser.NewParser(lexer.NewLexer(
+		`let f = fn(x) { 1 + f(x) }; try { f(1) } catch (e) { 0 } finally { 0 }`)).ParseProgram()
+	cfg := Config{Limits: Limits{MaxDepth: 20}}
+	res := EvalContext(context.Background(), program, object.NewEnvironment(), cfg)
+	errObj, ok := res.(*object.Error)
+	if !ok || errObj.Kind != object.DEPTH_LIMIT {
+		t.Errorf("expected DEPTH_LIMIT error. got=%T(%+v)", res, res)
+	}
+}
+
+func TestMatchExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected any
+	}{
+		{`match (2) { 1 => "one", 2 => "two", _ => "many" }`, "two"},
+		{`match (5) { 1 => "one", _ => "many" }`, "many"},
+		{`match (-1) { -1 => "neg", _ => "other" }`, "neg"},
+		{`match ("a") { "a" => 1, _ => 2 }`, 1},
+		{`match (true) { false => 0, true => 1 }`, 1},
+		{`match (7) { n => n * 2 }`, 14},
+		{`match (7) { n if n > 10 => "big", n if n > 5 => "medium", _ => "small" }`, "medium"},
+		{`match ([1, 2, 3]) { [] => 0, [x] => x, [x, ...rest] => len(rest) }`, 2},
+		{`match ([1, 2]) { [a, b, c] => 3, [a, b] => a + b }`, 3},
+		{`match ([1]) { [_, ...] => "nonempty", [] => "empty" }`, "nonempty"},
+		{`match ([[1, 2], 3]) { [[a, b], c] => a + b + c }`, 6},
+		{`match ({"op": "+", "l": 1, "r": 2, "extra": 0}) {
+			{"op": "-", "l": l, "r": r} => l - r,
+			{"op": "+", "l": l, "r": r} => l + r
+		}`, 3},
+		{`match ({"a": 1}) { {"b": _} => 1, {} => 2 }`, 2},
+		{`match (1) { {} => 1, [] => 2, _ => 3 }`, 3},
+		{`let sum = fn(arr) { match (arr) { [] => 0, [h, ...t] => h + sum(t) } }; sum([1, 2, 3, 4])`, 10},
+		{`let count = fn(arr, acc) { match (arr) { [] => acc, [_, ...t] => count(t, acc + 1) } }; count([1, 2, 3], 0)`, 3},
+		{`let eval = fn(node) { match (node) {
+			{"num": n} => n,
+			{"op": "+", "args": [a, b]} => eval(a) + eval(b),
+			{"op": "*", "args": [a, b]} => eval(a) * eval(b)
+		} };
+		eval({"op": "+", "args": [{"num": 1}, {"op": "*", "args": [{"num": 2}, {"num": 3}]}]})`, 7},
+		{`let x = 1; match (5) { x => x }; x`, 1},
+		{`match (3) { 1 => "one" }`, "no match arm for value 3"},
+		{`match (1 + true) { _ => 1 }`, "type mismatch: INTEGER + BOOLEAN"},
+		{`match (1) { n if n + true => 1 }`, "type mismatch: INTEGER + BOOLEAN"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			if str, ok := evaluated.(*object.String); ok {
+				if str.Value != expected {
+					t.Errorf("%q: expected %q. got=%q", tt.input, expected, str.Value)
+				}
+				continue
+			}
+			errObj, ok := evaluated.(*object.Error)
+			if !ok || errObj.Msg != expected {
+				t.Errorf("%q: expected %q. got=%T(%+v)", tt.input, expected, evaluated, evaluated)
+			}
+		}
+	}
+
+	program := parser.NewParser(lexer.NewLexer(
+		`let count = fn(n) { match (n) { 0 => 0, _ => count(n - 1) } }; count(500)`)).ParseProgram()
+	res := EvalContext(context.Background(), program, object.NewEnvironment(), Config{Limits: Limits{MaxDepth: 50}})
+	testIntegerObject(t, res, 0)
+}
+
+func TestFunctionArguments(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected any
+	}{
+		{`let f = fn(x, y = 10) { x + y }; f(1)`, 11},
+		{`let f = fn(x, y = 10) { x + y }; f(1, 2)`, 3},
+		{`let f = fn(x, y = x * 2) { x + y }; f(3)`, 9},
+		{`let n = 0; let f = fn(x = [n]) { x }; let a = f(); len(a)`, 1},
+		{`let f = fn(head, ...rest) { len(rest) }; f(1, 2, 3)`, 2},
+		{`let f = fn(head, ...rest) { len(rest) }; f(1)`, 0},
+		{`let f = fn(...all) { all }; f(1, 2) == [1, 2]`, true},
+		{`let f = fn(a, b, c) { a * 100 + b * 10 + c }; f(...[1, 2, 3])`, 123},
+		{`let f = fn(a, b, c) { a * 100 + b * 10 + c }; f(1, ...[2], ...[3])`, 123},
+		{`len(...[[1, 2]])`, 2},
+		{`let f = fn(x, y = 10, z = 20) { x + y + z }; f(1, z: 2)`, 13},
+		{`let f = fn(x, y) { x - y }; f(y: 1, x: 5)`, 4},
+		{`let f = fn(x, ...rest) { x + len(rest) }; f(...[1, 2, 3])`, 3},
+		{`let wrap = fn(g) { fn(...args) { g(...args) * 2 } }; let add = fn(a, b = 1) { a + b }; wrap(add)(3)`, 8},
+		{`let f = fn(x, y) { x }; f(1)`, "missing argument for parameter y"},
+		{`let f = fn(x) { x }; f(1, 2)`, "too many arguments: got=2, want at most 1"},
+		{`let f = fn(x) { x }; f(1, x: 2)`, "argument for parameter x given more than once"},
+		{`let f = fn(x, y = 1) { x }; f(1, y: 2, y: 3)`, "argument for parameter y given more than once"},
+		{`let f = fn(x) { x }; f(z: 2)`, "unknown parameter z"},
+		{`let f = fn(x, ...rest) { x }; f(1, rest: 2)`, "unknown parameter rest"},
+		{`let f = fn(x) { x }; f(...1)`, "cannot spread INTEGER"},
+		{`len(x: 1)`, "builtin function does not accept named arguments"},
+		{`let f = fn(x = 1 + true) { x }; f()`, "type mismatch: INTEGER + BOOLEAN"},
+		{`let f = fn(x) { if (x == 0) { 0 } else { f(x - 1, 1) } }; f(2)`, "too many arguments: got=2, want at most 1"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok || errObj.Msg != expected {
+				t.Errorf("%q: expected error %q. got=%T(%+v)", tt.input, expected, evaluated, evaluated)
+			}
+		}
+	}
+
+	fn := testEval(`fn(x, y = 10, ...rest) { x }`)
+	if fn.Inspect() != "fn(x, y = 10, ...rest) {\n\tx;\n}" {
+		t.Errorf("Inspect() wrong. got=%q", fn.Inspect())
+	}
+}
+
+func TestFunctionStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected any
+	}{
+		{`fn add(x, y) { x + y }; add(1, 2)`, 3},
+		{`let r = twice(4); fn twice(x) { x * 2 }; r`, 8},
+		{`fn isEven(n) { if (n == 0) { true } else { isOdd(n - 1) } }
+fn isOdd(n) { if (n == 0) { false } else { isEven(n - 1) } }
+isEven(10)`, true},
+		{`fn fact(n) { if (n < 2) { 1 } else { n * fact(n - 1) } }; fact(5)`, 120},
+		{`let f = fn() { fn inner() { 5 }; inner() }; f()`, 5},
+		{`let f = fn() { inner(); fn inner() { 5 } }; f()`, "identifier not found: inner"},
+		{`fn f() { 1 }`, nil},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok || errObj.Msg != expected {
+				t.Errorf("%q: expected error %q. got=%T(%+v)", tt.input, expected, evaluated, evaluated)
+			}
+		default:
+			if evaluated != nil {
+				t.Errorf("%q: expected no value. got=%T(%+v)", tt.input, evaluated, evaluated)
+			}
+		}
+	}
+
+	names := []struct {
+		input    string
+		expected string
+	}{
+		{`fn add(x, y) { x + y }; add`, "fn add(x, y) {\n\t(x + y);\n}"},
+		{`let sub = fn(x) { x }; sub`, "fn sub(x) {\n\tx;\n}"},
+		{`fn(x) { x }`, "fn(x) {\n\tx;\n}"},
+	}
+	for _, tt := range names {
+		if got := testEval(tt.input).Inspect(); got != tt.expected {
+			t.Errorf("Inspect() wrong. expected=%q, got=%q", tt.expected, got)
+		}
+	}
+
+	errObj, ok := testEval("fn outer() { 1 + inner() }\nfn inner() {\n  1 + true\n}\nlet run = fn() { 1 + outer() }; run()").(*object.Error)
+	if !ok {
+		t.Fatalf("expected error")
+	}
+	expected := []string{"inner (2:1)", "outer (1:1)", "run (5:11)"}
+	if strings.Join(errObj.Trace, ", ") != strings.Join(expected, ", ") {
+		t.Errorf("trace wrong. expected=%q, got=%q", expected, errObj.Trace)
+	}
+}
+
+func TestConstAndFreeze(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected any
+	}{
+		{`const a = 5; a * 2`, 10},
+		{`const a = 5; let f = fn() { let a = 1; a }; f() + a`, 6},
+		{`const a = 5; if (true) { let a = 2 }; a`, "cannot reassign constant a"},
+		{`isFrozen([1])`, false},
+		{`isFrozen(freeze([1]))`, true},
+		{`isFrozen(freeze({"a": [1]})["a"])`, true},
+		{`isFrozen(freeze([{"a": 1}])[0])`, true},
+		{`struct P { xs }; isFrozen(freeze(P{xs: [1]}).xs)`, true},
+		{`isFrozen(1)`, true},
+		{`isFrozen("s")`, true},
+		{`struct P { x }; let p = freeze(P{x: 1}); p{x: 2}.x + p.x`, 3},
+		{`struct P { x }; let p = freeze(P{x: 1}); isFrozen(p{x: 2})`, false},
+		{`freeze([1, [2]]) == [1, [2]]`, true},
+		{`let m = freeze({"k": 1}); m["k"]`, 1},
+		{`isFrozen(push(freeze([1]), 2))`, false},
+		{`freeze()`, "wrong number of arguments. got=0, want=1"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok || errObj.Msg != expected {
+				t.Errorf("%q: expected error %q. got=%T(%+v)", tt.input, expected, evaluated, evaluated)
+			}
+		}
+	}
+
+	//跨多次求值的重新绑定只能在运行时发现,如REPL中的多次输入
+	env := object.NewEnvironment()
+	for _, input := range []string{`const a = 1;`, `let a = 2;`, `fn a() { 1 }`, `struct a { x }`} {
+		program := parser.NewParser(lexer.NewLexer(input)).ParseProgram()
+		res := Eval(program, env)
+		if input == `const a = 1;` {
+			continue
+		}
+		errObj, ok := res.(*object.Error)
+		if !ok || errObj.Msg != "cannot reassign constant a" {
+			t.Errorf("%q: expected error. got=%T(%+v)", input, res, res)
+		}
+	}
+	val, _ := env.Get("a")
+	testIntegerObject(t, val, 1)
+}
+
+func TestTypeAnnotationsIgnored(t *testing.T) {
+	input := `let x: int = 5;
+fn double(a: int) -> int { a * 2 }
+let g: fn(int) -> int = fn(n: int = 1, ...r: [int]) -> int { n + len(r) };
+double(x) + g(1, 2, 3)`
+	testIntegerObject(t, testEval(input), 13)
+}
@@ -0,0 +1,112 @@
+package builtins
+
+import (
+	"my-interpreter/ast"
+	"my-interpreter/object"
+	"my-interpreter/token"
+)
+
+// 带有位置信息的节点的token
+func nodeToken(node ast.Node) (token.Token, bool) {
+	switch node := node.(type) {
+	case *ast.Identifier:
+		return node.Token, true
+	case *ast.PrefixExpression:
+		return node.Token, true
+	case *ast.InfixExpression:
+		return node.Token, true
+	case *ast.CallExpression:
+		return node.Token, true
+	case *ast.IndexExpression:
+		return node.Token, true
+	case *ast.SelectorExpression:
+		return node.Token, true
+	case *ast.StructLiteral:
+		return node.Token, true
+	case *ast.ThrowStatement:
+		return node.Token, true
+	case *ast.ImportStatement:
+		//找不到模块,循环导入等错误
+		return node.Token, true
+	}
+	return token.Token{}, false
+}
+
+// 记下错误发生的位置
+func (st *state) locate(err *object.Error, tok token.Token) {
+	if tok.Line == 0 {
+		return
+	}
+	err.File, err.Line, err.Column = st.file, tok.Line, tok.Column
+	if st.trace != nil {
+		st.trace.Error(err)
+	}
+}
+
+// throw error(...)抛出的错误保留其消息,抛出其他值时消息为值的Inspect()
+func evalThrowStatement(st *state, node *ast.ThrowStatement, env *object.Environment) object.Object {
+	val := eval(st, node.Value, env)
+	if isError(val) {
+		return val
+	}
+	err := &object.Error{Kind: object.USER_ERROR, Msg: "uncaught exception: " + val.Inspect(), Value: val}
+	if ev, ok := val.(*object.ErrorValue); ok {
+		err.Msg = ev.Msg
+		if ev.Line == 0 {
+			//第一次抛出时记下位置,重新抛出捕获的错误时保留原来的位置
+			ev.File, ev.Line, ev.Column = st.file, node.Token.Line, node.Token.Column
+		}
+		err.File, err.Line, err.Column = ev.File, ev.Line, ev.Column
+		if st.trace != nil {
+			//已经有位置,不经过locate
+			st.trace.Error(err)
+		}
+	}
+	return err
+}
+
+// 超时,取消和资源限制不能被捕获,也不执行finally,其余错误交给catch处理
+// finally总会执行,其中出错或return时以finally的结果为准
+func evalTryExpr(st *state, node *ast.TryExpression, env *object.Environment) object.Object {
+	res := eval(st, node.Body, env)
+	if err, ok := res.(*object.Error); ok {
+		if !err.Catchable() {
+			return err
+		}
+		if node.Catch != nil {
+			catchEnv := object.NewEnclosedEnvironment(env)
+			if node.Param != nil {
+				catchEnv.Set(node.Param.Token.Literal, caughtValue(err))
+			}
+			res = eval(st, node.Catch, catchEnv)
+		}
+	}
+	if node.Finally != nil {
+		fin := eval(st, node.Finally, env)
+		if err, ok := fin.(*object.Error); ok {
+			return err
+		}
+		if ret, ok := fin.(*object.Return); ok {
+			return ret
+		}
+	}
+	if res == nil {
+		return Nil
+	}
+	return res
+}
+
+// catch绑定的值:throw抛出的原值,或者由内部错误转换成的ErrorValue
+func caughtValue(err *object.Error) object.Object {
+	if err.Value != nil {
+		return err.Value
+	}
+	return &object.ErrorValue{
+		Kind:   err.Kind,
+		Msg:    err.Msg,
+		Data:   Nil,
+		File:   err.File,
+		Line:   err.Line,
+		Column: err.Column,
+	}
+}
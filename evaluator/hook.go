@@ -0,0 +1,99 @@
+package builtins
+
+import (
+	"my-interpreter/ast"
+	"my-interpreter/object"
+	"my-interpreter/token"
+)
+
+// 求值的钩子,供调试器等工具观察求值过程,Config.Hook为nil时不调用
+type Hook interface {
+	// 每条语句求值之前调用,file为正在求值的文件,env为求值语句的环境
+	// 返回错误时停止求值,该错误作为求值结果
+	Statement(file string, stmt ast.Statement, env *object.Environment) *object.Error
+	// 调用脚本函数,env为绑定了参数的新环境
+	Call(fn *object.Function, args []object.Object, env *object.Environment)
+	// 脚本函数返回,尾调用另一个脚本函数时result为nil,随后是被调函数的Call
+	Return(fn *object.Function, result object.Object)
+}
+
+func (st *state) statement(stmt ast.Statement, env *object.Environment) *object.Error {
+	if st.hook == nil {
+		return nil
+	}
+	return st.hook.Statement(st.file, stmt, env)
+}
+
+// 还需要观察对象分配的钩子,Config.Hook实现了该接口时才调用Alloc
+type AllocHook interface {
+	Hook
+	// 求值site处的表达式新创建了obj
+	Alloc(obj object.Object, site token.Token)
+}
+
+// 还需要观察分支的钩子,Config.Hook实现了该接口时才调用Branch
+type BranchHook interface {
+	Hook
+	// if表达式求值了条件,consequence为false时选择else分支,没有else分支时也算
+	Branch(file string, node *ast.IfExpression, consequence bool)
+}
+
+// 还需要跟踪内置函数调用和错误的钩子,Config.Hook实现了该接口时才调用Builtin和Error
+type TraceHook interface {
+	Hook
+	// 内置函数返回了result
+	Builtin(fn *object.Builtins, args []object.Object, result object.Object)
+	// 产生了新的错误,包括throw,每个错误只报告一次,通常已经记下了位置
+	Error(err *object.Error)
+}
+
+// 求值的最终结果,没有位置的错误在这里报告给TraceHook
+func (st *state) result(res object.Object) object.Object {
+	if err, ok := res.(*object.Error); st.trace != nil && ok && err.Line == 0 {
+		st.trace.Error(err)
+	}
+	return res
+}
+
+// 会创建新对象的表达式求值为res后调用
+func (st *state) allocated(node ast.Node, res object.Object) {
+	switch node.(type) {
+	case *ast.IntLiteral, *ast.StrLiteral, *ast.ArrLiteral, *ast.MapLiteral, *ast.StructLiteral,
+		*ast.FunctionLiteral, *ast.PrefixExpression, *ast.InfixExpression:
+	default:
+		return
+	}
+	if fresh(res, nil) {
+		st.alloc.Alloc(res, ast.Pos(node))
+	}
+}
+
+// 内置函数返回后调用,返回实参或其中的元素时不算新创建
+func (st *state) allocatedBy(call token.Token, fn object.Object, args []object.Object, res object.Object) {
+	if _, ok := fn.(*object.Builtins); ok && fresh(res, args) {
+		st.alloc.Alloc(res, call)
+	}
+}
+
+func fresh(res object.Object, args []object.Object) bool {
+	switch res {
+	case nil, True, False, Nil:
+		return false
+	}
+	if _, ok := res.(*object.Error); ok {
+		return false
+	}
+	for _, arg := range args {
+		if arg == res {
+			return false
+		}
+		if arr, ok := arg.(*object.Array); ok {
+			for _, elem := range arr.Elements {
+				if elem == res {
+					return false
+				}
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,140 @@
+package builtins
+
+import (
+	"fmt"
+	"my-interpreter/ast"
+	"my-interpreter/lexer"
+	"my-interpreter/object"
+	"my-interpreter/parser"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// 脚本文件的扩展名,import的路径没有扩展名时自动加上
+const FILE_EXT = ".mi"
+
+// 模块搜索路径的环境变量,多个目录用系统的路径分隔符隔开
+const PATH_ENV = "MY_INTERPRETER_PATH"
+
+// 模块加载器,每个模块只求值一次,并检测循环导入
+type Importer struct {
+	SearchPath []string
+	modules    map[string]*object.Module //按绝对路径缓存
+	loading    []string                  //正在加载的模块,用于检测循环
+}
+
+func NewImporter(searchPath ...string) *Importer {
+	return &Importer{SearchPath: searchPath, modules: map[string]*object.Module{}}
+}
+
+// 搜索路径取自环境变量MY_INTERPRETER_PATH
+func NewImporterFromEnv() *Importer {
+	return NewImporter(filepath.SplitList(os.Getenv(PATH_ENV))...)
+}
+
+// 先相对于导入它的文件所在目录查找,再依次在搜索路径中查找
+func (im *Importer) resolve(path, from string) (string, error) {
+	if filepath.Ext(path) == "" {
+		path += FILE_EXT
+	}
+	if filepath.IsAbs(path) {
+		return path, nil
+	}
+	dir := "."
+	if from != "" {
+		dir = filepath.Dir(from)
+	}
+	candidates := []string{filepath.Join(dir, path)}
+	for _, p := range im.SearchPath {
+		candidates = append(candidates, filepath.Join(p, path))
+	}
+	for _, c := range candidates {
+		if info, err := os.Stat(c); err == nil && !info.IsDir() {
+			return filepath.Abs(c)
+		}
+	}
+	return "", fmt.Errorf("module not found: %s", path)
+}
+
+// 把顶层求值的文件也记为正在加载,模块反过来导入它时报告循环,返回的函数撤销记录
+func (im *Importer) enter(file string) func() {
+	abs, err := filepath.Abs(file)
+	if file == "" || err != nil {
+		return func() {}
+	}
+	im.loading = append(im.loading, abs)
+	return func() {
+		im.loading = im.loading[:len(im.loading)-1]
+	}
+}
+
+func evalImportStatement(st *state, node *ast.ImportStatement, env *object.Environment) object.Object {
+	path := node.Path.Token.Literal
+	module := importModule(st, path)
+	if isError(module) {
+		return module
+	}
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	if node.Alias != nil {
+		name = node.Alias.Token.Literal
+	}
+	if env.IsConst(name) {
+		return newError("cannot reassign constant %s", name)
+	}
+	env.Set(name, module)
+	return nil
+}
+
+func importModule(st *state, path string) object.Object {
+	im := st.importer
+	abs, err := im.resolve(path, st.file)
+	if err != nil {
+		return newError("%s", err)
+	}
+	if module, ok := im.modules[abs]; ok {
+		return module
+	}
+	for i, loading := range im.loading {
+		if loading == abs {
+			cycle := append(append([]string{}, im.loading[i:]...), abs)
+			for j := range cycle {
+				cycle[j] = filepath.Base(cycle[j])
+			}
+			return newError("import cycle: %s", strings.Join(cycle, " -> "))
+		}
+	}
+	data, err := os.ReadFile(abs)
+	if err != nil {
+		return newError("%s", err)
+	}
+	p := parser.NewParser(lexer.NewLexer(string(data)))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return newError("parse error in module %s: %s", path, strings.Join(p.Errors(), "; "))
+	}
+
+	im.loading = append(im.loading, abs)
+	file := st.file
+	st.file = abs
+	module := &object.Module{
+		Name: strings.TrimSuffix(filepath.Base(abs), filepath.Ext(abs)),
+		Path: abs,
+		Env:  object.NewEnvironment(),
+	}
+	res := evalProgram(st, program, module.Env)
+	st.file = file
+	im.loading = im.loading[:len(im.loading)-1]
+
+	if errObj, ok := res.(*object.Error); ok {
+		//超时和资源限制等错误原样返回
+		if errObj.Kind != object.RUNTIME_ERROR {
+			return errObj
+		}
+		wrapped := *errObj
+		wrapped.Msg = fmt.Sprintf("in module %s: %s", path, errObj.Msg)
+		return &wrapped
+	}
+	im.modules[abs] = module
+	return module
+}
@@ -0,0 +1,105 @@
+package builtins
+
+import (
+	"context"
+	"fmt"
+	"my-interpreter/lexer"
+	"my-interpreter/object"
+	"my-interpreter/parser"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeFiles(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func evalFile(t *testing.T, im *Importer, path string) object.Object {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	program := parser.NewParser(lexer.NewLexer(string(data))).ParseProgram()
+	return EvalContext(context.Background(), program, object.NewEnvironment(), Config{Importer: im, File: path})
+}
+
+func TestImport(t *testing.T) {
+	shared := writeFiles(t, map[string]string{
+		"strings.mi": `let greet = fn(name) { "hello " + name };`,
+	})
+	dir := writeFiles(t, map[string]string{
+		"lib/math.mi": `let _offset = 0;
+let counter = [];
+let add = fn(a, b) { a + b + _offset };`,
+		"lib/geometry.mi": `import "math"; let area = fn(w, h) { math.add(w * h, 0) };`,
+		"main.mi": `import "lib/math";
+import "lib/geometry" as geo;
+import "strings";
+math.add(geo.area(2, 3), len(strings.greet("x")))`,
+		"once.mi":       `import "lib/math" as a; import "lib/math" as b; a == b`,
+		"private.mi":    `import "lib/math"; math._offset`,
+		"missing.mi":    "let a = 1;\n  import \"nope\"",
+		"cycle/a.mi":    `import "b"; let x = 1;`,
+		"cycle/b.mi":    `import "a"; let y = 1;`,
+		"cycle/self.mi": `import "self"`,
+		"broken.mi":     `import "lib/bad"`,
+		"lib/bad.mi":    `1 + true`,
+	})
+	im := NewImporter(shared)
+	testIntegerObject(t, evalFile(t, im, filepath.Join(dir, "main.mi")), 13)
+	testBooleanObject(t, evalFile(t, im, filepath.Join(dir, "once.mi")), true)
+
+	tests := []struct {
+		file     string
+		expected string
+		location string //出错的文件,行和列
+	}{
+		{"private.mi", "module math has no exported member _offset", "private.mi:1:24"},
+		{"missing.mi", "module not found: nope.mi", "missing.mi:2:3"},
+		{"cycle/a.mi", "in module b: import cycle: a.mi -> b.mi -> a.mi", "cycle/b.mi:1:1"},
+		{"cycle/self.mi", "import cycle: self.mi -> self.mi", "cycle/self.mi:1:1"},
+		{"broken.mi", "in module lib/bad: type mismatch: INTEGER + BOOLEAN", "lib/bad.mi:1:3"},
+	}
+	for _, tt := range tests {
+		evaluated := evalFile(t, NewImporter(), filepath.Join(dir, tt.file))
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("%s: no error object returned. got=%T(%+v)", tt.file, evaluated, evaluated)
+			continue
+		}
+		if errObj.Msg != tt.expected {
+			t.Errorf("%s: wrong error message. expected=%q, got=%q", tt.file, tt.expected, errObj.Msg)
+		}
+		rel, _ := filepath.Rel(dir, errObj.File)
+		if location := fmt.Sprintf("%s:%d:%d", filepath.ToSlash(rel), errObj.Line, errObj.Column); location != tt.location {
+			t.Errorf("%s: wrong error location. expected=%s, got=%s", tt.file, tt.location, location)
+		}
+	}
+}
+
+// 模块中定义的函数在调用方求值时,错误的位置仍然在模块中
+func TestImportedFunctionErrorFile(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"lib.mi":  "let fail = fn(x) {\n\tx + true\n};",
+		"main.mi": "import \"lib\";\n\nlib.fail(1)",
+	})
+	evaluated := evalFile(t, NewImporter(), filepath.Join(dir, "main.mi"))
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+	}
+	if filepath.Base(errObj.File) != "lib.mi" || errObj.Line != 2 {
+		t.Errorf("error at %s:%d, want lib.mi:2", errObj.File, errObj.Line)
+	}
+}
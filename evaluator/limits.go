@@ -0,0 +1,77 @@
+package builtins
+
+import (
+	"context"
+	"my-interpreter/object"
+	"time"
+)
+
+// 求值的资源限制,零值表示不限制
+type Limits struct {
+	Timeout       time.Duration //墙钟时间
+	MaxDepth      int           //函数调用的最大嵌套深度
+	MaxSteps      int64         //最多求值的语法树节点数
+	MaxCollection int           //数组元素,映射键值对和字符串字节数的上限
+}
+
+// 每求值这么多个节点检查一次ctx是否已取消
+const cancelCheckInterval = 256
+
+// 每求值一个节点调用一次,超出步数或ctx已结束时返回错误
+func (st *state) step() *object.Error {
+	st.steps++
+	if st.limits.MaxSteps > 0 && st.steps > st.limits.MaxSteps {
+		return newLimitError(object.STEP_LIMIT, "step limit exceeded: %d", st.limits.MaxSteps)
+	}
+	if st.steps%cancelCheckInterval == 0 {
+		return st.checkContext()
+	}
+	return nil
+}
+
+func (st *state) checkContext() *object.Error {
+	switch st.ctx.Err() {
+	case nil:
+		return nil
+	case context.DeadlineExceeded:
+		return newLimitError(object.TIMEOUT, "evaluation timed out")
+	default:
+		return newLimitError(object.CANCELED, "evaluation canceled")
+	}
+}
+
+// 进入函数调用,超出最大深度时返回错误,否则调用方需在返回时调用leave
+func (st *state) enter() *object.Error {
+	if st.limits.MaxDepth > 0 && st.depth >= st.limits.MaxDepth {
+		return newLimitError(object.DEPTH_LIMIT, "maximum call depth exceeded: %d", st.limits.MaxDepth)
+	}
+	st.depth++
+	return nil
+}
+
+func (st *state) leave() {
+	st.depth--
+}
+
+// 检查新创建的数组,映射和字符串是否超出大小限制
+func (st *state) checkSize(obj object.Object) object.Object {
+	max := st.limits.MaxCollection
+	if max <= 0 {
+		return obj
+	}
+	var size int
+	switch obj := obj.(type) {
+	case *object.Array:
+		size = len(obj.Elements)
+	case *object.Map:
+		size = len(obj.Mappings)
+	case *object.String:
+		size = len(obj.Value)
+	default:
+		return obj
+	}
+	if size > max {
+		return newLimitError(object.SIZE_LIMIT, "%s size %d exceeds limit %d", obj.Type(), size, max)
+	}
+	return obj
+}
@@ -0,0 +1,96 @@
+package builtins
+
+import (
+	"my-interpreter/ast"
+	"my-interpreter/object"
+)
+
+// 依次尝试各分支,模式中绑定的名字只在该分支的guard和body中可见
+// tail为true时body处于尾位置,其中的调用是尾调用
+func evalMatchExpr(st *state, node *ast.MatchExpression, env *object.Environment, tail bool) object.Object {
+	val := eval(st, node.Value, env)
+	if isError(val) {
+		return val
+	}
+	for _, arm := range node.Arms {
+		armEnv := object.NewEnclosedEnvironment(env)
+		ok, err := matchPattern(st, arm.Pattern, val, armEnv)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		if arm.Guard != nil {
+			guard := eval(st, arm.Guard, armEnv)
+			if isError(guard) {
+				return guard
+			}
+			if !isTruthy(guard) {
+				continue
+			}
+		}
+		if tail {
+			return evalTailExpr(st, arm.Body, armEnv)
+		}
+		return eval(st, arm.Body, armEnv)
+	}
+	err := newError("no match arm for value %s", val.Inspect())
+	st.locate(err, node.Token)
+	return err
+}
+
+// 匹配成功时把模式中的名字绑定到env
+func matchPattern(st *state, pattern ast.Pattern, val object.Object, env *object.Environment) (bool, object.Object) {
+	switch pattern := pattern.(type) {
+	case *ast.WildcardPattern:
+		return true, nil
+	case *ast.BindingPattern:
+		env.Set(pattern.Name.Token.Literal, val)
+		return true, nil
+	case *ast.LiteralPattern:
+		lit := eval(st, pattern.Value, env)
+		if isError(lit) {
+			return false, lit
+		}
+		return object.Equal(lit, val), nil
+	case *ast.ArrayPattern:
+		arr, ok := val.(*object.Array)
+		if !ok || len(arr.Elements) < len(pattern.Elements) {
+			return false, nil
+		}
+		if !pattern.HasRest && len(arr.Elements) != len(pattern.Elements) {
+			return false, nil
+		}
+		for i, elem := range pattern.Elements {
+			if ok, err := matchPattern(st, elem, arr.Elements[i], env); !ok || err != nil {
+				return false, err
+			}
+		}
+		if pattern.Rest != nil {
+			rest := append([]object.Object{}, arr.Elements[len(pattern.Elements):]...)
+			env.Set(pattern.Rest.Token.Literal, &object.Array{Elements: rest})
+		}
+		return true, nil
+	case *ast.MapPattern:
+		m, ok := val.(*object.Map)
+		if !ok {
+			return false, nil
+		}
+		for i, keyNode := range pattern.Keys {
+			key := eval(st, keyNode, env)
+			if isError(key) {
+				return false, key
+			}
+			pair, ok := m.Get(key)
+			if !ok {
+				return false, nil
+			}
+			if ok, err := matchPattern(st, pattern.Values[i], pair.Value, env); !ok || err != nil {
+				return false, err
+			}
+		}
+		return true, nil
+	}
+	return false, newError("unknown pattern: %s", pattern)
+}
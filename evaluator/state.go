@@ -0,0 +1,49 @@
+package builtins
+
+import (
+	"context"
+)
+
+// 求值的配置,零值表示不限制资源,每次求值使用新的模块加载器
+type Config struct {
+	Limits   Limits
+	Importer *Importer
+	File     string //正在求值的文件,其中的import相对于它所在的目录解析
+	Hook     Hook
+}
+
+// 一次求值过程的状态,随env一起沿着求值函数传递
+type state struct {
+	ctx      context.Context
+	limits   Limits
+	importer *Importer
+	file     string
+	hook     Hook
+	alloc    AllocHook  //Hook实现了AllocHook时与hook相同,否则为nil
+	branch   BranchHook //同上
+	trace    TraceHook  //同上
+	depth    int
+	steps    int64
+}
+
+// 返回的函数在求值结束后调用,释放超时的计时器等资源
+func newState(ctx context.Context, cfg Config) (*state, func()) {
+	cancel := context.CancelFunc(func() {})
+	if cfg.Limits.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, cfg.Limits.Timeout)
+	}
+	importer := cfg.Importer
+	if importer == nil {
+		importer = NewImporter()
+	}
+	leave := importer.enter(cfg.File)
+	done := func() {
+		leave()
+		cancel()
+	}
+	st := &state{ctx: ctx, limits: cfg.Limits, importer: importer, file: cfg.File, hook: cfg.Hook}
+	st.alloc, _ = cfg.Hook.(AllocHook)
+	st.branch, _ = cfg.Hook.(BranchHook)
+	st.trace, _ = cfg.Hook.(TraceHook)
+	return st, done
+}
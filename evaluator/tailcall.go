@@ -0,0 +1,120 @@
+package builtins
+
+import (
+	"my-interpreter/ast"
+	"my-interpreter/object"
+)
+
+const TAIL_CALL = "TAIL_CALL"
+
+// 尾调用:函数体在尾位置调用另一个函数时不直接调用,而是把被调函数和实参交回applyFunction
+// 只在applyFunction内部出现,不会被脚本看到
+type tailCall struct {
+	fn    object.Object
+	args  []object.Object
+	named []namedArg
+}
+
+func (tc *tailCall) Type() object.ObjectType {
+	return TAIL_CALL
+}
+func (tc *tailCall) Inspect() string {
+	return "tail call"
+}
+
+// 求值函数体,tail表示block的最后一条语句是否处于尾位置
+// 任何位置的return语句返回的都是函数的结果,其中的调用总是尾调用
+func evalFunctionBody(st *state, block *ast.BlockStatement, env *object.Environment, tail bool) object.Object {
+	var res object.Object
+	for i, statement := range block.Statements {
+		if err := st.statement(statement, env); err != nil {
+			return err
+		}
+		last := tail && i == len(block.Statements)-1
+		switch statement := statement.(type) {
+		case *ast.ReturnStatement:
+			if err := st.step(); err != nil {
+				return err
+			}
+			res = evalTailExpr(st, statement.ReturnValue, env)
+			if !isError(res) && res.Type() != TAIL_CALL {
+				res = &object.Return{Value: res}
+			}
+		case *ast.ExpressionStatement:
+			if ifExpr, ok := statement.Expr.(*ast.IfExpression); ok && !last {
+				//不在尾位置的if,分支中仍可能有return
+				res = evalTailIf(st, ifExpr, env, false)
+			} else if last {
+				res = evalTailExpr(st, statement.Expr, env)
+			} else {
+				res = eval(st, statement, env)
+			}
+		default:
+			res = eval(st, statement, env)
+		}
+		if res != nil {
+			typ := res.Type()
+			if typ == object.RETURN || typ == object.ERROR || typ == TAIL_CALL {
+				return res
+			}
+		}
+	}
+	return res
+}
+
+// 求值尾位置上的表达式,调用脚本函数时返回tailCall
+func evalTailExpr(st *state, expr ast.Expression, env *object.Environment) object.Object {
+	switch expr := expr.(type) {
+	case *ast.CallExpression:
+		if err := st.step(); err != nil {
+			return err
+		}
+		function := eval(st, expr.Function, env)
+		if isError(function) {
+			return function
+		}
+		args, named, err := evalArguments(st, expr.Arguments, env)
+		if err != nil {
+			return err
+		}
+		if _, ok := function.(*object.Function); !ok {
+			res := applyFunction(st, function, args, named)
+			if err, ok := res.(*object.Error); ok && err.Line == 0 {
+				st.locate(err, expr.Token)
+			}
+			if st.alloc != nil {
+				st.allocatedBy(expr.Token, function, args, res)
+			}
+			return res
+		}
+		return &tailCall{fn: function, args: args, named: named}
+	case *ast.IfExpression:
+		return evalTailIf(st, expr, env, true)
+	case *ast.MatchExpression:
+		if err := st.step(); err != nil {
+			return err
+		}
+		return evalMatchExpr(st, expr, env, true)
+	default:
+		return eval(st, expr, env)
+	}
+}
+
+func evalTailIf(st *state, node *ast.IfExpression, env *object.Environment, tail bool) object.Object {
+	if err := st.step(); err != nil {
+		return err
+	}
+	condf := eval(st, node.Condition, env)
+	if isError(condf) {
+		return condf
+	}
+	if st.branch != nil {
+		st.branch.Branch(st.file, node, isTruthy(condf))
+	}
+	if isTruthy(condf) {
+		return evalFunctionBody(st, node.Consequence, env, tail)
+	} else if node.Alternative != nil {
+		return evalFunctionBody(st, node.Alternative, env, tail)
+	}
+	return Nil
+}
@@ -0,0 +1,392 @@
+// format包按统一的风格重新输出脚本:制表符缩进,运算符两侧各一个空格,只保留必要的括号
+// 语句之间最多保留一个空行,源码中跨行的映射,结构体字面量和match保持每项一行
+package format
+
+import (
+	"bytes"
+	"errors"
+	"my-interpreter/ast"
+	"my-interpreter/lexer"
+	"my-interpreter/parser"
+	"strings"
+)
+
+// 格式化源码,有语法错误时返回第一个错误
+func Source(src string) (string, error) {
+	p := parser.NewParser(lexer.NewLexer(src))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return "", errors.New(p.Errors()[0])
+	}
+	return Program(program), nil
+}
+
+// 没有语法错误的program的源码
+func Program(program *ast.Program) string {
+	pr := &printer{}
+	pr.statements(program.Statements)
+	if len(program.Statements) > 0 {
+		pr.write("\n")
+	}
+	return pr.out.String()
+}
+
+// 比调用更高的优先级,用于字面量和标识符等不需要括号的表达式
+const primary = parser.CALL + 1
+
+type printer struct {
+	out    bytes.Buffer
+	indent int
+}
+
+func (pr *printer) write(s ...string) {
+	for _, str := range s {
+		pr.out.WriteString(str)
+	}
+}
+
+func (pr *printer) newline() {
+	pr.out.WriteString("\n" + strings.Repeat("\t", pr.indent))
+}
+
+// 以当前的缩进输出到新的printer,返回其内容
+func (pr *printer) capture(f func(sub *printer)) string {
+	sub := &printer{indent: pr.indent}
+	f(sub)
+	return sub.out.String()
+}
+
+// 每条语句一行,以当前的缩进开始
+func (pr *printer) statements(statements []ast.Statement) {
+	texts := make([]string, len(statements))
+	for i, stmt := range statements {
+		texts[i] = pr.capture(func(sub *printer) { sub.statement(stmt) })
+	}
+	for i, stmt := range statements {
+		if i > 0 {
+			pr.newline()
+			if ast.Pos(stmt).Line > ast.End(statements[i-1]).Line+1 {
+				pr.newline()
+			}
+		}
+		pr.write(texts[i])
+		next := ""
+		if i+1 < len(texts) {
+			next = texts[i+1]
+		}
+		if needsSemicolon(stmt, next) {
+			pr.write(";")
+		}
+	}
+}
+
+// 以}结束的if,try,match和函数字面量之后只有在下一条语句会被解析为其延续时才需要分号
+func needsSemicolon(stmt ast.Statement, next string) bool {
+	switch stmt := stmt.(type) {
+	case *ast.FunctionStatement, *ast.StructStatement:
+		return false
+	case *ast.ExpressionStatement:
+		switch stmt.Expr.(type) {
+		case *ast.IfExpression, *ast.TryExpression, *ast.MatchExpression, *ast.FunctionLiteral:
+			return next != "" && strings.ContainsAny(next[:1], "([{.-+*/<>=!")
+		}
+	}
+	return true
+}
+
+func (pr *printer) statement(stmt ast.Statement) {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		pr.write(stmt.Token.Literal, " ", stmt.Name.String())
+		if stmt.Type != nil {
+			pr.write(": ", stmt.Type.String())
+		}
+		pr.write(" = ")
+		pr.expr(stmt.Value)
+	case *ast.ImportStatement:
+		pr.write(`import "`, stmt.Path.Token.Literal, `"`)
+		if stmt.Alias != nil {
+			pr.write(" as ", stmt.Alias.String())
+		}
+	case *ast.ReturnStatement:
+		pr.write("return ")
+		pr.expr(stmt.ReturnValue)
+	case *ast.ThrowStatement:
+		pr.write("throw ")
+		pr.expr(stmt.Value)
+	case *ast.ExpressionStatement:
+		pr.expr(stmt.Expr)
+	case *ast.FunctionStatement:
+		pr.write("fn ", stmt.Name.String())
+		pr.signature(stmt.Function)
+		pr.write(" ")
+		pr.block(stmt.Function.Body)
+	case *ast.StructStatement:
+		var fields []string
+		for _, f := range stmt.Fields {
+			fields = append(fields, f.String())
+		}
+		pr.write("struct ", stmt.Name.String(), " { ", strings.Join(fields, ", "), " }")
+	}
+}
+
+// 空的语句块输出{},源码中在一行的只有一条语句的语句块保持一行,其他的每条语句一行
+func (pr *printer) block(block *ast.BlockStatement) {
+	switch len(block.Statements) {
+	case 0:
+		pr.write("{}")
+		return
+	case 1:
+		if block.Token.Line == block.Rbrace.Line {
+			text := pr.capture(func(sub *printer) { sub.statement(block.Statements[0]) })
+			if !strings.Contains(text, "\n") {
+				pr.write("{ ", text, " }")
+				return
+			}
+		}
+	}
+	pr.write("{")
+	pr.indent++
+	pr.newline()
+	pr.statements(block.Statements)
+	pr.indent--
+	pr.newline()
+	pr.write("}")
+}
+
+func precedence(expr ast.Expression) int {
+	switch expr := expr.(type) {
+	case *ast.InfixExpression:
+		return parser.Precedence(expr.Token.Type)
+	case *ast.PrefixExpression:
+		return parser.PREFIX
+	case *ast.IndexExpression:
+		return parser.INDEX
+	case *ast.CallExpression, *ast.SelectorExpression, *ast.StructLiteral:
+		return parser.CALL
+	}
+	return primary
+}
+
+// 输出expr,其优先级低于prec时加括号
+func (pr *printer) operand(expr ast.Expression, prec int) {
+	if precedence(expr) < prec {
+		pr.write("(")
+		pr.expr(expr)
+		pr.write(")")
+		return
+	}
+	pr.expr(expr)
+}
+
+func (pr *printer) list(exprs []ast.Expression) {
+	for i, e := range exprs {
+		if i > 0 {
+			pr.write(", ")
+		}
+		pr.expr(e)
+	}
+}
+
+// 输出大括号中的多个项,multiline时每项一行并以逗号结束,否则在一行中,padded时大括号内侧有空格
+func (pr *printer) items(n int, multiline, padded bool, item func(i int)) {
+	if n == 0 {
+		pr.write("{}")
+		return
+	}
+	if !multiline {
+		open, close := "{", "}"
+		if padded {
+			open, close = "{ ", " }"
+		}
+		pr.write(open)
+		for i := 0; i < n; i++ {
+			if i > 0 {
+				pr.write(", ")
+			}
+			item(i)
+		}
+		pr.write(close)
+		return
+	}
+	pr.write("{")
+	pr.indent++
+	for i := 0; i < n; i++ {
+		pr.newline()
+		item(i)
+		pr.write(",")
+	}
+	pr.indent--
+	pr.newline()
+	pr.write("}")
+}
+
+func (pr *printer) expr(expr ast.Expression) {
+	switch expr := expr.(type) {
+	case *ast.Identifier, *ast.IntLiteral, *ast.BoolLiteral:
+		pr.write(expr.String())
+	case *ast.StrLiteral:
+		pr.write(`"`, expr.Token.Literal, `"`)
+	case *ast.PrefixExpression:
+		pr.write(expr.Token.Literal)
+		pr.operand(expr.Right, parser.PREFIX)
+	case *ast.InfixExpression:
+		//同一优先级的运算符左结合,右操作数需要更高的优先级
+		prec := parser.Precedence(expr.Token.Type)
+		pr.operand(expr.Left, prec)
+		pr.write(" ", expr.Token.Literal, " ")
+		pr.operand(expr.Right, prec+1)
+	case *ast.ArrLiteral:
+		pr.write("[")
+		pr.list(expr.Elements)
+		pr.write("]")
+	case *ast.MapLiteral:
+		keys := ast.SortedKeys(expr)
+		multiline := len(keys) > 0 && ast.Pos(keys[0]).Line > expr.Token.Line
+		pr.items(len(keys), multiline, false, func(i int) {
+			pr.expr(keys[i])
+			pr.write(": ")
+			pr.expr(expr.Pairs[keys[i]])
+		})
+	case *ast.FunctionLiteral:
+		pr.write("fn")
+		pr.signature(expr)
+		pr.write(" ")
+		pr.block(expr.Body)
+	case *ast.IfExpression:
+		pr.write("if (")
+		pr.expr(expr.Condition)
+		pr.write(") ")
+		pr.block(expr.Consequence)
+		if expr.Alternative != nil {
+			pr.write(" else ")
+			pr.block(expr.Alternative)
+		}
+	case *ast.TryExpression:
+		pr.write("try ")
+		pr.block(expr.Body)
+		if expr.Catch != nil {
+			pr.write(" catch ")
+			if expr.Param != nil {
+				pr.write("(", expr.Param.String(), ") ")
+			}
+			pr.block(expr.Catch)
+		}
+		if expr.Finally != nil {
+			pr.write(" finally ")
+			pr.block(expr.Finally)
+		}
+	case *ast.MatchExpression:
+		pr.write("match (")
+		pr.expr(expr.Value)
+		pr.write(") ")
+		multiline := len(expr.Arms) > 0 && expr.Arms[0].Token.Line > expr.Token.Line
+		pr.items(len(expr.Arms), multiline, true, func(i int) {
+			arm := expr.Arms[i]
+			pr.pattern(arm.Pattern)
+			if arm.Guard != nil {
+				pr.write(" if ")
+				pr.expr(arm.Guard)
+			}
+			pr.write(" => ")
+			pr.expr(arm.Body)
+		})
+	case *ast.CallExpression:
+		pr.operand(expr.Function, parser.INDEX)
+		pr.write("(")
+		pr.list(expr.Arguments)
+		pr.write(")")
+	case *ast.SpreadExpression:
+		pr.write("...")
+		pr.expr(expr.Value)
+	case *ast.NamedArgument:
+		pr.write(expr.Name.String(), ": ")
+		pr.expr(expr.Value)
+	case *ast.IndexExpression:
+		pr.operand(expr.Left, parser.INDEX)
+		pr.write("[")
+		pr.expr(expr.Index)
+		pr.write("]")
+	case *ast.SelectorExpression:
+		pr.operand(expr.Left, parser.INDEX)
+		pr.write(".", expr.Selector.String())
+	case *ast.StructLiteral:
+		pr.operand(expr.Type, parser.INDEX)
+		multiline := len(expr.Fields) > 0 && expr.Fields[0].Token.Line > expr.Token.Line
+		pr.items(len(expr.Fields), multiline, false, func(i int) {
+			pr.write(expr.Fields[i].String(), ": ")
+			pr.expr(expr.Values[i])
+		})
+	}
+}
+
+// 带类型注解和默认值的参数列表以及返回类型
+func (pr *printer) signature(fn *ast.FunctionLiteral) {
+	pr.write("(")
+	for i, param := range fn.Parameters {
+		if i > 0 {
+			pr.write(", ")
+		}
+		pr.write(param.String())
+		if i < len(fn.ParamTypes) && fn.ParamTypes[i] != nil {
+			pr.write(": ", fn.ParamTypes[i].String())
+		}
+		if i < len(fn.Defaults) && fn.Defaults[i] != nil {
+			pr.write(" = ")
+			pr.expr(fn.Defaults[i])
+		}
+	}
+	if fn.Rest != nil {
+		if len(fn.Parameters) > 0 {
+			pr.write(", ")
+		}
+		pr.write("...", fn.Rest.String())
+		if fn.RestType != nil {
+			pr.write(": ", fn.RestType.String())
+		}
+	}
+	pr.write(")")
+	if fn.ReturnType != nil {
+		pr.write(" -> ", fn.ReturnType.String())
+	}
+}
+
+func (pr *printer) pattern(pattern ast.Pattern) {
+	switch pattern := pattern.(type) {
+	case *ast.LiteralPattern:
+		pr.expr(pattern.Value)
+	case *ast.WildcardPattern:
+		pr.write("_")
+	case *ast.BindingPattern:
+		pr.write(pattern.Name.String())
+	case *ast.ArrayPattern:
+		pr.write("[")
+		for i, e := range pattern.Elements {
+			if i > 0 {
+				pr.write(", ")
+			}
+			pr.pattern(e)
+		}
+		if pattern.HasRest {
+			if len(pattern.Elements) > 0 {
+				pr.write(", ")
+			}
+			pr.write("...")
+			if pattern.Rest != nil {
+				pr.write(pattern.Rest.String())
+			}
+		}
+		pr.write("]")
+	case *ast.MapPattern:
+		pr.write("{")
+		for i, key := range pattern.Keys {
+			if i > 0 {
+				pr.write(", ")
+			}
+			pr.expr(key)
+			pr.write(": ")
+			pr.pattern(pattern.Values[i])
+		}
+		pr.write("}")
+	}
+}
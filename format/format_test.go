@@ -0,0 +1,65 @@
+package format
+
+import (
+	"my-interpreter/ast"
+	"my-interpreter/lexer"
+	"my-interpreter/parser"
+	"testing"
+)
+
+func TestSource(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let   x=1+2*3;x`, "let x = 1 + 2 * 3;\nx;\n"},
+		{`(1 + 2) * 3; 1 - (2 - 3); (1 - 2) - 3; -(a + b); (-a)[0]; (a + b)(1); f(1)(2)[0].x`,
+			"(1 + 2) * 3;\n1 - (2 - 3);\n1 - 2 - 3;\n-(a + b);\n(-a)[0];\n(a + b)(1);\nf(1)(2)[0].x;\n"},
+		{`let s = "a b"; import "lib/util.mi" as u`, "let s = \"a b\";\nimport \"lib/util.mi\" as u;\n"},
+		{"let a = 1;\n\n\n\nlet b = 2;\nlet c = 3;", "let a = 1;\n\nlet b = 2;\nlet c = 3;\n"},
+		{`let f = fn(x: int, y = "d", ...r: [int]) -> int { x };`,
+			"let f = fn(x: int, y = \"d\", ...r: [int]) -> int { x };\n"},
+		{"fn add(a, b) {\nreturn a + b;\n}\nstruct P { x, y }\nadd(1, 2)",
+			"fn add(a, b) {\n\treturn a + b;\n}\nstruct P { x, y }\nadd(1, 2);\n"},
+		{"if (x > 1) { let y = 2; y } else {}\nif (x) { 1 };\n[1, 2]",
+			"if (x > 1) {\n\tlet y = 2;\n\ty;\n} else {}\nif (x) { 1 };\n[1, 2];\n"},
+		{`let m = {"a": 1, "b": [1, 2]}; P{x: 1, y: 2}`, "let m = {\"a\": 1, \"b\": [1, 2]};\nP{x: 1, y: 2};\n"},
+		{"let m = {\n\"a\": 1, \"b\": 2}", "let m = {\n\t\"a\": 1,\n\t\"b\": 2,\n};\n"},
+		{`match (v) { [1, ...r] if len(r) > 0 => r, {"k": x} => x, -1 => "neg", _ => null }`,
+			"match (v) { [1, ...r] if len(r) > 0 => r, {\"k\": x} => x, -1 => \"neg\", _ => null }\n"},
+		{"try { throw error(\"e\") } catch (e) {\ne\n} finally { prints(1) }; f(...xs, n: 1)",
+			"try { throw error(\"e\") } catch (e) {\n\te;\n} finally { prints(1) }\nf(...xs, n: 1);\n"},
+		{"let g = fn() {\nlet h = fn() {\n1\n};\nh\n};", "let g = fn() {\n\tlet h = fn() {\n\t\t1;\n\t};\n\th;\n};\n"},
+	}
+	for _, tt := range tests {
+		got, err := Source(tt.input)
+		if err != nil {
+			t.Errorf("%q: unexpected error %v", tt.input, err)
+			continue
+		}
+		if got != tt.expected {
+			t.Errorf("%q:\nexpected=%q\ngot=     %q", tt.input, tt.expected, got)
+		}
+		//格式化的结果应当不变,且与原程序的语法树相同
+		again, err := Source(got)
+		if err != nil || again != got {
+			t.Errorf("%q: formatting is not idempotent. got=%q, err=%v", got, again, err)
+		}
+		if parse(t, got) != parse(t, tt.input) {
+			t.Errorf("%q: formatted program differs.\nexpected:\n%s\ngot:\n%s", tt.input, parse(t, tt.input), parse(t, got))
+		}
+	}
+	if _, err := Source(`let = 1`); err == nil {
+		t.Errorf("expected an error for invalid input")
+	}
+}
+
+func parse(t *testing.T, input string) string {
+	p := parser.NewParser(lexer.NewLexer(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser has errors for %q: %v", input, p.Errors())
+	}
+	//String输出映射字面量时键的顺序不固定,Dump按键排序且不含位置
+	return ast.Dump(program)
+}
@@ -1,3 +1,7 @@
 module my-interpreter
 
-go 1.22
+go 1.25.0
+
+require golang.org/x/term v0.45.0
+
+require golang.org/x/sys v0.47.0 // indirect
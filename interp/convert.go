@@ -0,0 +1,381 @@
+package interp
+
+import (
+	"fmt"
+	"math"
+	evaluator "my-interpreter/evaluator" //不要动
+	"my-interpreter/object"
+	"reflect"
+)
+
+var (
+	objectType = reflect.TypeOf((*object.Object)(nil)).Elem()
+	errorType  = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// 把Go值转换为object.Object
+// 整数转为INTEGER,切片和数组转为ARRAY,映射和结构体转为MAP,函数转为内置函数
+// 结构体字段名可以用`interp:"name"`标签改写,标签为"-"的字段被忽略
+func ToObject(v any) (object.Object, error) {
+	if obj, ok := v.(object.Object); ok {
+		return obj, nil
+	}
+	if v == nil {
+		return evaluator.Nil, nil
+	}
+	return toObject(reflect.ValueOf(v))
+}
+
+func toObject(v reflect.Value) (object.Object, error) {
+	if v.Type().Implements(objectType) {
+		if v.Kind() == reflect.Interface && v.IsNil() {
+			return evaluator.Nil, nil
+		}
+		return v.Interface().(object.Object), nil
+	}
+	switch v.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			return evaluator.True, nil
+		}
+		return evaluator.False, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &object.Integer{Value: v.Int()}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if v.Uint() > math.MaxInt64 {
+			return nil, fmt.Errorf("%d overflows INTEGER", v.Uint())
+		}
+		return &object.Integer{Value: int64(v.Uint())}, nil
+	case reflect.String:
+		return &object.String{Value: v.String()}, nil
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return evaluator.Nil, nil
+		}
+		return toObject(v.Elem())
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return evaluator.Nil, nil
+		}
+		elements := make([]object.Object, v.Len())
+		for i := range elements {
+			elem, err := toObject(v.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			elements[i] = elem
+		}
+		return &object.Array{Elements: elements}, nil
+	case reflect.Map:
+		if v.IsNil() {
+			return evaluator.Nil, nil
+		}
+		m := object.NewMap()
+		iter := v.MapRange()
+		for iter.Next() {
+			if err := setPair(m, iter.Key(), iter.Value()); err != nil {
+				return nil, err
+			}
+		}
+		return m, nil
+	case reflect.Struct:
+		m := object.NewMap()
+		for i := 0; i < v.NumField(); i++ {
+			name, ok := fieldName(v.Type().Field(i))
+			if !ok {
+				continue
+			}
+			if err := setPair(m, reflect.ValueOf(name), v.Field(i)); err != nil {
+				return nil, err
+			}
+		}
+		return m, nil
+	case reflect.Func:
+		if v.IsNil() {
+			return evaluator.Nil, nil
+		}
+		return wrapFunc("function", v.Interface())
+	default:
+		return nil, fmt.Errorf("cannot convert %s to object", v.Type())
+	}
+}
+
+func setPair(m *object.Map, k, v reflect.Value) error {
+	key, err := toObject(k)
+	if err != nil {
+		return err
+	}
+	if !object.IsHashable(key) {
+		return fmt.Errorf("unusable as hash key: %s", key.Type())
+	}
+	val, err := toObject(v)
+	if err != nil {
+		return err
+	}
+	m.Set(key, val)
+	return nil
+}
+
+// 结构体字段在映射中对应的键,未导出或标签为"-"的字段返回false
+func fieldName(f reflect.StructField) (string, bool) {
+	if !f.IsExported() {
+		return "", false
+	}
+	tag := f.Tag.Get("interp")
+	if tag == "-" {
+		return "", false
+	}
+	if tag != "" {
+		return tag, true
+	}
+	return f.Name, true
+}
+
+// 把object.Object转换为最接近的Go值
+// INTEGER为int64,ARRAY为[]any,键全是字符串的MAP为map[string]any,其余MAP为map[any]any
+// 函数等没有对应Go类型的对象原样返回
+func ToValue(obj object.Object) any {
+	switch obj := obj.(type) {
+	case nil, *object.Null:
+		return nil
+	case *object.Integer:
+		return obj.Value
+	case *object.String:
+		return obj.Value
+	case *object.Boolean:
+		return obj.Value
+	case *object.Array:
+		res := make([]any, len(obj.Elements))
+		for i, e := range obj.Elements {
+			res[i] = ToValue(e)
+		}
+		return res
+	case *object.Map:
+		if strs, ok := stringKeyed(obj); ok {
+			return strs
+		}
+		res := make(map[any]any, len(obj.Mappings))
+		for _, pair := range obj.Mappings {
+			key := ToValue(pair.Key)
+			if _, ok := key.([]any); ok {
+				//切片不能作为Go映射的键,数组键保留为*object.Array
+				key = pair.Key
+			}
+			res[key] = ToValue(pair.Value)
+		}
+		return res
+	default:
+		return obj
+	}
+}
+
+func stringKeyed(m *object.Map) (map[string]any, bool) {
+	res := make(map[string]any, len(m.Mappings))
+	for _, pair := range m.Mappings {
+		key, ok := pair.Key.(*object.String)
+		if !ok {
+			return nil, false
+		}
+		res[key.Value] = ToValue(pair.Value)
+	}
+	return res, true
+}
+
+// 把obj存入target指向的Go值
+func FromObject(obj object.Object, target any) error {
+	v := reflect.ValueOf(target)
+	if v.Kind() != reflect.Pointer || v.IsNil() {
+		return fmt.Errorf("target must be a non-nil pointer, got %T", target)
+	}
+	return fromObject(obj, v.Elem())
+}
+
+func fromObject(obj object.Object, v reflect.Value) error {
+	if v.Type() == objectType {
+		v.Set(reflect.ValueOf(obj))
+		return nil
+	}
+	if _, ok := obj.(*object.Null); ok || obj == nil {
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+	mismatch := fmt.Errorf("cannot convert %s to %s", obj.Type(), v.Type())
+	switch v.Kind() {
+	case reflect.Interface:
+		val := ToValue(obj)
+		if val == nil || !reflect.TypeOf(val).AssignableTo(v.Type()) {
+			return mismatch
+		}
+		v.Set(reflect.ValueOf(val))
+	case reflect.Pointer:
+		elem := reflect.New(v.Type().Elem())
+		if err := fromObject(obj, elem.Elem()); err != nil {
+			return err
+		}
+		v.Set(elem)
+	case reflect.Bool:
+		b, ok := obj.(*object.Boolean)
+		if !ok {
+			return mismatch
+		}
+		v.SetBool(b.Value)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, ok := obj.(*object.Integer)
+		if !ok {
+			return mismatch
+		}
+		if v.OverflowInt(i.Value) {
+			return fmt.Errorf("%d overflows %s", i.Value, v.Type())
+		}
+		v.SetInt(i.Value)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		i, ok := obj.(*object.Integer)
+		if !ok {
+			return mismatch
+		}
+		if i.Value < 0 || v.OverflowUint(uint64(i.Value)) {
+			return fmt.Errorf("%d overflows %s", i.Value, v.Type())
+		}
+		v.SetUint(uint64(i.Value))
+	case reflect.String:
+		s, ok := obj.(*object.String)
+		if !ok {
+			return mismatch
+		}
+		v.SetString(s.Value)
+	case reflect.Slice:
+		arr, ok := obj.(*object.Array)
+		if !ok {
+			return mismatch
+		}
+		slice := reflect.MakeSlice(v.Type(), len(arr.Elements), len(arr.Elements))
+		for i, e := range arr.Elements {
+			if err := fromObject(e, slice.Index(i)); err != nil {
+				return err
+			}
+		}
+		v.Set(slice)
+	case reflect.Map:
+		m, ok := obj.(*object.Map)
+		if !ok {
+			return mismatch
+		}
+		res := reflect.MakeMapWithSize(v.Type(), len(m.Mappings))
+		for _, pair := range m.Mappings {
+			key := reflect.New(v.Type().Key()).Elem()
+			if err := fromObject(pair.Key, key); err != nil {
+				return err
+			}
+			val := reflect.New(v.Type().Elem()).Elem()
+			if err := fromObject(pair.Value, val); err != nil {
+				return err
+			}
+			res.SetMapIndex(key, val)
+		}
+		v.Set(res)
+	case reflect.Struct:
+		m, ok := obj.(*object.Map)
+		if !ok {
+			return mismatch
+		}
+		for i := 0; i < v.NumField(); i++ {
+			name, ok := fieldName(v.Type().Field(i))
+			if !ok {
+				continue
+			}
+			pair, ok := m.Get(&object.String{Value: name})
+			if !ok {
+				continue
+			}
+			if err := fromObject(pair.Value, v.Field(i)); err != nil {
+				return fmt.Errorf("field %s: %w", name, err)
+			}
+		}
+	default:
+		return mismatch
+	}
+	return nil
+}
+
+// 用反射把任意Go函数包装为内置函数
+// 返回值可以是空,一个值,一个error,或者一个值加一个error,返回的error在脚本中成为错误对象
+func wrapFunc(name string, fn any) (*object.Builtins, error) {
+	if builtin, ok := fn.(func(...object.Object) object.Object); ok {
+		return &object.Builtins{Name: name, Fn: func(params ...object.Object) (res object.Object) {
+			defer recoverPanic(name, &res)
+			return builtin(params...)
+		}}, nil
+	}
+	v := reflect.ValueOf(fn)
+	t := v.Type()
+	if t.Kind() != reflect.Func {
+		return nil, fmt.Errorf("cannot register %s as a function", t)
+	}
+	switch {
+	case t.NumOut() > 2,
+		t.NumOut() == 2 && t.Out(1) != errorType:
+		return nil, fmt.Errorf("function %s must return at most a value and an error", name)
+	}
+	return &object.Builtins{Name: name, Fn: func(params ...object.Object) object.Object {
+		in, errObj := funcArgs(name, t, params)
+		if errObj != nil {
+			return errObj
+		}
+		out, panicked := call(name, v, in)
+		if panicked != nil {
+			return panicked
+		}
+		if len(out) > 0 && out[len(out)-1].Type() == errorType {
+			if err, _ := out[len(out)-1].Interface().(error); err != nil {
+				return &object.Error{Kind: object.RUNTIME_ERROR, Msg: err.Error()}
+			}
+			out = out[:len(out)-1]
+		}
+		if len(out) == 0 {
+			return evaluator.Nil
+		}
+		obj, err := toObject(out[0])
+		if err != nil {
+			return &object.Error{Kind: object.RUNTIME_ERROR, Msg: err.Error()}
+		}
+		return obj
+	}}, nil
+}
+
+// 调用宿主函数,panic转换为脚本中的错误,不让它结束宿主进程
+func call(name string, fn reflect.Value, in []reflect.Value) (out []reflect.Value, errObj object.Object) {
+	defer recoverPanic(name, &errObj)
+	return fn.Call(in), nil
+}
+
+// 在defer中调用,宿主函数panic时把*res设为错误
+func recoverPanic(name string, res *object.Object) {
+	if r := recover(); r != nil {
+		*res = &object.Error{Kind: object.RUNTIME_ERROR, Msg: fmt.Sprintf("%s panicked: %v", name, r)}
+	}
+}
+
+func funcArgs(name string, t reflect.Type, params []object.Object) ([]reflect.Value, *object.Error) {
+	fixed := t.NumIn()
+	if t.IsVariadic() {
+		fixed--
+	}
+	if len(params) < fixed || !t.IsVariadic() && len(params) != fixed {
+		return nil, &object.Error{Kind: object.RUNTIME_ERROR, Msg: fmt.Sprintf("wrong number of arguments. got=%d, want=%d", len(params), fixed)}
+	}
+	in := make([]reflect.Value, len(params))
+	for i, param := range params {
+		var typ reflect.Type
+		if i < fixed {
+			typ = t.In(i)
+		} else {
+			typ = t.In(fixed).Elem()
+		}
+		arg := reflect.New(typ).Elem()
+		if err := fromObject(param, arg); err != nil {
+			return nil, &object.Error{Kind: object.RUNTIME_ERROR, Msg: fmt.Sprintf("argument %d to `%s`: %s", i+1, name, err)}
+		}
+		in[i] = arg
+	}
+	return in, nil
+}
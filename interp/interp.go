@@ -0,0 +1,226 @@
+// interp包供Go程序嵌入解释器,运行用户提供的脚本
+package interp
+
+import (
+	"context"
+	"fmt"
+	evaluator "my-interpreter/evaluator" //不要动
+	"my-interpreter/lexer"
+	"my-interpreter/object"
+	"my-interpreter/parser"
+	"strings"
+)
+
+// 解析失败时返回的错误
+type ParseError struct {
+	Errors []string
+}
+
+func (e *ParseError) Error() string {
+	return "parse error: " + strings.Join(e.Errors, "; ")
+}
+
+// 脚本求值得到object.Error时返回的错误,Kind区分超时,取消和各种资源限制
+// 脚本用throw抛出而未捕获时Kind为USER_ERROR,Value为抛出的值转换成的Go值
+type RuntimeError struct {
+	Kind   object.ErrorKind
+	Msg    string
+	Value  any
+	File   string //出错的位置,Line为0时未知
+	Line   int
+	Column int
+	Trace  []string //错误经过的脚本函数,由内向外
+}
+
+func (e *RuntimeError) Error() string {
+	return e.Msg
+}
+
+// 超时和取消可以用errors.Is与context中的错误比较
+func (e *RuntimeError) Unwrap() error {
+	switch e.Kind {
+	case object.TIMEOUT:
+		return context.DeadlineExceeded
+	case object.CANCELED:
+		return context.Canceled
+	}
+	return nil
+}
+
+// 一个解释器实例,各实例的全局变量互不影响
+type Interpreter struct {
+	env *object.Environment
+	cfg evaluator.Config
+	err error //选项中出现的第一个错误,由New之后的调用返回
+}
+
+type Option func(*Interpreter)
+
+// 设置全局变量
+func WithGlobal(name string, value any) Option {
+	return func(in *Interpreter) {
+		if err := in.Set(name, value); err != nil && in.err == nil {
+			in.err = err
+		}
+	}
+}
+
+// 设置全局常量,见SetConst
+func WithConst(name string, value any) Option {
+	return func(in *Interpreter) {
+		if err := in.SetConst(name, value); err != nil && in.err == nil {
+			in.err = err
+		}
+	}
+}
+
+// 把Go函数注册为脚本中可调用的内置函数
+func WithFunc(name string, fn any) Option {
+	return func(in *Interpreter) {
+		if err := in.Register(name, fn); err != nil && in.err == nil {
+			in.err = err
+		}
+	}
+}
+
+// 限制每次Eval和Call的运行时间,调用深度,求值步数和集合大小
+func WithLimits(limits evaluator.Limits) Option {
+	return func(in *Interpreter) {
+		in.cfg.Limits = limits
+	}
+}
+
+// 设置import的搜索路径
+func WithSearchPath(dirs ...string) Option {
+	return func(in *Interpreter) {
+		in.cfg.Importer.SearchPath = dirs
+	}
+}
+
+func New(opts ...Option) *Interpreter {
+	in := &Interpreter{env: object.NewEnvironment()}
+	//模块在同一个解释器的多次Eval之间只加载一次
+	in.cfg.Importer = evaluator.NewImporter()
+	for _, opt := range opts {
+		opt(in)
+	}
+	return in
+}
+
+// 在解释器的全局环境中求值src,返回最后一条语句的值
+func (in *Interpreter) Eval(ctx context.Context, src string) (any, error) {
+	obj, err := in.EvalObject(ctx, src)
+	if err != nil {
+		return nil, err
+	}
+	return ToValue(obj), nil
+}
+
+// 与Eval相同,但返回未经转换的object.Object
+func (in *Interpreter) EvalObject(ctx context.Context, src string) (object.Object, error) {
+	if in.err != nil {
+		return nil, in.err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	p := parser.NewParser(lexer.NewLexer(src))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, &ParseError{Errors: p.Errors()}
+	}
+	return result(evaluator.EvalContext(ctx, program, in.env, in.cfg))
+}
+
+// 调用名为fn的全局函数,参数和返回值自动转换
+func (in *Interpreter) Call(fn string, args ...any) (any, error) {
+	return in.CallContext(context.Background(), fn, args...)
+}
+
+func (in *Interpreter) CallContext(ctx context.Context, fn string, args ...any) (any, error) {
+	if in.err != nil {
+		return nil, in.err
+	}
+	f, ok := in.env.Get(fn)
+	if !ok {
+		return nil, fmt.Errorf("function not found: %s", fn)
+	}
+	params := make([]object.Object, len(args))
+	for i, arg := range args {
+		obj, err := ToObject(arg)
+		if err != nil {
+			return nil, err
+		}
+		params[i] = obj
+	}
+	obj, err := result(evaluator.ApplyFunctionContext(ctx, f, params, in.cfg))
+	if err != nil {
+		return nil, err
+	}
+	return ToValue(obj), nil
+}
+
+// 设置全局变量,value会被转换为object.Object
+func (in *Interpreter) Set(name string, value any) error {
+	obj, err := ToObject(value)
+	if err != nil {
+		return err
+	}
+	if in.env.IsConst(name) {
+		return fmt.Errorf("cannot reassign constant %s", name)
+	}
+	in.env.Set(name, obj)
+	return nil
+}
+
+// 设置全局常量,值被深层冻结,脚本不能重新绑定或修改它
+func (in *Interpreter) SetConst(name string, value any) error {
+	obj, err := ToObject(value)
+	if err != nil {
+		return err
+	}
+	if in.env.IsConst(name) {
+		return fmt.Errorf("cannot reassign constant %s", name)
+	}
+	in.env.SetConst(name, object.Freeze(obj))
+	return nil
+}
+
+// 读取全局变量并转换为Go值
+func (in *Interpreter) Get(name string) (any, bool) {
+	obj, ok := in.env.Get(name)
+	if !ok {
+		return nil, false
+	}
+	return ToValue(obj), true
+}
+
+// 读取全局变量并存入target指向的Go值,可以是结构体
+func (in *Interpreter) GetInto(name string, target any) error {
+	obj, ok := in.env.Get(name)
+	if !ok {
+		return fmt.Errorf("identifier not found: %s", name)
+	}
+	return FromObject(obj, target)
+}
+
+// 注册Go函数,参数和返回值按ToObject/FromObject的规则转换
+func (in *Interpreter) Register(name string, fn any) error {
+	builtin, err := wrapFunc(name, fn)
+	if err != nil {
+		return err
+	}
+	in.env.Set(name, builtin)
+	return nil
+}
+
+func result(obj object.Object) (object.Object, error) {
+	if errObj, ok := obj.(*object.Error); ok {
+		err := &RuntimeError{Kind: errObj.Kind, Msg: errObj.Msg, File: errObj.File, Line: errObj.Line, Column: errObj.Column, Trace: errObj.Trace}
+		if errObj.Value != nil {
+			err.Value = ToValue(errObj.Value)
+		}
+		return nil, err
+	}
+	return obj, nil
+}
@@ -0,0 +1,205 @@
+package interp
+
+import (
+	"context"
+	"errors"
+	"math"
+	evaluator "my-interpreter/evaluator" //不要动
+	"my-interpreter/object"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type rule struct {
+	Name    string
+	Limit   int
+	Tags    []string
+	Enabled bool   `interp:"enabled"`
+	secret  string //未导出字段不参与转换
+	Skipped string `interp:"-"`
+}
+
+func TestEval(t *testing.T) {
+	in := New(WithGlobal("limit", 10), WithGlobal("names", []string{"a", "b"}))
+	tests := []struct {
+		input    string
+		expected any
+	}{
+		{"limit * 2", int64(20)},
+		{`"x" + "y"`, "xy"},
+		{"limit > 5", true},
+		{"names", []any{"a", "b"}},
+		{`{"a": 1}`, map[string]any{"a": int64(1)}},
+		{`{1: true}`, map[any]any{int64(1): true}},
+		{"let unused = 1;", nil},
+	}
+	for _, tt := range tests {
+		got, err := in.Eval(context.Background(), tt.input)
+		if err != nil {
+			t.Errorf("Eval(%q) returned error: %s", tt.input, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("Eval(%q) wrong. expected=%#v, got=%#v", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestEvalErrors(t *testing.T) {
+	in := New()
+	_, err := in.Eval(context.Background(), "let = 5;")
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) {
+		t.Errorf("expected ParseError. got=%T (%v)", err, err)
+	}
+	_, err = in.Eval(context.Background(), "1 + true")
+	var runtimeErr *RuntimeError
+	if !errors.As(err, &runtimeErr) || runtimeErr.Msg != "type mismatch: INTEGER + BOOLEAN" {
+		t.Errorf("expected RuntimeError. got=%T (%v)", err, err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := in.Eval(ctx, "1"); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled. got=%v", err)
+	}
+	if _, err := New(WithFunc("bad", 5)).Eval(context.Background(), "1"); err == nil {
+		t.Errorf("expected error from invalid option")
+	}
+}
+
+func TestCallAndStructs(t *testing.T) {
+	in := New()
+	src := `let check = fn(r) { if (r["enabled"]) { len(r["Tags"]) } else { 0 } };
+	let make = fn(name) { {"Name": name, "Limit": 3, "Tags": ["x"], "enabled": true} };`
+	if _, err := in.Eval(context.Background(), src); err != nil {
+		t.Fatal(err)
+	}
+	got, err := in.Call("check", rule{Name: "r", Tags: []string{"a", "b"}, Enabled: true, secret: "s"})
+	if err != nil || got != int64(2) {
+		t.Errorf("Call(check) wrong. got=%v, err=%v", got, err)
+	}
+	if _, err := in.Call("missing"); err == nil {
+		t.Errorf("expected error calling missing function")
+	}
+
+	if _, err := in.Eval(context.Background(), `let made = make("built");`); err != nil {
+		t.Fatal(err)
+	}
+	var r rule
+	if err := in.GetInto("made", &r); err != nil {
+		t.Fatal(err)
+	}
+	expected := rule{Name: "built", Limit: 3, Tags: []string{"x"}, Enabled: true}
+	if !reflect.DeepEqual(r, expected) {
+		t.Errorf("GetInto wrong. expected=%+v, got=%+v", expected, r)
+	}
+
+	in.Set("cfg", rule{Name: "n", Skipped: "s"})
+	got, _ = in.Get("cfg")
+	m := got.(map[string]any)
+	if _, ok := m["Skipped"]; ok || m["Name"] != "n" || len(m) != 4 {
+		t.Errorf("struct converted wrong. got=%v", m)
+	}
+}
+
+func TestRegister(t *testing.T) {
+	in := New(
+		WithFunc("upper", strings.ToUpper),
+		WithFunc("sum", func(nums ...int) int {
+			total := 0
+			for _, n := range nums {
+				total += n
+			}
+			return total
+		}),
+		WithFunc("fail", func(msg string) (int, error) { return 0, errors.New(msg) }),
+		WithFunc("huge", func() uint64 { return math.MaxUint64 }),
+		WithFunc("crash", func() int { panic("bad state") }),
+		WithFunc("crashRaw", func(...object.Object) object.Object { panic(errors.New("nil map")) }),
+	)
+	tests := []struct {
+		input    string
+		expected any
+	}{
+		{`upper("abc")`, "ABC"},
+		{`sum(1, 2, 3)`, int64(6)},
+		{`sum()`, int64(0)},
+	}
+	for _, tt := range tests {
+		got, err := in.Eval(context.Background(), tt.input)
+		if err != nil || got != tt.expected {
+			t.Errorf("Eval(%q) wrong. expected=%v, got=%v, err=%v", tt.input, tt.expected, got, err)
+		}
+	}
+	errorTests := []struct {
+		input    string
+		expected string
+	}{
+		{`fail("boom")`, "boom"},
+		{`upper(1)`, "argument 1 to `upper`: cannot convert INTEGER to string"},
+		{`upper()`, "wrong number of arguments. got=0, want=1"},
+		{`huge()`, "18446744073709551615 overflows INTEGER"},
+		{`crash()`, "crash panicked: bad state"},
+		{`crashRaw()`, "crashRaw panicked: nil map"},
+	}
+	for _, tt := range errorTests {
+		_, err := in.Eval(context.Background(), tt.input)
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("Eval(%q) wrong error. expected=%q, got=%v", tt.input, tt.expected, err)
+		}
+	}
+}
+
+func TestLimits(t *testing.T) {
+	in := New(WithLimits(evaluator.Limits{MaxDepth: 50, Timeout: 20 * time.Millisecond}))
+	_, err := in.Eval(context.Background(), "let f = fn(x) { 1 + f(x) }; f(1)")
+	var runtimeErr *RuntimeError
+	if !errors.As(err, &runtimeErr) || runtimeErr.Kind != object.DEPTH_LIMIT {
+		t.Errorf("expected DEPTH_LIMIT error. got=%v", err)
+	}
+	_, err = in.Eval(context.Background(), "let g = fn(n) { if (n == 0) { 0 } else { g(n - 1) + g(n - 1) } }; g(40)")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected timeout. got=%v", err)
+	}
+	if _, err := in.Call("g", 30); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected timeout from Call. got=%v", err)
+	}
+}
+
+func TestThrownErrors(t *testing.T) {
+	in := New()
+	_, err := in.Eval(context.Background(), "let check = fn(x) { if (x < 0) { throw {\"bad\": x} } x };\ncheck(-1)")
+	var runtimeErr *RuntimeError
+	if !errors.As(err, &runtimeErr) || runtimeErr.Kind != object.USER_ERROR {
+		t.Fatalf("expected USER_ERROR. got=%v", err)
+	}
+	if !reflect.DeepEqual(runtimeErr.Value, map[string]any{"bad": int64(-1)}) {
+		t.Errorf("thrown value wrong. got=%#v", runtimeErr.Value)
+	}
+	if runtimeErr.Line != 1 || runtimeErr.Column != 34 {
+		t.Errorf("position wrong. got=%d:%d", runtimeErr.Line, runtimeErr.Column)
+	}
+	_, err = in.Eval(context.Background(), "1 + true")
+	if !errors.As(err, &runtimeErr) || runtimeErr.Value != nil || runtimeErr.Kind != object.RUNTIME_ERROR {
+		t.Errorf("expected RUNTIME_ERROR without value. got=%+v", runtimeErr)
+	}
+}
+
+func TestConst(t *testing.T) {
+	in := New(WithConst("config", map[string]any{"name": "x", "tags": []string{"a"}}))
+	got, err := in.Eval(context.Background(), `[isFrozen(config), isFrozen(config["tags"])] == [true, true]`)
+	if err != nil || got != true {
+		t.Errorf("config not frozen. got=%v, err=%v", got, err)
+	}
+	if _, err := in.Eval(context.Background(), `let config = 1;`); err == nil || err.Error() != "cannot reassign constant config" {
+		t.Errorf("expected reassignment error. got=%v", err)
+	}
+	if err := in.Set("config", 1); err == nil {
+		t.Errorf("expected error from Set on a constant")
+	}
+	if _, err := New(WithConst("f", func() {}), WithConst("f", 1)).Eval(context.Background(), "1"); err == nil {
+		t.Errorf("expected error from duplicate WithConst")
+	}
+}
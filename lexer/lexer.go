@@ -7,10 +7,12 @@ type Lexer struct {
 	index     int
 	nextIndex int
 	char      byte
+	line      int //l.char所在的行和列
+	column    int
 }
 
 func NewLexer(input string) *Lexer {
-	l := Lexer{input: input, index: -1, nextIndex: 0}
+	l := Lexer{input: input, index: -1, nextIndex: 0, line: 1}
 	l.readChar()
 	return &l
 }
@@ -18,6 +20,8 @@ func NewLexer(input string) *Lexer {
 func (l *Lexer) NextToken() token.Token {
 	var tok token.Token
 	l.skipWhitespace()
+	//记录token第一个字符的位置
+	line, column := l.line, l.column
 	switch l.char {
 	case '"':
 		tok.Type = token.STRING
@@ -29,13 +33,23 @@ func (l *Lexer) NextToken() token.Token {
 			s := string(ch) + string(l.char)
 			tok.Type = token.EQ
 			tok.Literal = s
+		} else if l.peekChar() == '>' {
+			l.readChar()
+			tok.Type = token.ARROW
+			tok.Literal = "=>"
 		} else {
 			tok = newToken(token.ASSIGN, l.char)
 		}
 	case '+':
 		tok = newToken(token.PLUS, l.char)
 	case '-':
-		tok = newToken(token.MINUS, l.char)
+		if l.peekChar() == '>' {
+			l.readChar()
+			tok.Type = token.RARROW
+			tok.Literal = "->"
+		} else {
+			tok = newToken(token.MINUS, l.char)
+		}
 	case '*':
 		tok = newToken(token.ASTERISK, l.char)
 	case '/':
@@ -58,6 +72,15 @@ func (l *Lexer) NextToken() token.Token {
 		tok = newToken(token.COMMA, l.char)
 	case ':':
 		tok = newToken(token.COLON, l.char)
+	case '.':
+		if l.peekChar() == '.' && l.nextIndex+1 < len(l.input) && l.input[l.nextIndex+1] == '.' {
+			l.readChar()
+			l.readChar()
+			tok.Type = token.ELLIPSIS
+			tok.Literal = "..."
+		} else {
+			tok = newToken(token.DOT, l.char)
+		}
 	case ';':
 		tok = newToken(token.SEMICOLON, l.char)
 	case '(':
@@ -81,21 +104,29 @@ func (l *Lexer) NextToken() token.Token {
 			tok.Literal = s
 			//判断是关键字还是标识符
 			tok.Type = token.LookupIdentifier(s)
+			tok.Line, tok.Column = line, column
 			return tok
 		} else if token.IsDigit(l.char) {
 			s := l.readNumber()
 			tok.Literal = s
 			tok.Type = token.INT
+			tok.Line, tok.Column = line, column
 			return tok
 		} else {
 			tok = newToken(token.ILLEGAL, l.char)
 		}
 	}
 	l.readChar()
+	tok.Line, tok.Column = line, column
 	return tok
 }
 
 func (l *Lexer) readChar() byte {
+	if l.char == '\n' {
+		l.line++
+		l.column = 0
+	}
+	l.column++
 	l.index++
 	if l.nextIndex >= len(l.input) {
 		l.char = 0
@@ -144,7 +175,7 @@ func (l *Lexer) readString() string {
 }
 
 func (l *Lexer) peekChar() byte {
-	if l.index >= len(l.input) {
+	if l.nextIndex >= len(l.input) {
 		return 0
 	} else {
 		//只是窥视,并未读取
@@ -131,3 +131,62 @@ var (
 	else_   = NewExpect(token.ELSE, "else")
 	return_ = NewExpect(token.RETURN, "return")
 )
+
+func TestPositions(t *testing.T) {
+	input := "let x = 5;\n  x == \"ab\"\n\nfoo"
+	expected := []struct {
+		literal      string
+		line, column int
+	}{
+		{"let", 1, 1}, {"x", 1, 5}, {"=", 1, 7}, {"5", 1, 9}, {";", 1, 10},
+		{"x", 2, 3}, {"==", 2, 5}, {"ab", 2, 8},
+		{"foo", 4, 1}, {"", 4, 4},
+	}
+	l := NewLexer(input)
+	for i, tt := range expected {
+		tok := l.NextToken()
+		if tok.Literal != tt.literal || tok.Line != tt.line || tok.Column != tt.column {
+			t.Errorf("tests[%d]: expected %q at %d:%d. got %q at %d:%d",
+				i, tt.literal, tt.line, tt.column, tok.Literal, tok.Line, tok.Column)
+		}
+	}
+}
+
+func TestMatchTokens(t *testing.T) {
+	input := `match (x) { [a, ...rest] => a, _ => m.b }`
+	expected := []Expect{
+		NewExpect(token.MATCH, "match"), NewExpect(token.LPAREN, "("), NewExpect(token.IDENT, "x"),
+		NewExpect(token.RPAREN, ")"), NewExpect(token.LBRACE, "{"), NewExpect(token.LBRACKET, "["),
+		NewExpect(token.IDENT, "a"), NewExpect(token.COMMA, ","), NewExpect(token.ELLIPSIS, "..."),
+		NewExpect(token.IDENT, "rest"), NewExpect(token.RBRACKET, "]"), NewExpect(token.ARROW, "=>"),
+		NewExpect(token.IDENT, "a"), NewExpect(token.COMMA, ","), NewExpect(token.IDENT, "_"),
+		NewExpect(token.ARROW, "=>"), NewExpect(token.IDENT, "m"), NewExpect(token.DOT, "."),
+		NewExpect(token.IDENT, "b"), NewExpect(token.RBRACE, "}"), NewExpect(token.EOF, ""),
+	}
+	l := NewLexer(input)
+	for i, tt := range expected {
+		tok := l.NextToken()
+		if tok.Type != tt.Type || tok.Literal != tt.Literal {
+			t.Errorf("tests[%d]: expected %s %q. got %s %q", i, tt.Type, tt.Literal, tok.Type, tok.Literal)
+		}
+	}
+}
+
+func TestTypeAnnotationTokens(t *testing.T) {
+	input := `fn(a: [int]) -> bool { a - -1 }`
+	expected := []Expect{
+		NewExpect(token.FUNCTION, "fn"), NewExpect(token.LPAREN, "("), NewExpect(token.IDENT, "a"),
+		NewExpect(token.COLON, ":"), NewExpect(token.LBRACKET, "["), NewExpect(token.IDENT, "int"),
+		NewExpect(token.RBRACKET, "]"), NewExpect(token.RPAREN, ")"), NewExpect(token.RARROW, "->"),
+		NewExpect(token.IDENT, "bool"), NewExpect(token.LBRACE, "{"), NewExpect(token.IDENT, "a"),
+		NewExpect(token.MINUS, "-"), NewExpect(token.MINUS, "-"), NewExpect(token.INT, "1"),
+		NewExpect(token.RBRACE, "}"), NewExpect(token.EOF, ""),
+	}
+	l := NewLexer(input)
+	for i, tt := range expected {
+		tok := l.NextToken()
+		if tok.Type != tt.Type || tok.Literal != tt.Literal {
+			t.Errorf("tests[%d]: expected %s %q. got %s %q", i, tt.Type, tt.Literal, tok.Type, tok.Literal)
+		}
+	}
+}
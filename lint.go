@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"my-interpreter/lexer"
+	"my-interpreter/lint"
+	"my-interpreter/parser"
+	"os"
+)
+
+// 检查脚本文件,有诊断时返回1,参数或配置有误时返回2
+// 没有指定-config时使用当前目录中的.milint.json,不存在则启用所有规则
+func lintFiles(args []string) int {
+	flags := flag.NewFlagSet("lint", flag.ContinueOnError)
+	configPath := flags.String("config", "", "config file, default "+lint.CONFIG_FILE)
+	format := flags.String("format", "text", "output format: text or json")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() == 0 || (*format != "text" && *format != "json") {
+		fmt.Fprint(os.Stderr, usage)
+		return 2
+	}
+	cfg := lint.DefaultConfig()
+	path := *configPath
+	if path == "" {
+		if _, err := os.Stat(lint.CONFIG_FILE); err == nil {
+			path = lint.CONFIG_FILE
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+	}
+	if path != "" {
+		var err error
+		if cfg, err = lint.LoadConfig(path); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+	}
+
+	diagnostics := []lint.Diagnostic{}
+	for _, file := range flags.Args() {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+		p := parser.NewParser(lexer.NewLexer(string(data)))
+		program := p.ParseProgram()
+		found := lint.SyntaxErrors(p)
+		if len(found) == 0 {
+			found = lint.Lint(program, cfg)
+		}
+		for _, d := range found {
+			d.File = file
+			diagnostics = append(diagnostics, d)
+		}
+	}
+
+	if *format == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(diagnostics)
+	} else {
+		for _, d := range diagnostics {
+			fmt.Println(d)
+		}
+	}
+	if len(diagnostics) > 0 {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,65 @@
+package lint
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// 默认的配置文件,在当前目录中查找
+const CONFIG_FILE = ".milint.json"
+
+// 配置文件是JSON,如{"rules": {"shadow": false, "unused-param": true}}
+// 没有列出的规则是否启用由default决定,省略default时启用
+type Config struct {
+	Default *bool           `json:"default,omitempty"`
+	Rules   map[string]bool `json:"rules"`
+}
+
+// 启用所有规则
+func DefaultConfig() *Config {
+	return &Config{Rules: map[string]bool{}}
+}
+
+func (c *Config) Enabled(rule string) bool {
+	if enabled, ok := c.Rules[rule]; ok {
+		return enabled
+	}
+	return c.Default == nil || *c.Default
+}
+
+// 解析配置,有未知的字段或规则时报错
+func ParseConfig(data []byte) (*Config, error) {
+	cfg := DefaultConfig()
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(cfg); err != nil {
+		return nil, err
+	}
+	known := map[string]bool{}
+	for _, rule := range Rules() {
+		known[rule] = true
+	}
+	for rule := range cfg.Rules {
+		if !known[rule] {
+			return nil, fmt.Errorf("unknown rule %q", rule)
+		}
+	}
+	if cfg.Rules == nil {
+		cfg.Rules = map[string]bool{}
+	}
+	return cfg, nil
+}
+
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := ParseConfig(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return cfg, nil
+}
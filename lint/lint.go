@@ -0,0 +1,297 @@
+// lint包检查能通过语法分析但很可能有错的代码
+// 除了resolver报告的作用域问题,还检查不可达的代码,常量条件,字面量类型不匹配,未使用的参数和内置函数的参数个数
+package lint
+
+import (
+	"fmt"
+	"my-interpreter/ast"
+	builtins "my-interpreter/evaluator"
+	"my-interpreter/object"
+	"my-interpreter/parser"
+	"my-interpreter/resolver"
+	"sort"
+)
+
+// 规则的名字,resolver的诊断以其Code作为规则名
+const (
+	UNREACHABLE        = "unreachable"
+	CONSTANT_CONDITION = "constant-condition"
+	TYPE_MISMATCH      = "type-mismatch"
+	UNUSED_PARAM       = "unused-param"
+	BUILTIN_ARITY      = "builtin-arity"
+)
+
+// 语法错误的规则名,不在Rules()中,不能在配置中关闭
+const SYNTAX = "syntax"
+
+// 所有规则,按字典序排列
+func Rules() []string {
+	rules := []string{
+		UNREACHABLE, CONSTANT_CONDITION, TYPE_MISMATCH, UNUSED_PARAM, BUILTIN_ARITY,
+		resolver.UNDEFINED, resolver.UNUSED, resolver.SHADOW, resolver.DUPLICATE_PARAM,
+	}
+	sort.Strings(rules)
+	return rules
+}
+
+type Diagnostic struct {
+	File     string            `json:"file,omitempty"`
+	Line     int               `json:"line"`
+	Column   int               `json:"column"`
+	Severity resolver.Severity `json:"severity"`
+	Rule     string            `json:"rule"`
+	Msg      string            `json:"message"`
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%s:%d:%d: %s: %s (%s)", d.File, d.Line, d.Column, d.Severity, d.Msg, d.Rule)
+}
+
+// 语法分析器报告的错误,与Lint的诊断格式相同,供命令行以同样的方式输出
+func SyntaxErrors(p *parser.Parser) []Diagnostic {
+	var diagnostics []Diagnostic
+	for i, msg := range p.Errors() {
+		tok := p.ErrorPositions()[i]
+		diagnostics = append(diagnostics, Diagnostic{
+			Line: tok.Line, Column: tok.Column, Severity: resolver.ERROR, Rule: SYNTAX, Msg: msg,
+		})
+	}
+	return diagnostics
+}
+
+type linter struct {
+	cfg         *Config
+	diagnostics []Diagnostic
+}
+
+// 检查没有语法错误的program,只报告cfg中启用的规则,按位置排列
+// 会和resolver一样在program的标识符上记下Binding
+func Lint(program *ast.Program, cfg *Config) []Diagnostic {
+	if cfg == nil {
+		cfg = DefaultConfig()
+	}
+	l := &linter{cfg: cfg}
+	for _, d := range resolver.Resolve(program, builtins.BuiltinNames()) {
+		if cfg.Enabled(d.Code) {
+			l.diagnostics = append(l.diagnostics, Diagnostic{
+				Line: d.Line, Column: d.Column, Severity: d.Severity, Rule: d.Code, Msg: d.Msg,
+			})
+		}
+	}
+	ast.Inspect(program, l.visit)
+	sort.SliceStable(l.diagnostics, func(i, j int) bool {
+		a, b := l.diagnostics[i], l.diagnostics[j]
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+	return l.diagnostics
+}
+
+func (l *linter) report(node ast.Node, rule, format string, a ...any) {
+	if !l.cfg.Enabled(rule) {
+		return
+	}
+	tok := ast.Pos(node)
+	l.diagnostics = append(l.diagnostics, Diagnostic{
+		Line:     tok.Line,
+		Column:   tok.Column,
+		Severity: resolver.WARNING,
+		Rule:     rule,
+		Msg:      fmt.Sprintf(format, a...),
+	})
+}
+
+func (l *linter) visit(node ast.Node) bool {
+	switch node := node.(type) {
+	case *ast.Program:
+		l.checkUnreachable(node.Statements)
+	case *ast.BlockStatement:
+		l.checkUnreachable(node.Statements)
+	case *ast.IfExpression:
+		l.checkCondition(node)
+	case *ast.InfixExpression:
+		l.checkOperandTypes(node)
+	case *ast.FunctionLiteral:
+		l.checkUnusedParams(node)
+	case *ast.CallExpression:
+		l.checkBuiltinCall(node)
+	}
+	return true
+}
+
+// return和throw之后的语句不会执行,每个语句列表只报告第一条
+func (l *linter) checkUnreachable(statements []ast.Statement) {
+	for i, stmt := range statements {
+		switch stmt.(type) {
+		case *ast.ReturnStatement, *ast.ThrowStatement:
+			if i+1 < len(statements) {
+				l.report(statements[i+1], UNREACHABLE, "unreachable code")
+			}
+			return
+		}
+	}
+}
+
+// 条件只由字面量和运算符组成时,其结果在求值前就能确定
+func (l *linter) checkCondition(node *ast.IfExpression) {
+	if !isConstant(node.Condition) {
+		return
+	}
+	res := builtins.Eval(node.Condition, object.NewEnvironment())
+	if _, ok := res.(*object.Error); ok {
+		//运算本身出错,由type-mismatch报告
+		return
+	}
+	truthy := res != builtins.False && res != builtins.Nil
+	l.report(node, CONSTANT_CONDITION, "if condition is always %t", truthy)
+}
+
+func isConstant(expr ast.Expression) bool {
+	switch expr := expr.(type) {
+	case *ast.IntLiteral, *ast.BoolLiteral, *ast.StrLiteral:
+		return true
+	case *ast.PrefixExpression:
+		return isConstant(expr.Right)
+	case *ast.InfixExpression:
+		return isConstant(expr.Left) && isConstant(expr.Right)
+	}
+	return false
+}
+
+// 字面量的类型,其他表达式的类型要到求值时才知道,返回空串
+func literalType(expr ast.Expression) object.ObjectType {
+	switch expr.(type) {
+	case *ast.IntLiteral:
+		return object.INTEGER
+	case *ast.BoolLiteral:
+		return object.BOOLEAN
+	case *ast.StrLiteral:
+		return object.STRING
+	case *ast.ArrLiteral:
+		return object.ARRAY
+	case *ast.MapLiteral:
+		return object.MAP
+	case *ast.FunctionLiteral:
+		return object.FUNCTION
+	}
+	return ""
+}
+
+// 类型不同的字面量用==和!=比较时结果固定,用其他运算符时求值会报错
+func (l *linter) checkOperandTypes(node *ast.InfixExpression) {
+	left, right := literalType(node.Left), literalType(node.Right)
+	if left == "" || right == "" || left == right {
+		return
+	}
+	switch op := node.Token.Literal; op {
+	case "==", "!=":
+		l.report(node, TYPE_MISMATCH, "comparison of %s and %s is always %t", left, right, op == "!=")
+	default:
+		l.report(node, TYPE_MISMATCH, "type mismatch: %s %s %s", left, op, right)
+	}
+}
+
+// 以下划线开头的参数表示有意不使用
+// 只按名字查找引用,内层函数声明了同名参数时外层参数也视为已使用
+func (l *linter) checkUnusedParams(fn *ast.FunctionLiteral) {
+	params := append([]*ast.Identifier{}, fn.Parameters...)
+	if fn.Rest != nil {
+		params = append(params, fn.Rest)
+	}
+	if len(params) == 0 {
+		return
+	}
+	used := map[string]bool{}
+	for _, def := range fn.Defaults {
+		references(def, used)
+	}
+	references(fn.Body, used)
+	for _, param := range params {
+		name := param.Token.Literal
+		if !used[name] && name[0] != '_' {
+			l.report(param, UNUSED_PARAM, "parameter %s is never used", name)
+		}
+	}
+}
+
+// 把node中引用的名字加入used,声明中的名字和字段名不算引用
+func references(node ast.Node, used map[string]bool) {
+	decls := map[*ast.Identifier]bool{}
+	ast.Inspect(node, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.LetStatement:
+			decls[n.Name] = true
+		case *ast.FunctionStatement:
+			decls[n.Name] = true
+		case *ast.FunctionLiteral:
+			for _, param := range n.Parameters {
+				decls[param] = true
+			}
+			decls[n.Rest] = true
+		case *ast.StructStatement:
+			decls[n.Name] = true
+			for _, f := range n.Fields {
+				decls[f] = true
+			}
+		case *ast.StructLiteral:
+			for _, f := range n.Fields {
+				decls[f] = true
+			}
+		case *ast.ImportStatement:
+			decls[n.Alias] = true
+		case *ast.TryExpression:
+			decls[n.Param] = true
+		case *ast.SelectorExpression:
+			decls[n.Selector] = true
+		case *ast.NamedArgument:
+			decls[n.Name] = true
+		case *ast.BindingPattern:
+			decls[n.Name] = true
+		case *ast.ArrayPattern:
+			decls[n.Rest] = true
+		case *ast.Identifier:
+			if !decls[n] {
+				used[n.Token.Literal] = true
+			}
+		}
+		return true
+	})
+}
+
+// 调用没有被重新绑定的内置函数时检查参数个数,展开参数的个数要到求值时才知道
+func (l *linter) checkBuiltinCall(node *ast.CallExpression) {
+	ident, ok := node.Function.(*ast.Identifier)
+	if !ok || ident.Binding != nil {
+		return
+	}
+	name := ident.Token.Literal
+	sig, ok := builtins.BuiltinSignature(name)
+	if !ok {
+		return
+	}
+	for _, arg := range node.Arguments {
+		switch arg := arg.(type) {
+		case *ast.SpreadExpression:
+			return
+		case *ast.NamedArgument:
+			l.report(arg, BUILTIN_ARITY, "builtin %s does not accept named arguments", name)
+			return
+		}
+	}
+	got := len(node.Arguments)
+	if got >= sig.MinArgs && (sig.MaxArgs < 0 || got <= sig.MaxArgs) {
+		return
+	}
+	var want string
+	switch {
+	case sig.MaxArgs < 0:
+		want = fmt.Sprintf("at least %d", sig.MinArgs)
+	case sig.MinArgs == sig.MaxArgs:
+		want = fmt.Sprint(sig.MinArgs)
+	default:
+		want = fmt.Sprintf("%d to %d", sig.MinArgs, sig.MaxArgs)
+	}
+	l.report(node, BUILTIN_ARITY, "%s%s called with %d arguments, want %s", name, sig, got, want)
+}
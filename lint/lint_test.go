@@ -0,0 +1,104 @@
+package lint
+
+import (
+	"my-interpreter/lexer"
+	"my-interpreter/parser"
+	"strings"
+	"testing"
+)
+
+func lint(t *testing.T, input string, cfg *Config) []string {
+	t.Helper()
+	p := parser.NewParser(lexer.NewLexer(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser has errors for %q: %v", input, p.Errors())
+	}
+	var got []string
+	for _, d := range Lint(program, cfg) {
+		got = append(got, d.String())
+	}
+	return got
+}
+
+func TestRules(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{`let f = fn(x) { return x; x + 1 };`, []string{":1:27: warning: unreachable code (unreachable)"}},
+		{`let f = fn(x) { throw x; };`, nil},
+		{"return 1;\nlet a = 2;", []string{":2:1: warning: unreachable code (unreachable)"}},
+		{`if (true) { 1 }`, []string{":1:1: warning: if condition is always true (constant-condition)"}},
+		{`if (1 > 2) { 1 } else { 2 }`, []string{":1:1: warning: if condition is always false (constant-condition)"}},
+		{`if (!"a") { 1 }`, []string{":1:1: warning: if condition is always false (constant-condition)"}},
+		{`let a = 1; if (a > 2) { 1 }`, nil},
+		{`1 + "a"`, []string{`:1:1: warning: type mismatch: INTEGER + STRING (type-mismatch)`}},
+		{`1 == "1"`, []string{`:1:1: warning: comparison of INTEGER and STRING is always false (type-mismatch)`}},
+		{`[1] != {}`, []string{`:1:1: warning: comparison of ARRAY and MAP is always true (type-mismatch)`}},
+		{`let a = 1; a + "a"`, nil},
+		{`let f = fn(x, y, ...rest) { x };`, []string{
+			":1:15: warning: parameter y is never used (unused-param)",
+			":1:21: warning: parameter rest is never used (unused-param)",
+		}},
+		{`let f = fn(_x, y = 1) { 2 };`, []string{":1:16: warning: parameter y is never used (unused-param)"}},
+		{`let f = fn(x, y = x) { y };`, nil},
+		{`let f = fn(x) { let g = fn() { x }; g };`, nil},
+		{`let f = fn(x) { {"x": 1}.x };`, []string{":1:12: warning: parameter x is never used (unused-param)"}},
+		{`len(1, 2)`, []string{":1:1: warning: len(value) called with 2 arguments, want 1 (builtin-arity)"}},
+		{`error()`, []string{":1:1: warning: error(message, data?) called with 0 arguments, want 1 to 2 (builtin-arity)"}},
+		{`len(value: "a")`, []string{":1:5: warning: builtin len does not accept named arguments (builtin-arity)"}},
+		{`len(...[1, 2]); prints(); push([], 1)`, nil},
+		{`let len = fn(a, b) { a + b }; len(1, 2)`, nil},
+		{`let f = fn() { let unused = 1; nope };`, []string{
+			":1:20: warning: variable unused declared and not used (unused)",
+			":1:32: error: undefined: nope (undefined)",
+		}},
+	}
+	for _, tt := range tests {
+		got := lint(t, tt.input, nil)
+		if strings.Join(got, "\n") != strings.Join(tt.expected, "\n") {
+			t.Errorf("%q: expected %q. got=%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestSyntaxErrors(t *testing.T) {
+	p := parser.NewParser(lexer.NewLexer("let a = 1;\nlet b = ;"))
+	p.ParseProgram()
+	var got []string
+	for _, d := range SyntaxErrors(p) {
+		got = append(got, d.String())
+	}
+	expected := []string{":2:9: error: no prefix parse function for ; found (syntax)"}
+	if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expected %q. got=%q", expected, got)
+	}
+}
+
+func TestConfig(t *testing.T) {
+	input := `let f = fn(x) { return 1; if (true) { x } };`
+	cfg, err := ParseConfig([]byte(`{"rules": {"unreachable": false}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := lint(t, input, cfg)
+	if len(got) != 1 || !strings.HasSuffix(got[0], "(constant-condition)") {
+		t.Errorf("unreachable should be disabled. got=%q", got)
+	}
+
+	cfg, err = ParseConfig([]byte(`{"default": false, "rules": {"unreachable": true}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got = lint(t, input, cfg)
+	if len(got) != 1 || !strings.HasSuffix(got[0], "(unreachable)") {
+		t.Errorf("only unreachable should be enabled. got=%q", got)
+	}
+
+	for _, bad := range []string{`{"rules": {"nope": true}}`, `{"rule": {}}`, `[`} {
+		if _, err := ParseConfig([]byte(bad)); err == nil {
+			t.Errorf("ParseConfig(%s) should fail", bad)
+		}
+	}
+}
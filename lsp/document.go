@@ -0,0 +1,166 @@
+package lsp
+
+import (
+	"my-interpreter/ast"
+	builtins "my-interpreter/evaluator"
+	"my-interpreter/lexer"
+	"my-interpreter/parser"
+	"my-interpreter/resolver"
+	"my-interpreter/token"
+	"my-interpreter/typecheck"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// 打开的文档及其分析结果
+type document struct {
+	uri         string
+	version     int
+	text        string
+	lines       []string
+	program     *ast.Program //没有语法错误时的语法树,否则为nil
+	lastGood    *ast.Program //最近一次没有语法错误的语法树,用于补全正在编辑的文档
+	defs        map[*ast.Identifier]*ast.Identifier
+	diagnostics []Diagnostic
+}
+
+// 分析文档的新内容,prev为修改前的文档
+func newDocument(uri, text string, version int, prev *document) *document {
+	d := &document{uri: uri, version: version, text: text, lines: strings.Split(text, "\n")}
+	if prev != nil {
+		d.lastGood = prev.lastGood
+	}
+	d.diagnostics = []Diagnostic{}
+	p := parser.NewParser(lexer.NewLexer(text))
+	program := p.ParseProgram()
+	for i, msg := range p.Errors() {
+		d.diagnostics = append(d.diagnostics, Diagnostic{Range: d.tokenRange(p.ErrorPositions()[i]), Severity: SeverityError, Source: source, Message: msg})
+	}
+	for i, msg := range p.Warnings() {
+		tok := p.WarningPositions()[i]
+		d.diagnostics = append(d.diagnostics, Diagnostic{Range: d.pointRange(tok.Line, tok.Column), Severity: SeverityWarning, Source: source, Message: msg})
+	}
+	if len(p.Errors()) != 0 {
+		return d
+	}
+	d.program, d.lastGood = program, program
+	diagnostics, defs := resolver.Analyze(program, builtins.BuiltinNames())
+	d.defs = defs
+	for _, diag := range diagnostics {
+		severity := SeverityWarning
+		if diag.Severity == resolver.ERROR {
+			severity = SeverityError
+		}
+		d.diagnostics = append(d.diagnostics, Diagnostic{
+			Range: d.pointRange(diag.Line, diag.Column), Severity: severity, Code: diag.Code, Source: source, Message: diag.Msg,
+		})
+	}
+	for _, diag := range typecheck.Check(program) {
+		d.diagnostics = append(d.diagnostics, Diagnostic{
+			Range: d.pointRange(diag.Line, diag.Column), Severity: SeverityError, Source: source, Message: diag.Msg,
+		})
+	}
+	return d
+}
+
+// 诊断的来源
+const source = "my-interpreter"
+
+// 从1开始按字节计的行列转换为协议中的位置
+func (d *document) position(line, column int) Position {
+	if line < 1 || line > len(d.lines) {
+		return Position{Line: max(line-1, 0)}
+	}
+	text := d.lines[line-1]
+	if column-1 < len(text) {
+		text = text[:max(column-1, 0)]
+	}
+	return Position{Line: line - 1, Character: len(utf16.Encode([]rune(text)))}
+}
+
+// 协议中的位置转换为从1开始按字节计的行列
+func (d *document) offset(pos Position) (int, int) {
+	if pos.Line < 0 || pos.Line >= len(d.lines) {
+		return pos.Line + 1, 1
+	}
+	text := d.lines[pos.Line]
+	units, i := 0, 0
+	for i < len(text) && units < pos.Character {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		units += utf16.RuneLen(r)
+		i += size
+	}
+	return pos.Line + 1, i + 1
+}
+
+// token在源码中的范围,字符串字面量包括引号
+func (d *document) tokenRange(tok token.Token) Range {
+	length := len(tok.Literal)
+	if tok.Type == token.STRING {
+		length += 2
+	}
+	return Range{Start: d.position(tok.Line, tok.Column), End: d.position(tok.Line, tok.Column+length)}
+}
+
+// 只有起始位置的诊断标出从这里开始的单词,不是单词时标出一个字符
+func (d *document) pointRange(line, column int) Range {
+	length := 1
+	if line >= 1 && line <= len(d.lines) && column >= 1 {
+		text := d.lines[line-1]
+		i := column - 1
+		for i < len(text) && (token.IsLetter(text[i]) || token.IsDigit(text[i])) {
+			i++
+		}
+		length = max(i-(column-1), 1)
+	}
+	return Range{Start: d.position(line, column), End: d.position(line, column+length)}
+}
+
+// 整个文档的范围
+func (d *document) fullRange() Range {
+	last := len(d.lines) - 1
+	return Range{End: Position{Line: last, Character: len(utf16.Encode([]rune(d.lines[last])))}}
+}
+
+// 光标前正在输入的单词
+func (d *document) wordBefore(line, column int) string {
+	if line < 1 || line > len(d.lines) {
+		return ""
+	}
+	text := d.lines[line-1]
+	end := min(column-1, len(text))
+	start := end
+	for start > 0 && token.IsLetter(text[start-1]) {
+		start--
+	}
+	return text[start:end]
+}
+
+// 位于line行column列的名字,光标紧跟在名字之后时也算
+func identAt(program *ast.Program, line, column int) *ast.Identifier {
+	var res *ast.Identifier
+	ast.Inspect(program, func(n ast.Node) bool {
+		if res != nil {
+			return false
+		}
+		if ident, ok := n.(*ast.Identifier); ok {
+			tok := ident.Token
+			if tok.Line == line && tok.Column <= column && column <= tok.Column+len(tok.Literal) {
+				res = ident
+			}
+		}
+		return true
+	})
+	return res
+}
+
+func before(a, b token.Token) bool {
+	return a.Line < b.Line || a.Line == b.Line && a.Column < b.Column
+}
+
+// 位置是否在start和end之间,end的Line为0表示没有结束位置
+func within(line, column int, start, end token.Token) bool {
+	pos := token.Token{Line: line, Column: column}
+	return !before(pos, start) && (end.Line == 0 || !before(end, pos))
+}
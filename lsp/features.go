@@ -0,0 +1,282 @@
+package lsp
+
+import (
+	"my-interpreter/ast"
+	builtins "my-interpreter/evaluator"
+	"my-interpreter/format"
+	"my-interpreter/token"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// 光标处的名字的声明
+func (d *document) definition(pos Position) *Location {
+	ident := d.identAt(pos)
+	if ident == nil || d.defs[ident] == nil {
+		return nil
+	}
+	return &Location{URI: d.uri, Range: d.tokenRange(d.defs[ident].Token)}
+}
+
+// 与光标处的名字引用同一声明的所有名字,按位置排列
+func (d *document) references(pos Position, includeDeclaration bool) []Location {
+	ident := d.identAt(pos)
+	if ident == nil || d.defs[ident] == nil {
+		return nil
+	}
+	decl := d.defs[ident]
+	var idents []*ast.Identifier
+	for use, def := range d.defs {
+		if def == decl && (includeDeclaration || use != decl) {
+			idents = append(idents, use)
+		}
+	}
+	sort.Slice(idents, func(i, j int) bool {
+		return before(idents[i].Token, idents[j].Token)
+	})
+	locations := []Location{}
+	for _, use := range idents {
+		locations = append(locations, Location{URI: d.uri, Range: d.tokenRange(use.Token)})
+	}
+	return locations
+}
+
+func (d *document) identAt(pos Position) *ast.Identifier {
+	if d.program == nil {
+		return nil
+	}
+	line, column := d.offset(pos)
+	return identAt(d.program, line, column)
+}
+
+// 光标处的名字的声明或内置函数的签名
+func (d *document) hover(pos Position) *Hover {
+	ident := d.identAt(pos)
+	if ident == nil {
+		return nil
+	}
+	var text string
+	if decl := d.defs[ident]; decl != nil {
+		text = "```\n" + describe(d.program, decl) + "\n```"
+	} else if sig, ok := builtins.BuiltinSignature(ident.Token.Literal); ok {
+		text = "```\n" + ident.Token.Literal + sig.String() + "\n```\n\n" + sig.Doc
+	} else {
+		return nil
+	}
+	return &Hover{Contents: MarkupContent{Kind: "markdown", Value: text}, Range: d.tokenRange(ident.Token)}
+}
+
+// 声明decl的语句的简短描述,如let x: int或fn f(x) -> int
+func describe(program *ast.Program, decl *ast.Identifier) string {
+	res := decl.Token.Literal
+	ast.Inspect(program, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.LetStatement:
+			if n.Name != decl {
+				break
+			}
+			res = n.Token.Literal + " " + res
+			if n.Type != nil {
+				res += ": " + n.Type.String()
+			} else if fn, ok := n.Value.(*ast.FunctionLiteral); ok {
+				res += " = fn" + fn.Signature()
+			}
+		case *ast.FunctionStatement:
+			if n.Name == decl {
+				res = "fn " + res + n.Function.Signature()
+			}
+		case *ast.StructStatement:
+			if n.Name == decl {
+				res = strings.TrimSuffix(n.String(), "\n")
+			}
+		case *ast.FunctionLiteral:
+			for i, param := range n.Parameters {
+				if param == decl {
+					res = "parameter " + res
+					if i < len(n.ParamTypes) && n.ParamTypes[i] != nil {
+						res += ": " + n.ParamTypes[i].String()
+					}
+				}
+			}
+			if n.Rest == decl {
+				res = "parameter ..." + res
+			}
+		case *ast.ImportStatement:
+			if n.Alias == decl || n.Path.Token.Line == decl.Token.Line && n.Path.Token.Column == decl.Token.Column {
+				res = "import " + res
+			}
+		}
+		return true
+	})
+	return res
+}
+
+// 关键字,内置函数和光标处可见的名字中以光标前的单词开始的
+func (d *document) completion(pos Position) []CompletionItem {
+	line, column := d.offset(pos)
+	prefix := d.wordBefore(line, column)
+	items := []CompletionItem{}
+	seen := map[string]bool{}
+	add := func(item CompletionItem) {
+		if strings.HasPrefix(item.Label, prefix) && !seen[item.Label] {
+			seen[item.Label] = true
+			items = append(items, item)
+		}
+	}
+	if d.lastGood != nil {
+		for _, sym := range namesAt(d.lastGood, line, column) {
+			add(sym)
+		}
+	}
+	for _, name := range builtins.BuiltinNames() {
+		sig, _ := builtins.BuiltinSignature(name)
+		add(CompletionItem{Label: name, Kind: CompletionFunction, Detail: name + sig.String(), Documentation: sig.Doc})
+	}
+	for _, word := range token.Keywords() {
+		add(CompletionItem{Label: word, Kind: CompletionKeyword})
+	}
+	return items
+}
+
+// 在line行column列可见的名字:顶层的声明,以及包含该位置的函数,catch和match分支中的声明
+// 语句块不产生作用域,所以函数中任何位置的let都算
+func namesAt(program *ast.Program, line, column int) []CompletionItem {
+	var items []CompletionItem
+	items = append(items, declarations(program.Statements)...)
+	ast.Inspect(program, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FunctionLiteral:
+			if n.Body == nil || !within(line, column, n.Token, n.Body.Rbrace) {
+				return false
+			}
+			params := append([]*ast.Identifier{}, n.Parameters...)
+			if n.Rest != nil {
+				params = append(params, n.Rest)
+			}
+			for _, param := range params {
+				items = append(items, CompletionItem{Label: param.Token.Literal, Kind: CompletionVariable, Detail: "parameter"})
+			}
+			items = append(items, declarations(n.Body.Statements)...)
+		case *ast.TryExpression:
+			if n.Param != nil && n.Catch != nil && within(line, column, n.Catch.Token, n.Catch.Rbrace) {
+				items = append(items, CompletionItem{Label: n.Param.Token.Literal, Kind: CompletionVariable})
+			}
+		case *ast.MatchExpression:
+			for i, arm := range n.Arms {
+				end := n.Rbrace
+				if i+1 < len(n.Arms) {
+					end = n.Arms[i+1].Token
+				}
+				if !within(line, column, arm.Token, end) {
+					continue
+				}
+				ast.Inspect(arm.Pattern, func(p ast.Node) bool {
+					if ident, ok := p.(*ast.Identifier); ok {
+						items = append(items, CompletionItem{Label: ident.Token.Literal, Kind: CompletionVariable})
+					}
+					return true
+				})
+			}
+		}
+		return true
+	})
+	return items
+}
+
+// 语句中声明的名字,不包括嵌套的函数中的
+func declarations(statements []ast.Statement) []CompletionItem {
+	var items []CompletionItem
+	for _, stmt := range statements {
+		ast.Inspect(stmt, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.LetStatement:
+				kind := CompletionVariable
+				if _, ok := n.Value.(*ast.FunctionLiteral); ok {
+					kind = CompletionFunction
+				}
+				items = append(items, CompletionItem{Label: n.Name.Token.Literal, Kind: kind})
+			case *ast.FunctionStatement:
+				items = append(items, CompletionItem{Label: n.Name.Token.Literal, Kind: CompletionFunction, Detail: "fn " + n.Name.Token.Literal + n.Function.Signature()})
+				return false
+			case *ast.StructStatement:
+				items = append(items, CompletionItem{Label: n.Name.Token.Literal, Kind: CompletionStruct})
+			case *ast.ImportStatement:
+				name := importName(n)
+				items = append(items, CompletionItem{Label: name, Kind: CompletionModule})
+			case *ast.FunctionLiteral:
+				return false
+			}
+			return true
+		})
+	}
+	return items
+}
+
+// import声明的名字,没有别名时是路径的最后一段去掉扩展名
+func importName(stmt *ast.ImportStatement) string {
+	if stmt.Alias != nil {
+		return stmt.Alias.Token.Literal
+	}
+	path := stmt.Path.Token.Literal
+	return strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+}
+
+// 顶层的声明,函数中的声明作为其子符号
+func (d *document) symbols() []DocumentSymbol {
+	if d.program == nil {
+		return []DocumentSymbol{}
+	}
+	return d.symbolsIn(d.program.Statements)
+}
+
+func (d *document) symbolsIn(statements []ast.Statement) []DocumentSymbol {
+	symbols := []DocumentSymbol{}
+	for _, stmt := range statements {
+		var sym DocumentSymbol
+		var name *ast.Identifier
+		var fn *ast.FunctionLiteral
+		switch stmt := stmt.(type) {
+		case *ast.LetStatement:
+			name, sym.Kind = stmt.Name, SymbolVariable
+			if stmt.Const {
+				sym.Kind = SymbolConstant
+			}
+			if lit, ok := stmt.Value.(*ast.FunctionLiteral); ok {
+				fn, sym.Kind, sym.Detail = lit, SymbolFunction, "fn"+lit.Signature()
+			} else if stmt.Type != nil {
+				sym.Detail = stmt.Type.String()
+			}
+		case *ast.FunctionStatement:
+			name, fn, sym.Kind, sym.Detail = stmt.Name, stmt.Function, SymbolFunction, "fn"+stmt.Function.Signature()
+		case *ast.StructStatement:
+			name, sym.Kind = stmt.Name, SymbolStruct
+		case *ast.ImportStatement:
+			sym = DocumentSymbol{Name: importName(stmt), Kind: SymbolModule, Detail: stmt.Path.Token.Literal}
+			sym.SelectionRange = d.tokenRange(stmt.Path.Token)
+		default:
+			continue
+		}
+		if name != nil {
+			sym.Name, sym.SelectionRange = name.Token.Literal, d.tokenRange(name.Token)
+		}
+		sym.Range = Range{Start: d.position(ast.Pos(stmt).Line, ast.Pos(stmt).Column), End: d.tokenRange(ast.End(stmt)).End}
+		if fn != nil {
+			sym.Children = d.symbolsIn(fn.Body.Statements)
+		}
+		symbols = append(symbols, sym)
+	}
+	return symbols
+}
+
+// 用格式化后的源码替换整个文档,已经格式化时没有修改
+func (d *document) formatting() ([]TextEdit, error) {
+	formatted, err := format.Source(d.text)
+	if err != nil {
+		return nil, err
+	}
+	if formatted == d.text {
+		return []TextEdit{}, nil
+	}
+	return []TextEdit{{Range: d.fullRange(), NewText: formatted}}, nil
+}
@@ -0,0 +1,277 @@
+// lsp包实现语言服务器协议,在标准输入输出上为编辑器提供诊断,跳转到定义,查找引用,悬停提示,补全,文档符号和格式化
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+type server struct {
+	in          *bufio.Reader
+	out         io.Writer
+	docs        map[string]*document
+	initialized bool
+	shutdown    bool
+}
+
+// 收到exit通知之前没有收到shutdown请求
+var ErrExitWithoutShutdown = errors.New("exit without shutdown")
+
+// 在in和out上运行语言服务器,按顺序处理消息,直到收到exit通知或in结束
+// 正常退出时返回nil
+func Serve(in io.Reader, out io.Writer) error {
+	s := &server{in: bufio.NewReader(in), out: out, docs: map[string]*document{}}
+	for {
+		msg, err := readMessage(s.in)
+		if err != nil {
+			var respErr *ResponseError
+			if errors.As(err, &respErr) {
+				//无法解析的消息没有ID,回复ID为null的错误
+				if err := s.reply(json.RawMessage("null"), nil, respErr); err != nil {
+					return err
+				}
+				continue
+			}
+			if errors.Is(err, io.EOF) && s.shutdown {
+				return nil
+			}
+			return err
+		}
+		if msg.Method == "exit" {
+			if !s.shutdown {
+				return ErrExitWithoutShutdown
+			}
+			return nil
+		}
+		if err := s.handle(msg); err != nil {
+			return err
+		}
+	}
+}
+
+type handler func(s *server, params json.RawMessage) (any, error)
+
+var requests = map[string]handler{
+	"initialize":                  (*server).initialize,
+	"shutdown":                    (*server).shutdownRequest,
+	"textDocument/definition":     (*server).definition,
+	"textDocument/references":     (*server).references,
+	"textDocument/hover":          (*server).hover,
+	"textDocument/completion":     (*server).completion,
+	"textDocument/documentSymbol": (*server).documentSymbol,
+	"textDocument/formatting":     (*server).formatting,
+}
+
+var notifications = map[string]func(s *server, params json.RawMessage) error{
+	"textDocument/didOpen":   (*server).didOpen,
+	"textDocument/didChange": (*server).didChange,
+	"textDocument/didClose":  (*server).didClose,
+}
+
+// 处理一条消息,只有写回复失败时返回错误
+func (s *server) handle(msg *message) error {
+	if msg.ID == nil {
+		//未知的通知和初始化之前的通知都忽略
+		if fn, ok := notifications[msg.Method]; ok && s.initialized {
+			return fn(s, msg.Params)
+		}
+		return nil
+	}
+	fn, ok := requests[msg.Method]
+	switch {
+	case !ok:
+		return s.reply(msg.ID, nil, &ResponseError{Code: codeMethodNotFound, Message: "method not found: " + msg.Method})
+	case !s.initialized && msg.Method != "initialize":
+		return s.reply(msg.ID, nil, &ResponseError{Code: codeServerNotInitialized, Message: "server not initialized"})
+	case s.shutdown:
+		return s.reply(msg.ID, nil, &ResponseError{Code: codeInvalidRequest, Message: "server is shutting down"})
+	}
+	result, err := fn(s, msg.Params)
+	if err != nil {
+		var respErr *ResponseError
+		if !errors.As(err, &respErr) {
+			respErr = &ResponseError{Code: codeRequestFailed, Message: err.Error()}
+		}
+		return s.reply(msg.ID, nil, respErr)
+	}
+	return s.reply(msg.ID, result, nil)
+}
+
+func (s *server) reply(id json.RawMessage, result any, respErr *ResponseError) error {
+	msg := &message{ID: id, Error: respErr}
+	if respErr == nil {
+		data, err := json.Marshal(result)
+		if err != nil {
+			return err
+		}
+		msg.Result = data
+	}
+	return writeMessage(s.out, msg)
+}
+
+func (s *server) notify(method string, params any) error {
+	data, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	return writeMessage(s.out, &message{Method: method, Params: data})
+}
+
+func decode(params json.RawMessage, v any) error {
+	if err := json.Unmarshal(params, v); err != nil {
+		return &ResponseError{Code: codeInvalidParams, Message: err.Error()}
+	}
+	return nil
+}
+
+func (s *server) initialize(params json.RawMessage) (any, error) {
+	if s.initialized {
+		return nil, &ResponseError{Code: codeInvalidRequest, Message: "server already initialized"}
+	}
+	s.initialized = true
+	return InitializeResult{
+		Capabilities: ServerCapabilities{
+			TextDocumentSync:           syncFull,
+			DefinitionProvider:         true,
+			ReferencesProvider:         true,
+			HoverProvider:              true,
+			CompletionProvider:         map[string]any{},
+			DocumentSymbolProvider:     true,
+			DocumentFormattingProvider: true,
+		},
+		ServerInfo: ServerInfo{Name: "my-interpreter"},
+	}, nil
+}
+
+func (s *server) shutdownRequest(params json.RawMessage) (any, error) {
+	s.shutdown = true
+	return nil, nil
+}
+
+// 分析文档并发布其诊断
+func (s *server) update(uri, text string, version int) error {
+	d := newDocument(uri, text, version, s.docs[uri])
+	s.docs[uri] = d
+	return s.notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{
+		URI: uri, Version: version, Diagnostics: d.diagnostics,
+	})
+}
+
+func (s *server) didOpen(params json.RawMessage) error {
+	var p DidOpenTextDocumentParams
+	if decode(params, &p) != nil {
+		return nil
+	}
+	return s.update(p.TextDocument.URI, p.TextDocument.Text, p.TextDocument.Version)
+}
+
+func (s *server) didChange(params json.RawMessage) error {
+	var p DidChangeTextDocumentParams
+	if decode(params, &p) != nil || len(p.ContentChanges) == 0 {
+		return nil
+	}
+	//全量同步时最后一次修改就是整个文档
+	text := p.ContentChanges[len(p.ContentChanges)-1].Text
+	return s.update(p.TextDocument.URI, text, p.TextDocument.Version)
+}
+
+func (s *server) didClose(params json.RawMessage) error {
+	var p DidCloseTextDocumentParams
+	if decode(params, &p) != nil {
+		return nil
+	}
+	delete(s.docs, p.TextDocument.URI)
+	return s.notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{
+		URI: p.TextDocument.URI, Diagnostics: []Diagnostic{},
+	})
+}
+
+func (s *server) document(uri string) (*document, error) {
+	d, ok := s.docs[uri]
+	if !ok {
+		return nil, &ResponseError{Code: codeInvalidParams, Message: fmt.Sprintf("document not open: %s", uri)}
+	}
+	return d, nil
+}
+
+// 解析位置参数并找到对应的文档
+func (s *server) positionParams(params json.RawMessage) (*document, Position, error) {
+	var p TextDocumentPositionParams
+	if err := decode(params, &p); err != nil {
+		return nil, Position{}, err
+	}
+	d, err := s.document(p.TextDocument.URI)
+	return d, p.Position, err
+}
+
+func (s *server) definition(params json.RawMessage) (any, error) {
+	d, pos, err := s.positionParams(params)
+	if err != nil {
+		return nil, err
+	}
+	if loc := d.definition(pos); loc != nil {
+		return loc, nil
+	}
+	return nil, nil
+}
+
+func (s *server) references(params json.RawMessage) (any, error) {
+	var p ReferenceParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+	d, err := s.document(p.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	if locs := d.references(p.Position, p.Context.IncludeDeclaration); locs != nil {
+		return locs, nil
+	}
+	return nil, nil
+}
+
+func (s *server) hover(params json.RawMessage) (any, error) {
+	d, pos, err := s.positionParams(params)
+	if err != nil {
+		return nil, err
+	}
+	if h := d.hover(pos); h != nil {
+		return h, nil
+	}
+	return nil, nil
+}
+
+func (s *server) completion(params json.RawMessage) (any, error) {
+	d, pos, err := s.positionParams(params)
+	if err != nil {
+		return nil, err
+	}
+	return d.completion(pos), nil
+}
+
+func (s *server) documentSymbol(params json.RawMessage) (any, error) {
+	var p DocumentParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+	d, err := s.document(p.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	return d.symbols(), nil
+}
+
+func (s *server) formatting(params json.RawMessage) (any, error) {
+	var p DocumentParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+	d, err := s.document(p.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	return d.formatting()
+}
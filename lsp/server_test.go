@@ -0,0 +1,349 @@
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 通过管道与Serve通信的JSON-RPC客户端
+type client struct {
+	t             *testing.T
+	w             *io.PipeWriter
+	msgs          chan *message
+	notifications []*message
+	nextID        int
+	done          chan error
+}
+
+func newClient(t *testing.T) *client {
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	c := &client{t: t, w: inW, msgs: make(chan *message, 100), done: make(chan error, 1)}
+	go func() {
+		c.done <- Serve(inR, outW)
+		outW.Close()
+	}()
+	//服务器的输出在另一个goroutine中读取,以免双方都阻塞在写上
+	go func() {
+		r := bufio.NewReader(outR)
+		for {
+			msg, err := readMessage(r)
+			if err != nil {
+				close(c.msgs)
+				return
+			}
+			c.msgs <- msg
+		}
+	}()
+	return c
+}
+
+func (c *client) send(msg *message) {
+	c.t.Helper()
+	if err := writeMessage(c.w, msg); err != nil {
+		c.t.Fatalf("write failed: %v", err)
+	}
+}
+
+func (c *client) notify(method string, params any) {
+	c.t.Helper()
+	data, _ := json.Marshal(params)
+	c.send(&message{Method: method, Params: data})
+}
+
+// 发送请求并等待回复,回复的result解码到result中
+func (c *client) call(method string, params any, result any) *ResponseError {
+	c.t.Helper()
+	c.nextID++
+	id, _ := json.Marshal(c.nextID)
+	data, _ := json.Marshal(params)
+	c.send(&message{ID: id, Method: method, Params: data})
+	for {
+		msg := c.receive()
+		if msg.ID == nil {
+			c.notifications = append(c.notifications, msg)
+			continue
+		}
+		if string(msg.ID) != string(id) {
+			c.t.Fatalf("unexpected response id %s, expected %s", msg.ID, id)
+		}
+		if msg.Error != nil {
+			return msg.Error
+		}
+		if result != nil {
+			if err := json.Unmarshal(msg.Result, result); err != nil {
+				c.t.Fatalf("%s: cannot decode result %s: %v", method, msg.Result, err)
+			}
+		}
+		return nil
+	}
+}
+
+func (c *client) receive() *message {
+	c.t.Helper()
+	select {
+	case msg, ok := <-c.msgs:
+		if !ok {
+			c.t.Fatalf("server closed the connection")
+		}
+		return msg
+	case <-time.After(5 * time.Second):
+		c.t.Fatalf("timed out waiting for the server")
+	}
+	return nil
+}
+
+// 下一次发布的诊断
+func (c *client) diagnostics() PublishDiagnosticsParams {
+	c.t.Helper()
+	var msg *message
+	if len(c.notifications) > 0 {
+		msg, c.notifications = c.notifications[0], c.notifications[1:]
+	} else {
+		msg = c.receive()
+	}
+	if msg.Method != "textDocument/publishDiagnostics" {
+		c.t.Fatalf("expected diagnostics, got %s", msg.Method)
+	}
+	var params PublishDiagnosticsParams
+	json.Unmarshal(msg.Params, &params)
+	return params
+}
+
+func (c *client) open(uri, text string) PublishDiagnosticsParams {
+	c.t.Helper()
+	c.notify("textDocument/didOpen", DidOpenTextDocumentParams{
+		TextDocument: TextDocumentItem{URI: uri, LanguageID: "my-interpreter", Version: 1, Text: text},
+	})
+	return c.diagnostics()
+}
+
+func (c *client) exit() error {
+	c.t.Helper()
+	if err := c.call("shutdown", nil, nil); err != nil {
+		c.t.Fatalf("shutdown failed: %v", err)
+	}
+	c.notify("exit", nil)
+	return <-c.done
+}
+
+func initialized(t *testing.T) *client {
+	c := newClient(t)
+	var res InitializeResult
+	if err := c.call("initialize", map[string]any{"capabilities": map[string]any{}}, &res); err != nil {
+		t.Fatalf("initialize failed: %v", err)
+	}
+	if !res.Capabilities.DefinitionProvider || res.Capabilities.TextDocumentSync != syncFull {
+		t.Fatalf("wrong capabilities: %+v", res.Capabilities)
+	}
+	c.notify("initialized", map[string]any{})
+	return c
+}
+
+func at(uri string, line, character int) TextDocumentPositionParams {
+	return TextDocumentPositionParams{TextDocument: TextDocumentIdentifier{URI: uri}, Position: Position{Line: line, Character: character}}
+}
+
+func TestLifecycle(t *testing.T) {
+	c := newClient(t)
+	if err := c.call("textDocument/hover", at("file:///a.mi", 0, 0), nil); err == nil || err.Code != codeServerNotInitialized {
+		t.Errorf("expected server not initialized error. got=%v", err)
+	}
+	c.call("initialize", map[string]any{}, nil)
+	if err := c.call("unknown/method", nil, nil); err == nil || err.Code != codeMethodNotFound {
+		t.Errorf("expected method not found error. got=%v", err)
+	}
+	if err := c.exit(); err != nil {
+		t.Errorf("expected clean exit. got=%v", err)
+	}
+
+	c = newClient(t)
+	c.notify("exit", nil)
+	if err := <-c.done; err != ErrExitWithoutShutdown {
+		t.Errorf("expected %v. got=%v", ErrExitWithoutShutdown, err)
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	c := initialized(t)
+	uri := "file:///a.mi"
+	diags := c.open(uri, "let a = 1;\nlet b = ;")
+	if len(diags.Diagnostics) == 0 || diags.URI != uri {
+		t.Fatalf("expected a syntax error. got=%+v", diags)
+	}
+	if d := diags.Diagnostics[0]; d.Severity != SeverityError || d.Range.Start != (Position{Line: 1, Character: 8}) {
+		t.Errorf("wrong syntax error: %+v", d)
+	}
+
+	c.notify("textDocument/didChange", DidChangeTextDocumentParams{
+		TextDocument:   VersionedTextDocumentIdentifier{URI: uri, Version: 2},
+		ContentChanges: []TextDocumentContentChangeEvent{{Text: "let s = \"中文\"; prints(s, nope);\nlet x: int = \"a\";"}},
+	})
+	type diagnostic struct {
+		start    Position
+		end      Position
+		severity int
+		message  string
+	}
+	check := func(version int, expected []diagnostic) {
+		t.Helper()
+		diags := c.diagnostics()
+		if diags.Version != version || len(diags.Diagnostics) != len(expected) {
+			t.Fatalf("wrong diagnostics: %+v", diags)
+		}
+		for i, e := range expected {
+			d := diags.Diagnostics[i]
+			if d.Range.Start != e.start || d.Range.End != e.end || d.Severity != e.severity || d.Message != e.message {
+				t.Errorf("diagnostic %d wrong. expected=%+v, got=%+v", i, e, d)
+			}
+		}
+	}
+	check(2, []diagnostic{
+		//列按UTF-16计,中文字符各占一个单元
+		{Position{0, 24}, Position{0, 28}, SeverityError, "undefined: nope"},
+		{Position{1, 13}, Position{1, 14}, SeverityError, "cannot use string as int in let x"},
+	})
+
+	//位置取自语法分析器记下的token,消息中不带位置
+	c.notify("textDocument/didChange", DidChangeTextDocumentParams{
+		TextDocument:   VersionedTextDocumentIdentifier{URI: uri, Version: 3},
+		ContentChanges: []TextDocumentContentChangeEvent{{Text: "const a = 1;\nlet a = match (a) { _ => 1, 2 => 3 };"}},
+	})
+	check(3, []diagnostic{
+		{Position{1, 4}, Position{1, 5}, SeverityError, "cannot reassign constant a"},
+		{Position{1, 28}, Position{1, 29}, SeverityWarning, "unreachable match arm 2"},
+	})
+
+	c.notify("textDocument/didClose", DidCloseTextDocumentParams{TextDocument: TextDocumentIdentifier{URI: uri}})
+	if diags := c.diagnostics(); len(diags.Diagnostics) != 0 {
+		t.Errorf("diagnostics should be cleared on close. got=%+v", diags)
+	}
+	c.exit()
+}
+
+const source1 = `let total = 0;
+fn add(a: int, b: int) -> int { a + b }
+let sum = fn(xs) {
+	let first = xs[0];
+	add(first, total)
+};
+sum([1]) + len("x")`
+
+func TestNavigation(t *testing.T) {
+	c := initialized(t)
+	uri := "file:///nav.mi"
+	c.open(uri, source1)
+
+	var loc *Location
+	c.call("textDocument/definition", at(uri, 4, 13), &loc)
+	if loc == nil || loc.Range != (Range{Position{0, 4}, Position{0, 9}}) {
+		t.Errorf("definition of total wrong. got=%+v", loc)
+	}
+	c.call("textDocument/definition", at(uri, 4, 7), &loc)
+	if loc == nil || loc.Range != (Range{Position{3, 5}, Position{3, 10}}) {
+		t.Errorf("definition of first wrong. got=%+v", loc)
+	}
+	loc = nil
+	c.call("textDocument/definition", at(uri, 6, 12), &loc)
+	if loc != nil {
+		t.Errorf("builtins have no definition. got=%+v", loc)
+	}
+
+	var refs []Location
+	params := ReferenceParams{TextDocumentPositionParams: at(uri, 1, 3)}
+	params.Context.IncludeDeclaration = true
+	c.call("textDocument/references", params, &refs)
+	if len(refs) != 2 || refs[0].Range.Start != (Position{1, 3}) || refs[1].Range.Start != (Position{4, 1}) {
+		t.Errorf("references of add wrong. got=%+v", refs)
+	}
+	params = ReferenceParams{TextDocumentPositionParams: at(uri, 3, 13)}
+	c.call("textDocument/references", params, &refs)
+	if len(refs) != 1 || refs[0].Range.Start != (Position{3, 13}) {
+		t.Errorf("references of xs without declaration wrong. got=%+v", refs)
+	}
+
+	var hover *Hover
+	c.call("textDocument/hover", at(uri, 6, 13), &hover)
+	if hover == nil || !strings.Contains(hover.Contents.Value, "len(value)") {
+		t.Errorf("hover of len wrong. got=%+v", hover)
+	}
+	c.call("textDocument/hover", at(uri, 4, 2), &hover)
+	if hover == nil || !strings.Contains(hover.Contents.Value, "fn add(a: int, b: int) -> int") {
+		t.Errorf("hover of add wrong. got=%+v", hover)
+	}
+	c.exit()
+}
+
+func TestCompletion(t *testing.T) {
+	c := initialized(t)
+	uri := "file:///c.mi"
+	c.open(uri, "let limit = 1;\nlet f = fn(left, right) {\n\tl\n};\nfn g(lo) { lo }\nl")
+	labels := func(line, character int) []string {
+		var items []CompletionItem
+		c.call("textDocument/completion", at(uri, line, character), &items)
+		var res []string
+		for _, item := range items {
+			res = append(res, item.Label)
+		}
+		return res
+	}
+	//函数中可以看到参数,外面看不到
+	if got := strings.Join(labels(2, 2), " "); got != "limit left last len let" {
+		t.Errorf("completion in function wrong. got=%s", got)
+	}
+	if got := strings.Join(labels(5, 1), " "); got != "limit last len let" {
+		t.Errorf("completion at top level wrong. got=%s", got)
+	}
+	//编辑中有语法错误时使用上一次的语法树
+	c.notify("textDocument/didChange", DidChangeTextDocumentParams{
+		TextDocument:   VersionedTextDocumentIdentifier{URI: uri, Version: 2},
+		ContentChanges: []TextDocumentContentChangeEvent{{Text: "let limit = 1;\nlet f = fn(left, right) {\n\tri\n};\nfn g(lo) { lo }\nl("}},
+	})
+	c.diagnostics()
+	if got := strings.Join(labels(2, 3), " "); got != "right" {
+		t.Errorf("completion in broken document wrong. got=%s", got)
+	}
+	c.exit()
+}
+
+func TestSymbolsAndFormatting(t *testing.T) {
+	c := initialized(t)
+	uri := "file:///s.mi"
+	c.open(uri, "import \"lib/util.mi\";\nconst max=10\nfn outer(x) {\nlet inner = fn() { x };\ninner()\n}\nstruct P { x, y }")
+	var symbols []DocumentSymbol
+	c.call("textDocument/documentSymbol", DocumentParams{TextDocument: TextDocumentIdentifier{URI: uri}}, &symbols)
+	var got []string
+	for _, sym := range symbols {
+		got = append(got, sym.Name)
+		for _, child := range sym.Children {
+			got = append(got, sym.Name+"."+child.Name)
+		}
+	}
+	if strings.Join(got, " ") != "util max outer outer.inner P" {
+		t.Errorf("wrong symbols: %v", got)
+	}
+	if outer := symbols[2]; outer.Kind != SymbolFunction || outer.Range != (Range{Position{2, 0}, Position{5, 1}}) || outer.SelectionRange.Start != (Position{2, 3}) {
+		t.Errorf("wrong symbol for outer: %+v", outer)
+	}
+
+	var edits []TextEdit
+	c.call("textDocument/formatting", DocumentParams{TextDocument: TextDocumentIdentifier{URI: uri}}, &edits)
+	expected := "import \"lib/util.mi\";\nconst max = 10;\nfn outer(x) {\n\tlet inner = fn() { x };\n\tinner();\n}\nstruct P { x, y }\n"
+	if len(edits) != 1 || edits[0].NewText != expected || edits[0].Range.End != (Position{6, 17}) {
+		t.Errorf("wrong formatting edits: %+v", edits)
+	}
+
+	c.notify("textDocument/didChange", DidChangeTextDocumentParams{
+		TextDocument:   VersionedTextDocumentIdentifier{URI: uri, Version: 2},
+		ContentChanges: []TextDocumentContentChangeEvent{{Text: "let = 1"}},
+	})
+	c.diagnostics()
+	if err := c.call("textDocument/formatting", DocumentParams{TextDocument: TextDocumentIdentifier{URI: uri}}, &edits); err == nil {
+		t.Errorf("formatting a document with syntax errors should fail")
+	}
+	c.exit()
+}
@@ -1,14 +1,75 @@
 package main
 
 import (
+	"bufio"
+	"context"
+	"flag"
 	"fmt"
+	"io"
 	"log"
+	"my-interpreter/ast"
+	"my-interpreter/coverage"
+	evaluator "my-interpreter/evaluator"
+	"my-interpreter/lexer"
+	"my-interpreter/lsp"
+	"my-interpreter/object"
+	"my-interpreter/optimizer"
+	"my-interpreter/parser"
+	"my-interpreter/profiler"
 	"my-interpreter/repl"
+	"my-interpreter/resolver"
+	"my-interpreter/tracer"
+	"my-interpreter/typecheck"
 	"os"
 	"os/user"
 )
 
+const usage = `usage:
+  my-interpreter            start the REPL
+  my-interpreter run [-O] [-dump-ast] [-cpuprofile OUT] [-memprofile OUT]
+                    [-cover] [-coverprofile OUT] [-coverhtml OUT]
+                    [-trace OUT [-trace-format text|json]] FILE
+                            run a script, -O optimizes it first,
+                            -dump-ast prints the syntax tree instead,
+                            -cpuprofile and -memprofile write pprof profiles,
+                            -cover prints statement and branch coverage,
+                            -coverprofile writes it as LCOV, -coverhtml as HTML,
+                            -trace writes calls, builtin calls and errors
+  my-interpreter test [-run REGEXP] [-v] [-junit OUT]
+                    [-cover] [-coverprofile OUT] [-coverhtml OUT] [PATH...]
+                            run the test* functions in *_test.mi files,
+                            directories are searched recursively,
+                            each test runs its file again, so coverage
+                            counts are summed over the tests
+  my-interpreter lint [-config FILE] [-format text|json] FILE...
+                            check scripts for likely mistakes
+  my-interpreter lsp        start a language server on stdin and stdout
+  my-interpreter debug FILE debug a script interactively
+  my-interpreter debug -dap start a debug adapter on stdin and stdout
+`
+
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "run":
+			os.Exit(runCommand(os.Args[2:]))
+		case "test":
+			os.Exit(testCommand(os.Args[2:]))
+		case "debug":
+			os.Exit(debugCommand(os.Args[2:]))
+		case "lint":
+			os.Exit(lintFiles(os.Args[2:]))
+		case "lsp":
+			if err := lsp.Serve(os.Stdin, os.Stdout); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			os.Exit(0)
+		default:
+			fmt.Fprint(os.Stderr, usage)
+			os.Exit(2)
+		}
+	}
 	usr, err := user.Current()
 	//MATEBOOK14S\35895,pansu
 	if err != nil {
@@ -18,3 +79,231 @@ func main() {
 	fmt.Println("I'm in Juejin")
 	repl.Start(os.Stdin, os.Stdout)
 }
+
+type runOptions struct {
+	optimize   bool   //求值前用optimizer改写语法树
+	dumpAST    bool   //只打印(优化后的)语法树,不求值
+	cpuProfile string //写出CPU采样的文件
+	memProfile string //写出对象分配统计的文件
+	cover      coverOptions
+	trace      string //写出执行跟踪的文件
+	traceJSON  bool   //跟踪写成JSON Lines
+}
+
+type coverOptions struct {
+	summary bool   //输出覆盖率的文本摘要
+	lcov    string //写出LCOV的文件
+	html    string //写出带注释源码的HTML文件
+}
+
+func (opts *coverOptions) enabled() bool {
+	return opts.summary || opts.lcov != "" || opts.html != ""
+}
+
+func (opts *coverOptions) register(flags *flag.FlagSet) {
+	flags.BoolVar(&opts.summary, "cover", false, "print statement and branch coverage")
+	flags.StringVar(&opts.lcov, "coverprofile", "", "write coverage to `file` in LCOV format")
+	flags.StringVar(&opts.html, "coverhtml", "", "write annotated source with coverage to `file`")
+}
+
+// 按选项输出覆盖率,失败时返回false
+func (opts *coverOptions) write(cov *coverage.Coverage) bool {
+	if opts.summary {
+		cov.WriteSummary(os.Stdout)
+	}
+	return writeOutput(opts.lcov, cov.WriteLCOV) && writeOutput(opts.html, cov.WriteHTML)
+}
+
+func runCommand(args []string) int {
+	flags := flag.NewFlagSet("run", flag.ContinueOnError)
+	var opts runOptions
+	flags.BoolVar(&opts.optimize, "O", false, "optimize the program before running it")
+	flags.BoolVar(&opts.dumpAST, "dump-ast", false, "print the syntax tree and exit")
+	flags.StringVar(&opts.cpuProfile, "cpuprofile", "", "write a CPU profile of the script to `file`")
+	flags.StringVar(&opts.memProfile, "memprofile", "", "write an allocation profile of the script to `file`")
+	opts.cover.register(flags)
+	flags.StringVar(&opts.trace, "trace", "", "write an execution trace to `file`")
+	traceFormat := flags.String("trace-format", "text", "trace format: text or json")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *traceFormat != "text" && *traceFormat != "json" {
+		fmt.Fprint(os.Stderr, usage)
+		return 2
+	}
+	opts.traceJSON = *traceFormat == "json"
+	//它们都使用求值的钩子,一次只能有一个
+	hooks := 0
+	for _, on := range []bool{opts.cpuProfile != "" || opts.memProfile != "", opts.cover.enabled(), opts.trace != ""} {
+		if on {
+			hooks++
+		}
+	}
+	if hooks > 1 {
+		fmt.Fprintln(os.Stderr, "profiling, coverage and tracing cannot be combined")
+		return 2
+	}
+	if flags.NArg() != 1 {
+		fmt.Fprint(os.Stderr, usage)
+		return 2
+	}
+	return runFile(flags.Arg(0), opts)
+}
+
+// 运行脚本文件,返回进程的退出码
+func runFile(path string, opts runOptions) int {
+	program, ok := loadFile(path)
+	if !ok {
+		return 1
+	}
+	if opts.optimize {
+		program = optimizer.Optimize(program)
+	}
+	if opts.dumpAST {
+		fmt.Print(ast.Dump(program))
+		return 0
+	}
+	cfg := evaluator.Config{Importer: evaluator.NewImporterFromEnv(), File: path}
+	var prof *profiler.Profiler
+	if opts.cpuProfile != "" || opts.memProfile != "" {
+		prof = profiler.New(path, profiler.DefaultInterval)
+		cfg.Hook = prof.Hook(opts.memProfile != "")
+		prof.Start()
+	}
+	var cov *coverage.Coverage
+	if opts.cover.enabled() {
+		cov = coverage.New()
+		cov.Add(path, program)
+		cfg.Hook = cov
+	}
+	var finishTrace func() error
+	if opts.trace != "" {
+		var err error
+		if cfg.Hook, finishTrace, err = startTrace(opts.trace, opts.traceJSON); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+	res := evaluator.EvalContext(context.Background(), program, object.NewEnvironment(), cfg)
+	code := report(path, res)
+	if finishTrace != nil {
+		if err := finishTrace(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+	if prof != nil {
+		prof.Stop()
+		if !writeOutput(opts.cpuProfile, prof.WriteCPU) || !writeOutput(opts.memProfile, prof.WriteAllocs) {
+			return 1
+		}
+	}
+	if cov != nil && !opts.cover.write(cov) {
+		return 1
+	}
+	return code
+}
+
+// 创建跟踪文件和写入它的跟踪器,求值结束后调用finish写出缓冲的内容并关闭文件
+func startTrace(path string, asJSON bool) (hook evaluator.Hook, finish func() error, err error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	w := bufio.NewWriter(f)
+	var t interface {
+		evaluator.TraceHook
+		Err() error
+	}
+	if asJSON {
+		t = tracer.NewJSON(w)
+	} else {
+		t = tracer.NewText(w)
+	}
+	finish = func() error {
+		err := t.Err()
+		if err == nil {
+			err = w.Flush()
+		}
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		return err
+	}
+	return t, finish, nil
+}
+
+// path不为空时用write写出性能分析或覆盖率文件,失败时返回false
+func writeOutput(path string, write func(w io.Writer) error) bool {
+	if path == "" {
+		return true
+	}
+	f, err := os.Create(path)
+	if err == nil {
+		err = write(f)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return false
+	}
+	return true
+}
+
+// 解析并检查脚本文件,有错误时输出到标准错误并返回false
+func loadFile(path string) (*ast.Program, bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return nil, false
+	}
+	p := parser.NewParser(lexer.NewLexer(string(data)))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		for i, msg := range p.Errors() {
+			if tok := p.ErrorPositions()[i]; tok.Line > 0 {
+				fmt.Fprintf(os.Stderr, "%s:%d:%d: %s\n", path, tok.Line, tok.Column, msg)
+			} else {
+				fmt.Fprintf(os.Stderr, "%s: %s\n", path, msg)
+			}
+		}
+		return nil, false
+	}
+	for i, msg := range p.Warnings() {
+		tok := p.WarningPositions()[i]
+		fmt.Fprintf(os.Stderr, "warning: %s:%d:%d: %s\n", path, tok.Line, tok.Column, msg)
+	}
+	failed := false
+	for _, d := range resolver.Resolve(program, evaluator.BuiltinNames()) {
+		fmt.Fprintf(os.Stderr, "%s:%s\n", path, d)
+		failed = failed || d.Severity == resolver.ERROR
+	}
+	for _, d := range typecheck.Check(program) {
+		fmt.Fprintf(os.Stderr, "%s:%s\n", path, d)
+		failed = true
+	}
+	return program, !failed
+}
+
+// 输出求值的错误,返回进程的退出码
+func report(path string, res object.Object) int {
+	if errObj, ok := res.(*object.Error); ok {
+		if errObj.Line > 0 {
+			//错误可能发生在导入的模块中
+			file := path
+			if errObj.File != "" {
+				file = errObj.File
+			}
+			fmt.Fprintf(os.Stderr, "%s:%d:%d: %s\n", file, errObj.Line, errObj.Column, errObj.Msg)
+		} else {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", path, errObj.Msg)
+		}
+		for _, frame := range errObj.Trace {
+			fmt.Fprintf(os.Stderr, "  in %s\n", frame)
+		}
+		return 1
+	}
+	return 0
+}
@@ -1,20 +1,66 @@
 package object
 
+import "sort"
+
 type Environment struct {
-	vars  map[string]Object
-	outer *Environment
+	vars   map[string]Object
+	consts map[string]bool //const声明的名字
+	outer  *Environment
 }
 
 func (e *Environment) Get(name string) (Object, bool) {
 	obj, ok := e.vars[name]
+	if !ok && e.outer != nil {
+		return e.outer.Get(name)
+	}
 	return obj, ok
 }
 
+// 跳过内层的depth个作用域,从向外depth层的作用域开始逐层查找
+func (e *Environment) GetFrom(depth int, name string) (Object, bool) {
+	env := e
+	for i := 0; i < depth && env != nil; i++ {
+		env = env.outer
+	}
+	if env == nil {
+		return nil, false
+	}
+	return env.Get(name)
+}
+
 func (e *Environment) Set(name string, val Object) Object {
 	e.vars[name] = val
 	return val
 }
 
+// 绑定为常量,调用方应先用IsConst检查
+func (e *Environment) SetConst(name string, val Object) Object {
+	if e.consts == nil {
+		e.consts = map[string]bool{}
+	}
+	e.consts[name] = true
+	return e.Set(name, val)
+}
+
+// name在当前作用域中是否为常量,外层作用域的常量可以被遮蔽
+func (e *Environment) IsConst(name string) bool {
+	return e.consts[name]
+}
+
+// 当前作用域中绑定的名字,按字典序排列,不包括外层作用域
+func (e *Environment) Names() []string {
+	names := make([]string, 0, len(e.vars))
+	for name := range e.vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (e *Environment) Outer() *Environment {
+	return e.outer
+}
+
 func NewEnvironment() *Environment {
 	env := &Environment{}
 	env.vars = make(map[string]Object)
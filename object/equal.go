@@ -0,0 +1,66 @@
+package object
+
+// 深层比较两个值:整数,布尔,字符串比较值,数组逐个比较元素,
+// 映射比较所有键值对,结构体比较类型和各字段,其余的值(函数,模块等)比较是否为同一个对象
+func Equal(a, b Object) bool {
+	if a == b {
+		return true
+	}
+	if a == nil || b == nil || a.Type() != b.Type() {
+		return false
+	}
+	switch a := a.(type) {
+	case *Integer:
+		return a.Value == b.(*Integer).Value
+	case *Boolean:
+		return a.Value == b.(*Boolean).Value
+	case *String:
+		return a.Value == b.(*String).Value
+	case *Null:
+		return true
+	case *Array:
+		b := b.(*Array)
+		if len(a.Elements) != len(b.Elements) {
+			return false
+		}
+		for i := range a.Elements {
+			if !Equal(a.Elements[i], b.Elements[i]) {
+				return false
+			}
+		}
+		return true
+	case *Map:
+		b := b.(*Map)
+		if len(a.Mappings) != len(b.Mappings) {
+			return false
+		}
+		for _, pair := range a.Mappings {
+			other, ok := b.Get(pair.Key)
+			if !ok || !Equal(pair.Value, other.Value) {
+				return false
+			}
+		}
+		return true
+	case *Struct:
+		return a.Equals(b)
+	default:
+		return false
+	}
+}
+
+// 能否作为映射的键,数组只有在所有元素都可哈希时才可哈希
+func IsHashable(obj Object) bool {
+	switch obj := obj.(type) {
+	case *Array:
+		for _, e := range obj.Elements {
+			if !IsHashable(e) {
+				return false
+			}
+		}
+		return true
+	case Hashable:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,52 @@
+package object
+
+// 返回obj的深层不可变副本,数组,映射和结构体及其中的所有元素都被冻结,已冻结的值原样返回
+func Freeze(obj Object) Object {
+	switch obj := obj.(type) {
+	case *Array:
+		if obj.Frozen {
+			return obj
+		}
+		elements := make([]Object, len(obj.Elements))
+		for i, e := range obj.Elements {
+			elements[i] = Freeze(e)
+		}
+		return &Array{Elements: elements, Frozen: true}
+	case *Map:
+		if obj.Frozen {
+			return obj
+		}
+		mappings := make(map[HashKey]*Pair, len(obj.Mappings))
+		for hash, pair := range obj.Mappings {
+			mappings[hash] = &Pair{Key: Freeze(pair.Key), Value: Freeze(pair.Value)}
+		}
+		return &Map{Mappings: mappings, Frozen: true}
+	case *Struct:
+		if obj.Frozen {
+			return obj
+		}
+		values := make([]Object, len(obj.Values))
+		for i, v := range obj.Values {
+			values[i] = Freeze(v)
+		}
+		return &Struct{StructType: obj.StructType, Values: values, Frozen: true}
+	default:
+		return obj
+	}
+}
+
+// obj是否不可修改,原地修改数组,映射和结构体的操作应先检查
+// 结构体更新等操作产生新的值,不修改原来的值,不受冻结的限制
+// 数字,字符串,函数等其余的值没有可以修改的部分,总是返回true
+func IsFrozen(obj Object) bool {
+	switch obj := obj.(type) {
+	case *Array:
+		return obj.Frozen
+	case *Map:
+		return obj.Frozen
+	case *Struct:
+		return obj.Frozen
+	default:
+		return true
+	}
+}
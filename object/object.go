@@ -2,9 +2,11 @@ package object
 
 import (
 	"bytes"
+	"encoding/binary"
 	"fmt"
 	"hash/fnv"
 	"my-interpreter/ast"
+	"my-interpreter/token"
 	"strings"
 )
 
@@ -12,6 +14,8 @@ const (
 	NULL  = "NULL"
 	ERROR = "ERROR"
 
+	ERROR_VALUE = "ERROR_VALUE"
+
 	INTEGER = "INTEGER"
 	BOOLEAN = "BOOLEAN"
 	STRING  = "STRING"
@@ -23,6 +27,11 @@ const (
 
 	MAP   = "MAP"
 	ARRAY = "ARRAY"
+
+	MODULE = "MODULE"
+
+	STRUCT      = "STRUCT"
+	STRUCT_TYPE = "STRUCT_TYPE"
 )
 
 type ObjectType string
@@ -109,9 +118,10 @@ func (n *Null) Inspect() string {
 	return "null"
 }
 
-// 数组
+// 数组,Frozen为true时不能修改
 type Array struct {
 	Elements []Object
+	Frozen   bool
 }
 
 func (a *Array) Type() ObjectType {
@@ -129,14 +139,68 @@ func (a *Array) Inspect() string {
 	return out.String()
 }
 
+// 由各元素的哈希组合而成,元素不可哈希时只计入其类型,调用前应先用IsHashable检查
+func (a *Array) Hash() HashKey {
+	h := fnv.New64a()
+	var buf [8]byte
+	for _, e := range a.Elements {
+		var key HashKey
+		if e, ok := e.(Hashable); ok {
+			key = e.Hash()
+		}
+		_, _ = h.Write([]byte(e.Type()))
+		binary.LittleEndian.PutUint64(buf[:], key.Value)
+		_, _ = h.Write(buf[:])
+	}
+	return HashKey{Type: a.Type(), Value: h.Sum64()}
+}
+
 type Pair struct {
 	Key   Object
 	Value Object
 }
 
 // 映射
+// 不同的键哈希值相同时,后插入的键顺延到Value+1的槽位,直到遇到空槽,
+// 所以查找和插入都应通过Get和Set,不要直接用键的Hash()访问Mappings
 type Map struct {
 	Mappings map[HashKey]*Pair
+	Frozen   bool
+}
+
+func NewMap() *Map {
+	return &Map{Mappings: map[HashKey]*Pair{}}
+}
+
+// 返回键所在的槽位,键不存在时返回可以插入的空槽
+func (m *Map) slot(key Hashable) (HashKey, *Pair) {
+	hash := key.Hash()
+	for {
+		pair, ok := m.Mappings[hash]
+		if !ok || Equal(pair.Key, key.(Object)) {
+			return hash, pair
+		}
+		hash.Value++
+	}
+}
+
+// 按键查找,键不可哈希时视为不存在
+func (m *Map) Get(key Object) (*Pair, bool) {
+	if !IsHashable(key) {
+		return nil, false
+	}
+	_, pair := m.slot(key.(Hashable))
+	return pair, pair != nil
+}
+
+// 插入或替换键值对,键不可哈希时返回false
+func (m *Map) Set(key, value Object) bool {
+	if !IsHashable(key) {
+		return false
+	}
+	hash, _ := m.slot(key.(Hashable))
+	m.Mappings[hash] = &Pair{Key: key, Value: value}
+	return true
 }
 
 func (m *Map) Type() ObjectType {
@@ -155,9 +219,29 @@ func (m *Map) Inspect() string {
 	return out.String()
 }
 
-// 错误
+// 错误的种类,资源限制和取消各有一种,throw抛出的是USER_ERROR,断言失败是ASSERTION_ERROR,其余运行时错误都是RUNTIME_ERROR
+type ErrorKind string
+
+const (
+	RUNTIME_ERROR   ErrorKind = "RUNTIME_ERROR"
+	USER_ERROR      ErrorKind = "USER_ERROR"
+	ASSERTION_ERROR ErrorKind = "ASSERTION_ERROR"
+	CANCELED        ErrorKind = "CANCELED"
+	TIMEOUT         ErrorKind = "TIMEOUT"
+	DEPTH_LIMIT     ErrorKind = "DEPTH_LIMIT"
+	STEP_LIMIT      ErrorKind = "STEP_LIMIT"
+	SIZE_LIMIT      ErrorKind = "SIZE_LIMIT"
+)
+
+// 错误,沿调用栈向上传播,直到被try捕获或到达顶层
 type Error struct {
-	Msg string
+	Kind   ErrorKind
+	Msg    string
+	Value  Object //throw抛出的值,内部产生的错误为nil
+	File   string //出错的位置,Line为0时未知
+	Line   int
+	Column int
+	Trace  []string //错误经过的函数,由内向外,如"f (3:1)"
 }
 
 func (e *Error) Type() ObjectType {
@@ -167,9 +251,55 @@ func (e *Error) Inspect() string {
 	return e.Msg
 }
 
+// 能否被try捕获,超时,取消和资源限制不能
+func (e *Error) Catchable() bool {
+	return e.Kind == RUNTIME_ERROR || e.Kind == USER_ERROR || e.Kind == ASSERTION_ERROR
+}
+
+// 作为值的错误,由error()创建或由catch捕获内部错误得到,不会自动传播
+type ErrorValue struct {
+	Kind   ErrorKind
+	Msg    string
+	Data   Object
+	File   string
+	Line   int
+	Column int
+}
+
+func (ev *ErrorValue) Type() ObjectType {
+	return ERROR_VALUE
+}
+func (ev *ErrorValue) Inspect() string {
+	return "error: " + ev.Msg
+}
+
+// 脚本中用e.kind,e.message,e.data,e.file,e.line,e.column读取
+func (ev *ErrorValue) Field(name string) (Object, bool) {
+	switch name {
+	case "kind":
+		return &String{Value: string(ev.Kind)}, true
+	case "message":
+		return &String{Value: ev.Msg}, true
+	case "data":
+		return ev.Data, true
+	case "file":
+		return &String{Value: ev.File}, true
+	case "line":
+		return &Integer{Value: int64(ev.Line)}, true
+	case "column":
+		return &Integer{Value: int64(ev.Column)}, true
+	}
+	return nil, false
+}
+
 // 函数
 type Function struct {
+	Name       string      //具名声明或let绑定的名字,匿名函数为空
+	Token      token.Token //fn关键字,记录定义的位置
+	File       string      //定义函数的文件,函数体中的错误和钩子使用它
 	Parameters []*ast.Identifier
+	Defaults   []ast.Expression
+	Rest       *ast.Identifier
 	Body       *ast.BlockStatement
 	Env        *Environment
 }
@@ -180,13 +310,12 @@ func (f *Function) Type() ObjectType {
 
 func (f *Function) Inspect() string {
 	var out bytes.Buffer
-	var params []string
-	for _, p := range f.Parameters {
-		params = append(params, p.String())
-	}
 	out.WriteString("fn")
+	if f.Name != "" {
+		out.WriteString(" " + f.Name)
+	}
 	out.WriteString("(")
-	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(ast.ParametersString(f.Parameters, f.Defaults, f.Rest))
 	out.WriteString(")")
 	out.WriteString(" ")
 	out.WriteString(f.Body.String())
@@ -208,8 +337,13 @@ func (r *Return) Inspect() string {
 // 内置函数
 type BuiltinFunction func(params ...Object) Object
 
+// 调用脚本函数或内置函数,由求值器提供给需要回调的内置函数
+type Caller func(fn Object, args ...Object) Object
+
 type Builtins struct {
-	Fn BuiltinFunction
+	Name string //注册的名字,用于跟踪
+	Fn   BuiltinFunction
+	Call func(call Caller, params ...Object) Object //不为nil时求值器调用它而不是Fn
 }
 
 func (b *Builtins) Type() ObjectType {
@@ -218,3 +352,104 @@ func (b *Builtins) Type() ObjectType {
 func (b *Builtins) Inspect() string {
 	return "builtin function"
 }
+
+// 模块,Env中不以下划线开头的名字是导出的
+type Module struct {
+	Name string
+	Path string
+	Env  *Environment
+}
+
+func (m *Module) Type() ObjectType {
+	return MODULE
+}
+func (m *Module) Inspect() string {
+	return "module " + m.Name
+}
+
+// 按名字查找导出的绑定
+func (m *Module) Member(name string) (Object, bool) {
+	if strings.HasPrefix(name, "_") {
+		return nil, false
+	}
+	return m.Env.Get(name)
+}
+
+// 所有导出的名字,按字典序排列
+func (m *Module) Exports() []string {
+	var names []string
+	for _, name := range m.Env.Names() {
+		if !strings.HasPrefix(name, "_") {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// 结构体类型,由struct声明创建
+type StructType struct {
+	Name   string
+	Fields []string
+}
+
+func (st *StructType) Type() ObjectType {
+	return STRUCT_TYPE
+}
+func (st *StructType) Inspect() string {
+	return "struct " + st.Name + " { " + strings.Join(st.Fields, ", ") + " }"
+}
+
+func (st *StructType) HasField(name string) bool {
+	for _, f := range st.Fields {
+		if f == name {
+			return true
+		}
+	}
+	return false
+}
+
+// 结构体值,Values按StructType.Fields的顺序存放
+type Struct struct {
+	StructType *StructType
+	Values     []Object
+	Frozen     bool
+}
+
+func (s *Struct) Type() ObjectType {
+	return STRUCT
+}
+func (s *Struct) Inspect() string {
+	var out bytes.Buffer
+	var fields []string
+	for i, f := range s.StructType.Fields {
+		fields = append(fields, f+": "+s.Values[i].Inspect())
+	}
+	out.WriteString(s.StructType.Name)
+	out.WriteString("{")
+	out.WriteString(strings.Join(fields, ", "))
+	out.WriteString("}")
+	return out.String()
+}
+
+func (s *Struct) Field(name string) (Object, bool) {
+	for i, f := range s.StructType.Fields {
+		if f == name {
+			return s.Values[i], true
+		}
+	}
+	return nil, false
+}
+
+// 类型相同且各字段相等
+func (s *Struct) Equals(other Object) bool {
+	o, ok := other.(*Struct)
+	if !ok || o.StructType != s.StructType {
+		return false
+	}
+	for i := range s.Values {
+		if !Equal(s.Values[i], o.Values[i]) {
+			return false
+		}
+	}
+	return true
+}
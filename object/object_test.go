@@ -58,3 +58,137 @@ func TestIntegerHash(t *testing.T) {
 		t.Errorf("integers with twoerent content have same hash keys")
 	}
 }
+
+func TestArrayHash(t *testing.T) {
+	a1 := &Array{Elements: []Object{&Integer{Value: 1}, &String{Value: "a"}}}
+	a2 := &Array{Elements: []Object{&Integer{Value: 1}, &String{Value: "a"}}}
+	a3 := &Array{Elements: []Object{&String{Value: "a"}, &Integer{Value: 1}}}
+	nested := &Array{Elements: []Object{a1, &Array{}}}
+
+	if a1.Hash() != a2.Hash() {
+		t.Errorf("arrays with same elements have different hash keys")
+	}
+	if a1.Hash() == a3.Hash() {
+		t.Errorf("arrays with different elements have same hash keys")
+	}
+	if !IsHashable(nested) {
+		t.Errorf("nested array of hashable elements should be hashable")
+	}
+	if IsHashable(&Array{Elements: []Object{&Map{}}}) {
+		t.Errorf("array containing a map should not be hashable")
+	}
+}
+
+func TestEqual(t *testing.T) {
+	m1 := NewMap()
+	m1.Set(&String{Value: "k"}, &Array{Elements: []Object{&Integer{Value: 1}}})
+	m2 := NewMap()
+	m2.Set(&String{Value: "k"}, &Array{Elements: []Object{&Integer{Value: 1}}})
+	m3 := NewMap()
+	m3.Set(&String{Value: "k"}, &Array{Elements: []Object{&Integer{Value: 2}}})
+	point := &StructType{Name: "Point", Fields: []string{"x"}}
+	fn := &Function{}
+
+	tests := []struct {
+		a, b     Object
+		expected bool
+	}{
+		{&Integer{Value: 1}, &Integer{Value: 1}, true},
+		{&Boolean{Value: true}, &Boolean{Value: true}, true},
+		{&Integer{Value: 1}, &String{Value: "1"}, false},
+		{&Null{}, &Null{}, true},
+		{&Array{}, &Array{}, true},
+		{&Array{Elements: []Object{&Integer{Value: 1}}}, &Array{}, false},
+		{m1, m2, true},
+		{m1, m3, false},
+		{m1, NewMap(), false},
+		{&Struct{StructType: point, Values: []Object{m1}}, &Struct{StructType: point, Values: []Object{m2}}, true},
+		{fn, fn, true},
+		{fn, &Function{}, false},
+	}
+	for i, tt := range tests {
+		if Equal(tt.a, tt.b) != tt.expected {
+			t.Errorf("tests[%d]: Equal(%s, %s) expected %t", i, tt.a.Inspect(), tt.b.Inspect(), tt.expected)
+		}
+	}
+}
+
+func TestMapHashCollision(t *testing.T) {
+	m := NewMap()
+	a := &String{Value: "a"}
+	b := &String{Value: "b"}
+	//模拟哈希冲突:b占据了a的槽位
+	m.Mappings[a.Hash()] = &Pair{Key: b, Value: &Integer{Value: 2}}
+	m.Set(a, &Integer{Value: 1})
+	m.Set(&String{Value: "a"}, &Integer{Value: 10})
+
+	if len(m.Mappings) != 2 {
+		t.Fatalf("expected 2 pairs. got=%d", len(m.Mappings))
+	}
+	if pair, ok := m.Get(&String{Value: "a"}); !ok || pair.Value.(*Integer).Value != 10 {
+		t.Errorf("a was not stored after the colliding key")
+	}
+	if pair, ok := m.Mappings[a.Hash()]; !ok || pair.Key != b {
+		t.Errorf("colliding key was overwritten")
+	}
+	if _, ok := m.Get(&String{Value: "c"}); ok {
+		t.Errorf("found missing key")
+	}
+}
+
+func TestFreeze(t *testing.T) {
+	inner := &Array{Elements: []Object{&Integer{Value: 1}}}
+	m := NewMap()
+	m.Set(&String{Value: "k"}, inner)
+	frozen := Freeze(&Array{Elements: []Object{m}}).(*Array)
+
+	if !frozen.Frozen || !IsFrozen(frozen.Elements[0]) {
+		t.Fatalf("array or nested map not frozen")
+	}
+	pair, _ := frozen.Elements[0].(*Map).Get(&String{Value: "k"})
+	if !IsFrozen(pair.Value) {
+		t.Errorf("nested array not frozen")
+	}
+	if inner.Frozen || m.Frozen {
+		t.Errorf("Freeze modified the original values")
+	}
+	if Freeze(frozen) != frozen {
+		t.Errorf("freezing a frozen value should return it unchanged")
+	}
+	if !Equal(frozen, &Array{Elements: []Object{m}}) {
+		t.Errorf("frozen copy should equal the original")
+	}
+}
+
+func TestGetFrom(t *testing.T) {
+	global := NewEnvironment()
+	global.Set("a", &Integer{Value: 1})
+	outer := NewEnclosedEnvironment(global)
+	outer.Set("b", &Integer{Value: 2})
+	inner := NewEnclosedEnvironment(outer)
+	inner.Set("a", &Integer{Value: 3})
+
+	tests := []struct {
+		depth    int
+		name     string
+		expected int64 //0表示找不到
+	}{
+		{0, "a", 3},
+		{1, "a", 1}, //跳过内层的a
+		{1, "b", 2},
+		{2, "b", 0},
+		{3, "a", 0},
+	}
+	for _, tt := range tests {
+		obj, ok := inner.GetFrom(tt.depth, tt.name)
+		if tt.expected == 0 {
+			if ok {
+				t.Errorf("GetFrom(%d, %s) = %v, want not found", tt.depth, tt.name, obj)
+			}
+			continue
+		}
+		if i, isInt := obj.(*Integer); !ok || !isInt || i.Value != tt.expected {
+			t.Errorf("GetFrom(%d, %s) = %v, want %d", tt.depth, tt.name, obj, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,212 @@
+package optimizer
+
+import (
+	"my-interpreter/ast"
+	builtins "my-interpreter/evaluator"
+	"path/filepath"
+	"strings"
+)
+
+// 找出可以内联的顶层函数
+// 函数名在整个程序中只能声明一次,这样所有对这个名字的引用都指向它
+// 函数体中的内置函数不能在程序中被重新声明
+func findCandidates(program *ast.Program) map[string]*candidate {
+	decls := declarations(program)
+	res := map[string]*candidate{}
+	for i, stmt := range program.Statements {
+		var name *ast.Identifier
+		var fn *ast.FunctionLiteral
+		hoist := false
+		switch stmt := stmt.(type) {
+		case *ast.LetStatement:
+			fn, _ = stmt.Value.(*ast.FunctionLiteral)
+			name = stmt.Name
+		case *ast.FunctionStatement:
+			fn, name, hoist = stmt.Function, stmt.Name, true
+		}
+		if fn == nil || decls[name.Token.Literal] != 1 || fn.Rest != nil || len(fn.Body.Statements) != 1 {
+			continue
+		}
+		var body ast.Expression
+		switch s := fn.Body.Statements[0].(type) {
+		case *ast.ExpressionStatement:
+			body = s.Expr
+		case *ast.ReturnStatement:
+			body = s.ReturnValue
+		}
+		c := &candidate{body: body, index: i, hoist: hoist}
+		uses := map[string]int{}
+		for j, param := range fn.Parameters {
+			if j < len(fn.Defaults) && fn.Defaults[j] != nil {
+				c = nil
+				break
+			}
+			c.params = append(c.params, param.Token.Literal)
+			uses[param.Token.Literal] = 0
+		}
+		if c == nil || body == nil {
+			continue
+		}
+		size := 0
+		if !inlinable(body, uses, decls, &size) || size > maxInlineSize {
+			continue
+		}
+		//每个参数至少用到一次,这样实参中未定义的名字仍会报错
+		for _, n := range uses {
+			if n == 0 {
+				c = nil
+				break
+			}
+		}
+		if c != nil {
+			res[name.Token.Literal] = c
+		}
+	}
+	return res
+}
+
+// 程序中每个名字被声明的次数
+func declarations(program *ast.Program) map[string]int {
+	decls := map[string]int{}
+	add := func(ident *ast.Identifier) {
+		if ident != nil {
+			decls[ident.Token.Literal]++
+		}
+	}
+	ast.Inspect(program, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.LetStatement:
+			add(n.Name)
+		case *ast.FunctionStatement:
+			add(n.Name)
+		case *ast.FunctionLiteral:
+			for _, param := range n.Parameters {
+				add(param)
+			}
+			add(n.Rest)
+		case *ast.StructStatement:
+			add(n.Name)
+		case *ast.ImportStatement:
+			if n.Alias != nil {
+				add(n.Alias)
+			} else {
+				path := n.Path.Token.Literal
+				decls[strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))]++
+			}
+		case *ast.TryExpression:
+			add(n.Param)
+		case *ast.BindingPattern:
+			add(n.Name)
+		case *ast.ArrayPattern:
+			add(n.Rest)
+		}
+		return true
+	})
+	return decls
+}
+
+// 函数体只能由字面量,参数,运算符,索引和对内置函数的调用组成,size累计节点数
+func inlinable(expr ast.Expression, params map[string]int, decls map[string]int, size *int) bool {
+	*size++
+	switch expr := expr.(type) {
+	case *ast.IntLiteral, *ast.BoolLiteral, *ast.StrLiteral:
+		return true
+	case *ast.Identifier:
+		name := expr.Token.Literal
+		if _, ok := params[name]; ok {
+			params[name]++
+			return true
+		}
+		return false
+	case *ast.PrefixExpression:
+		return inlinable(expr.Right, params, decls, size)
+	case *ast.InfixExpression:
+		return inlinable(expr.Left, params, decls, size) && inlinable(expr.Right, params, decls, size)
+	case *ast.IndexExpression:
+		return inlinable(expr.Left, params, decls, size) && inlinable(expr.Index, params, decls, size)
+	case *ast.ArrLiteral:
+		for _, e := range expr.Elements {
+			if !inlinable(e, params, decls, size) {
+				return false
+			}
+		}
+		return true
+	case *ast.CallExpression:
+		callee, ok := expr.Function.(*ast.Identifier)
+		if !ok || decls[callee.Token.Literal] > 0 {
+			return false
+		}
+		if _, ok := builtins.BuiltinSignature(callee.Token.Literal); !ok {
+			return false
+		}
+		for _, arg := range expr.Arguments {
+			switch arg.(type) {
+			case *ast.SpreadExpression, *ast.NamedArgument:
+				return false
+			}
+			if !inlinable(arg, params, decls, size) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// 实参都是字面量或名字且个数与参数相同时,用代入实参后的函数体代替调用
+// 这样的实参没有副作用,代入多次或改变求值顺序都不影响结果
+// let定义的函数只内联之后的顶层语句中的调用,提前绑定的具名函数中的调用可能在let之前执行
+func (o *optimizer) inlineCall(call *ast.CallExpression) (ast.Expression, bool) {
+	callee, ok := call.Function.(*ast.Identifier)
+	if !ok {
+		return nil, false
+	}
+	c, ok := o.inline[callee.Token.Literal]
+	if !ok || len(call.Arguments) != len(c.params) {
+		return nil, false
+	}
+	if !c.hoist && (o.hoisted || o.index <= c.index) {
+		return nil, false
+	}
+	subst := map[string]ast.Expression{}
+	for i, arg := range call.Arguments {
+		switch arg.(type) {
+		case *ast.IntLiteral, *ast.BoolLiteral, *ast.StrLiteral, *ast.Identifier:
+			subst[c.params[i]] = arg
+		default:
+			return nil, false
+		}
+	}
+	return clone(c.body, subst), true
+}
+
+// 复制inlinable接受的表达式,参数换成subst中的实参
+func clone(expr ast.Expression, subst map[string]ast.Expression) ast.Expression {
+	switch expr := expr.(type) {
+	case *ast.Identifier:
+		if arg, ok := subst[expr.Token.Literal]; ok {
+			return arg
+		}
+		return expr
+	case *ast.PrefixExpression:
+		return &ast.PrefixExpression{Token: expr.Token, Right: clone(expr.Right, subst)}
+	case *ast.InfixExpression:
+		return &ast.InfixExpression{Token: expr.Token, Left: clone(expr.Left, subst), Right: clone(expr.Right, subst)}
+	case *ast.IndexExpression:
+		return &ast.IndexExpression{Token: expr.Token, Left: clone(expr.Left, subst), Index: clone(expr.Index, subst)}
+	case *ast.ArrLiteral:
+		res := &ast.ArrLiteral{Token: expr.Token}
+		for _, e := range expr.Elements {
+			res.Elements = append(res.Elements, clone(e, subst))
+		}
+		return res
+	case *ast.CallExpression:
+		res := &ast.CallExpression{Token: expr.Token, Function: expr.Function}
+		for _, arg := range expr.Arguments {
+			res.Arguments = append(res.Arguments, clone(arg, subst))
+		}
+		return res
+	}
+	//字面量不会被修改,可以共用
+	return expr
+}
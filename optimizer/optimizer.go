@@ -0,0 +1,270 @@
+// optimizer包在求值之前改写语法树:折叠字面量的运算,删除条件为字面量的if的死分支,内联简单的非递归函数
+// 改写后的程序与原程序的求值结果和错误消息相同,但内联的调用不再出现在错误的调用栈中
+package optimizer
+
+import (
+	"my-interpreter/ast"
+	builtins "my-interpreter/evaluator"
+	"my-interpreter/object"
+	"my-interpreter/token"
+	"strconv"
+)
+
+// 折叠得到的字符串超过这个长度时不折叠,以免绕过求值时的大小限制
+const maxFoldedString = 1024
+
+// 可以内联的函数体最多的节点数
+const maxInlineSize = 16
+
+// 可以内联的顶层函数,函数体只有一个表达式,其中只引用参数和内置函数
+type candidate struct {
+	params []string
+	body   ast.Expression
+	index  int  //定义所在的顶层语句的序号
+	hoist  bool //由fn name() {}声明,在其他语句之前绑定
+}
+
+type optimizer struct {
+	inline  map[string]*candidate
+	index   int  //正在改写的顶层语句的序号
+	hoisted bool //正在改写顶层的具名函数
+}
+
+// 原地改写program并返回它
+func Optimize(program *ast.Program) *ast.Program {
+	o := &optimizer{inline: findCandidates(program)}
+	var statements []ast.Statement
+	for i, stmt := range program.Statements {
+		o.index = i
+		_, o.hoisted = stmt.(*ast.FunctionStatement)
+		statements = o.appendStatement(statements, stmt, i == len(program.Statements)-1, true)
+	}
+	program.Statements = statements
+	return program
+}
+
+func (o *optimizer) statements(statements []ast.Statement) []ast.Statement {
+	var res []ast.Statement
+	for i, stmt := range statements {
+		res = o.appendStatement(res, stmt, i == len(statements)-1, false)
+	}
+	return res
+}
+
+// 改写stmt后加入res,条件为字面量的if语句由选中的分支中的语句代替
+// 语句块不产生作用域,所以分支中的let展开后仍绑定在同一个环境中
+// 语句列表的最后一条决定其值,展开空的分支会改变这个值,所以不展开
+func (o *optimizer) appendStatement(res []ast.Statement, stmt ast.Statement, last, top bool) []ast.Statement {
+	stmt = o.statement(stmt)
+	es, ok := stmt.(*ast.ExpressionStatement)
+	if !ok {
+		return append(res, stmt)
+	}
+	ifExpr, ok := es.Expr.(*ast.IfExpression)
+	if !ok {
+		return append(res, stmt)
+	}
+	truthy, ok := constantTruth(ifExpr.Condition)
+	if !ok {
+		return append(res, stmt)
+	}
+	chosen := ifExpr.Consequence
+	if !truthy {
+		chosen = ifExpr.Alternative
+	}
+	switch {
+	case chosen == nil && !last:
+		return res
+	case chosen == nil || last && len(chosen.Statements) == 0:
+		return append(res, stmt)
+	case top && hasFunctionStatement(chosen):
+		//顶层的具名函数会提前绑定,不能从分支中移出
+		return append(res, stmt)
+	}
+	return append(res, chosen.Statements...)
+}
+
+func hasFunctionStatement(block *ast.BlockStatement) bool {
+	for _, stmt := range block.Statements {
+		if _, ok := stmt.(*ast.FunctionStatement); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (o *optimizer) statement(stmt ast.Statement) ast.Statement {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		stmt.Value = o.expr(stmt.Value)
+	case *ast.ReturnStatement:
+		stmt.ReturnValue = o.expr(stmt.ReturnValue)
+	case *ast.ThrowStatement:
+		stmt.Value = o.expr(stmt.Value)
+	case *ast.ExpressionStatement:
+		stmt.Expr = o.expr(stmt.Expr)
+	case *ast.FunctionStatement:
+		o.function(stmt.Function)
+	case *ast.BlockStatement:
+		return o.block(stmt)
+	}
+	return stmt
+}
+
+func (o *optimizer) block(block *ast.BlockStatement) *ast.BlockStatement {
+	if block != nil {
+		block.Statements = o.statements(block.Statements)
+	}
+	return block
+}
+
+func (o *optimizer) function(fn *ast.FunctionLiteral) {
+	for i, def := range fn.Defaults {
+		if def != nil {
+			fn.Defaults[i] = o.expr(def)
+		}
+	}
+	o.block(fn.Body)
+}
+
+func (o *optimizer) exprs(exprs []ast.Expression) {
+	for i, e := range exprs {
+		exprs[i] = o.expr(e)
+	}
+}
+
+// 先改写子表达式,再尝试折叠或内联当前表达式
+func (o *optimizer) expr(expr ast.Expression) ast.Expression {
+	switch node := expr.(type) {
+	case *ast.PrefixExpression:
+		node.Right = o.expr(node.Right)
+		return fold(node)
+	case *ast.InfixExpression:
+		node.Left = o.expr(node.Left)
+		node.Right = o.expr(node.Right)
+		return fold(node)
+	case *ast.IfExpression:
+		return o.ifExpr(node)
+	case *ast.CallExpression:
+		node.Function = o.expr(node.Function)
+		o.exprs(node.Arguments)
+		if body, ok := o.inlineCall(node); ok {
+			return o.expr(body)
+		}
+	case *ast.ArrLiteral:
+		o.exprs(node.Elements)
+	case *ast.MapLiteral:
+		pairs := make(map[ast.Expression]ast.Expression, len(node.Pairs))
+		for key, val := range node.Pairs {
+			pairs[o.expr(key)] = o.expr(val)
+		}
+		node.Pairs = pairs
+	case *ast.FunctionLiteral:
+		o.function(node)
+	case *ast.IndexExpression:
+		node.Left = o.expr(node.Left)
+		node.Index = o.expr(node.Index)
+	case *ast.SelectorExpression:
+		node.Left = o.expr(node.Left)
+	case *ast.SpreadExpression:
+		node.Value = o.expr(node.Value)
+	case *ast.NamedArgument:
+		node.Value = o.expr(node.Value)
+	case *ast.StructLiteral:
+		node.Type = o.expr(node.Type)
+		o.exprs(node.Values)
+	case *ast.TryExpression:
+		o.block(node.Body)
+		o.block(node.Catch)
+		o.block(node.Finally)
+	case *ast.MatchExpression:
+		node.Value = o.expr(node.Value)
+		for _, arm := range node.Arms {
+			if arm.Guard != nil {
+				arm.Guard = o.expr(arm.Guard)
+			}
+			arm.Body = o.expr(arm.Body)
+		}
+	}
+	return expr
+}
+
+// 条件为字面量时只保留选中的分支,分支只有一个表达式时直接用这个表达式代替if
+// 条件为假又没有else的if的值是null,语言中没有null字面量,保持不变
+func (o *optimizer) ifExpr(node *ast.IfExpression) ast.Expression {
+	node.Condition = o.expr(node.Condition)
+	o.block(node.Consequence)
+	o.block(node.Alternative)
+	truthy, ok := constantTruth(node.Condition)
+	if !ok {
+		return node
+	}
+	chosen := node.Consequence
+	if !truthy {
+		chosen = node.Alternative
+	}
+	if chosen == nil {
+		return node
+	}
+	if len(chosen.Statements) == 1 {
+		if es, ok := chosen.Statements[0].(*ast.ExpressionStatement); ok {
+			return es.Expr
+		}
+	}
+	tok := ast.Pos(node.Condition)
+	node.Condition = &ast.BoolLiteral{Token: token.Token{Type: token.TRUE, Literal: "true", Line: tok.Line, Column: tok.Column}, Value: true}
+	node.Consequence, node.Alternative = chosen, nil
+	return node
+}
+
+// 字面量条件的真假,与求值时的isTruthy一致:只有false为假
+func constantTruth(expr ast.Expression) (bool, bool) {
+	switch expr := expr.(type) {
+	case *ast.BoolLiteral:
+		return expr.Value, true
+	case *ast.IntLiteral, *ast.StrLiteral:
+		return true, true
+	}
+	return false, false
+}
+
+func isLiteral(expr ast.Expression) bool {
+	switch expr.(type) {
+	case *ast.IntLiteral, *ast.BoolLiteral, *ast.StrLiteral:
+		return true
+	}
+	return false
+}
+
+// 操作数都是字面量时用求值器计算结果,保证与求值时一致,出错时保留原表达式以便在求值时报告
+func fold(expr ast.Expression) ast.Expression {
+	switch expr := expr.(type) {
+	case *ast.PrefixExpression:
+		if !isLiteral(expr.Right) {
+			return expr
+		}
+	case *ast.InfixExpression:
+		if !isLiteral(expr.Left) || !isLiteral(expr.Right) {
+			return expr
+		}
+	}
+	tok := ast.Pos(expr)
+	switch res := builtins.Eval(expr, object.NewEnvironment()).(type) {
+	case *object.Integer:
+		tok.Type, tok.Literal = token.INT, strconv.FormatInt(res.Value, 10)
+		return &ast.IntLiteral{Token: tok, Value: res.Value}
+	case *object.Boolean:
+		tok.Type, tok.Literal = token.FALSE, "false"
+		if res.Value {
+			tok.Type, tok.Literal = token.TRUE, "true"
+		}
+		return &ast.BoolLiteral{Token: tok, Value: res.Value}
+	case *object.String:
+		if len(res.Value) > maxFoldedString {
+			return expr
+		}
+		tok.Type, tok.Literal = token.STRING, res.Value
+		return &ast.StrLiteral{Token: tok}
+	}
+	return expr
+}
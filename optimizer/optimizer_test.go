@@ -0,0 +1,98 @@
+package optimizer
+
+import (
+	"my-interpreter/ast"
+	builtins "my-interpreter/evaluator"
+	"my-interpreter/lexer"
+	"my-interpreter/object"
+	"my-interpreter/parser"
+	"testing"
+)
+
+func parse(t *testing.T, input string) *ast.Program {
+	t.Helper()
+	p := parser.NewParser(lexer.NewLexer(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser has errors for %q: %v", input, p.Errors())
+	}
+	return program
+}
+
+func TestOptimize(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`2 * 60 * 60`, "7200;\n"},
+		{`let f = fn(x) { x * (2 * 60) };`, "let f = fn(x) {\n\t(x * 120);\n};\n"},
+		{`-(1 + 2); !true; 1 < 2; "a" + "b"; 1 == "1"`, "-3;\nfalse;\ntrue;\nab;\nfalse;\n"},
+		{`1 / 0; -"a"; 1 + x`, "(1 / 0);\n(-a);\n(1 + x);\n"},
+		{`let a = if (1 > 2) { 1 } else { 2 };`, "let a = 2;\n"},
+		{`if (true) { let a = 1; prints(a) }; a`, "let a = 1;\nprints(a);\na;\n"},
+		{`if (false) { 1 }; 2`, "2;\n"},
+		{`let b = if (false) { 1 };`, "let b = if false {\n\t1;\n};\n"},
+		{`let c = if (0) { let x = 1; x } else { 2 };`, "let c = if true {\n\tlet x = 1;\n\tx;\n};\n"},
+		{`if (true) { fn g() { 1 } }`, "if true {\n\tfn g() {\n\t1;\n}\n};\n"},
+		{`let x = 1; if (x) { 2 } else { 3 }`, "let x = 1;\nif x {\n\t2;\n} else {\n\t3;\n};\n"},
+		{`let double = fn(x) { x * 2 }; double(21)`, "let double = fn(x) {\n\t(x * 2);\n};\n42;\n"},
+		{`let y = 3; let add = fn(a, b) { a + b }; add(y, 1)`, "let y = 3;\nlet add = fn(a, b) {\n\t(a + b);\n};\n(y + 1);\n"},
+		{`size(1); fn size(a) { len([a, a]) }`, "len([1, 1]);\nfn size(a) {\n\tlen([a, a]);\n}\n"},
+		//实参有副作用,先于let的调用,递归,引用非参数的名字和被重新声明的名字都不内联
+		{`let f = fn(x) { x + 1 }; f(g())`, "let f = fn(x) {\n\t(x + 1);\n};\nf(g());\n"},
+		{`f(1); let f = fn(x) { x + 1 };`, "f(1);\nlet f = fn(x) {\n\t(x + 1);\n};\n"},
+		{`fn r(n) { r(n) } r(1)`, "fn r(n) {\n\tr(n);\n}\nr(1);\n"},
+		{`let k = 2; let f = fn(x) { x + k }; f(1)`, "let k = 2;\nlet f = fn(x) {\n\t(x + k);\n};\nf(1);\n"},
+		{`let f = fn(x) { x }; let f = fn(x) { 2 }; f(1)`, "let f = fn(x) {\n\tx;\n};\nlet f = fn(x) {\n\t2;\n};\nf(1);\n"},
+		{`let f = fn(x) { len(x) }; let len = fn(x) { 0 }; f("a")`, "let f = fn(x) {\n\tlen(x);\n};\nlet len = fn(x) {\n\t0;\n};\nf(a);\n"},
+		{`let f = fn(x, y) { x }; f(1, 2)`, "let f = fn(x, y) {\n\tx;\n};\nf(1, 2);\n"},
+	}
+	for _, tt := range tests {
+		program := Optimize(parse(t, tt.input))
+		if program.String() != tt.expected {
+			t.Errorf("%q:\nexpected=%q\ngot=     %q", tt.input, tt.expected, program.String())
+		}
+	}
+}
+
+// 优化前后的求值结果应当相同
+func TestSameResults(t *testing.T) {
+	inputs := []string{
+		`let secs = fn(h) { h * 60 * 60 }; secs(2) + 2 * 60 * 60`,
+		`let sq = fn(x) { x * x }; let n = 7; [sq(n), sq(3), sq(n) - sq(2)]`,
+		`fn wrap(x) { [x, len([x])] } wrap("a")`,
+		`let a = if (1 < 2) { "yes" } else { "no" }; a + "!"`,
+		`if (true) { let v = 10; }; v * 2`,
+		`let f = fn() { if (false) { return 1; }; if (1) { return 2; }; 3 }; f()`,
+		`let x = if (false) { 1 }; x`,
+		`if (true) { }`,
+		`let g = fn(a) { 10 / a }; g(0)`,
+		`let g = fn(a) { a + 1 }; g(nope)`,
+		`1 + "a"`,
+		`let inc = fn(x) { x + 1 }; let twice = fn(f, x) { f(f(x)) }; twice(inc, 1) + inc(inc(1))`,
+		`let m = {"k" + "ey": 1 + 1}; m["key"]`,
+		`match (2 * 3) { 6 => "six", _ => "other" }`,
+		`try { throw "x" + "y" } catch (e) { e }`,
+		`fn fact(n) { if (n < 2) { 1 } else { n * fact(n - 1) } } fact(10)`,
+	}
+	for _, input := range inputs {
+		want := builtins.Eval(parse(t, input), object.NewEnvironment())
+		got := builtins.Eval(Optimize(parse(t, input)), object.NewEnvironment())
+		if inspect(got) != inspect(want) {
+			t.Errorf("%q: optimized result differs. expected=%s, got=%s", input, inspect(want), inspect(got))
+		}
+		if werr, ok := want.(*object.Error); ok {
+			gerr, ok := got.(*object.Error)
+			if !ok || gerr.Line != werr.Line || gerr.Column != werr.Column {
+				t.Errorf("%q: error position differs. expected=%d:%d, got=%v", input, werr.Line, werr.Column, got)
+			}
+		}
+	}
+}
+
+func inspect(obj object.Object) string {
+	if obj == nil {
+		return "<nil>"
+	}
+	return string(obj.Type()) + " " + obj.Inspect()
+}
@@ -0,0 +1,200 @@
+package parser
+
+import (
+	"fmt"
+	"my-interpreter/ast"
+	"my-interpreter/token"
+)
+
+// match (value) { pattern => expr, pattern if cond => expr, ... }
+func (p *Parser) parseMatchExpression() ast.Expression {
+	expr := &ast.MatchExpression{Token: p.curToken}
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+	p.nextToken()
+	expr.Value = p.parseExpression(LOWEST)
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+	for !p.peekTokenIs(token.RBRACE) {
+		p.nextToken()
+		arm := &ast.MatchArm{Token: p.curToken}
+		arm.Pattern = p.parsePattern()
+		if arm.Pattern == nil {
+			return nil
+		}
+		if p.peekTokenIs(token.IF) {
+			p.nextToken()
+			p.nextToken()
+			arm.Guard = p.parseExpression(LOWEST)
+		}
+		if !p.expectPeek(token.ARROW) {
+			return nil
+		}
+		p.nextToken()
+		arm.Body = p.parseExpression(LOWEST)
+		expr.Arms = append(expr.Arms, arm)
+		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+	p.nextToken()
+	expr.Rbrace = p.curToken
+	if len(expr.Arms) == 0 {
+		p.addError(p.curToken, "match expression has no arms")
+		return nil
+	}
+	p.checkMatchArms(expr)
+	return expr
+}
+
+// 调用时curToken为模式的第一个token,返回时为模式的最后一个token
+func (p *Parser) parsePattern() ast.Pattern {
+	switch p.curToken.Type {
+	case token.IDENT:
+		if p.curToken.Literal == "_" {
+			return &ast.WildcardPattern{Token: p.curToken}
+		}
+		return &ast.BindingPattern{Name: &ast.Identifier{Token: p.curToken}}
+	case token.INT, token.STRING, token.TRUE, token.FALSE:
+		return &ast.LiteralPattern{Value: p.prefixParseFns[p.curToken.Type]()}
+	case token.MINUS:
+		if !p.peekTokenIs(token.INT) {
+			break
+		}
+		return &ast.LiteralPattern{Value: p.parsePrefixExpression()}
+	case token.LBRACKET:
+		return p.parseArrayPattern()
+	case token.LBRACE:
+		return p.parseMapPattern()
+	}
+	p.addError(p.curToken, fmt.Sprintf("invalid pattern: %s", p.curToken.Literal))
+	return nil
+}
+
+// [a, b, ...rest],...只能出现在最后
+func (p *Parser) parseArrayPattern() ast.Pattern {
+	pattern := &ast.ArrayPattern{Token: p.curToken}
+	for !p.peekTokenIs(token.RBRACKET) {
+		p.nextToken()
+		if p.curTokenIs(token.ELLIPSIS) {
+			pattern.HasRest = true
+			if p.peekTokenIs(token.IDENT) {
+				p.nextToken()
+				if p.curToken.Literal != "_" {
+					pattern.Rest = &ast.Identifier{Token: p.curToken}
+				}
+			}
+			if !p.expectPeek(token.RBRACKET) {
+				return nil
+			}
+			return pattern
+		}
+		elem := p.parsePattern()
+		if elem == nil {
+			return nil
+		}
+		pattern.Elements = append(pattern.Elements, elem)
+		if !p.peekTokenIs(token.RBRACKET) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+	p.nextToken()
+	return pattern
+}
+
+// {"key": pattern, ...},键只能是字面量
+func (p *Parser) parseMapPattern() ast.Pattern {
+	pattern := &ast.MapPattern{Token: p.curToken}
+	for !p.peekTokenIs(token.RBRACE) {
+		p.nextToken()
+		key, ok := p.parsePattern().(*ast.LiteralPattern)
+		if !ok {
+			p.addError(p.curToken, "map pattern keys must be literals")
+			return nil
+		}
+		if !p.expectPeek(token.COLON) {
+			return nil
+		}
+		p.nextToken()
+		val := p.parsePattern()
+		if val == nil {
+			return nil
+		}
+		pattern.Keys = append(pattern.Keys, key.Value)
+		pattern.Values = append(pattern.Values, val)
+		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+	p.nextToken()
+	return pattern
+}
+
+// 前面某个没有guard的分支能匹配的值包含了这个分支能匹配的所有值时,这个分支永远不会执行
+func (p *Parser) checkMatchArms(expr *ast.MatchExpression) {
+	for i, arm := range expr.Arms {
+		for _, prev := range expr.Arms[:i] {
+			if prev.Guard == nil && patternCovers(prev.Pattern, arm.Pattern) {
+				p.addWarning(arm.Token, fmt.Sprintf("unreachable match arm %s", arm.Pattern))
+				break
+			}
+		}
+	}
+}
+
+// a能否匹配b所能匹配的所有值
+func patternCovers(a, b ast.Pattern) bool {
+	switch a := a.(type) {
+	case *ast.WildcardPattern, *ast.BindingPattern:
+		return true
+	case *ast.LiteralPattern:
+		b, ok := b.(*ast.LiteralPattern)
+		return ok && a.Value.String() == b.Value.String() && sameLiteralType(a.Value, b.Value)
+	case *ast.ArrayPattern:
+		b, ok := b.(*ast.ArrayPattern)
+		if !ok || len(b.Elements) < len(a.Elements) {
+			return false
+		}
+		if !a.HasRest && (b.HasRest || len(b.Elements) != len(a.Elements)) {
+			return false
+		}
+		for i := range a.Elements {
+			if !patternCovers(a.Elements[i], b.Elements[i]) {
+				return false
+			}
+		}
+		return true
+	case *ast.MapPattern:
+		b, ok := b.(*ast.MapPattern)
+		if !ok {
+			return false
+		}
+		for i, key := range a.Keys {
+			j := mapPatternKey(b, key)
+			if j < 0 || !patternCovers(a.Values[i], b.Values[j]) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+func mapPatternKey(m *ast.MapPattern, key ast.Expression) int {
+	for i, k := range m.Keys {
+		if k.String() == key.String() && sameLiteralType(k, key) {
+			return i
+		}
+	}
+	return -1
+}
+
+// 字符串"1"和整数1的String()相同,还要比较类型
+func sameLiteralType(a, b ast.Expression) bool {
+	return fmt.Sprintf("%T", a) == fmt.Sprintf("%T", b)
+}
@@ -23,6 +23,8 @@ var precedences = map[tkt]int{
 	token.ASTERISK: PRODUCT,
 	token.SLASH:    PRODUCT,
 	token.LPAREN:   CALL,
+	token.DOT:      CALL,
+	token.LBRACE:   CALL,
 	token.LBRACKET: INDEX,
 }
 
@@ -40,9 +42,20 @@ const (
 
 type tkt = token.TokenType
 
+// 中缀运算符的优先级,不是中缀运算符时为LOWEST
+func Precedence(t token.TokenType) int {
+	if p, ok := precedences[t]; ok {
+		return p
+	}
+	return LOWEST
+}
+
 type Parser struct {
-	l      *lexer.Lexer
-	errors []string
+	l           *lexer.Lexer
+	errors      []string
+	errorTokens []token.Token //与errors一一对应,出错时所在的token
+	warnings    []string      //不影响求值的问题,如不可达的match分支
+	warnTokens  []token.Token //与warnings一一对应
 
 	curToken  token.Token
 	peekToken token.Token
@@ -104,9 +117,19 @@ func NewParser(l *lexer.Lexer) *Parser {
 
 		//注册索引表达式
 		p.registerInfix(token.LBRACKET, p.parseIndexExpression)
-	}
 
-	//p.registerInfix(token.LPAREN, p.parseInfixExpression)
+		//注册成员访问表达式
+		p.registerInfix(token.DOT, p.parseSelectorExpression)
+
+		//注册match表达式
+		p.registerPrefix(token.MATCH, p.parseMatchExpression)
+
+		//注册try表达式
+		p.registerPrefix(token.TRY, p.parseTryExpression)
+
+		//注册结构体字面量
+		p.registerInfix(token.LBRACE, p.parseStructLiteral)
+	}
 
 	p.nextToken()
 	p.nextToken()
@@ -117,9 +140,38 @@ func (p *Parser) Errors() []string {
 	return p.errors
 }
 
+func (p *Parser) Warnings() []string {
+	return p.warnings
+}
+
+// 与Errors()一一对应的出错位置,供编辑器标出错误
+func (p *Parser) ErrorPositions() []token.Token {
+	return p.errorTokens
+}
+
+// 与Warnings()一一对应的位置
+func (p *Parser) WarningPositions() []token.Token {
+	return p.warnTokens
+}
+
+func (p *Parser) addError(tok token.Token, msg string) {
+	p.errors = append(p.errors, msg)
+	p.errorTokens = append(p.errorTokens, tok)
+}
+
+func (p *Parser) addWarning(tok token.Token, msg string) {
+	p.warnings = append(p.warnings, msg)
+	p.warnTokens = append(p.warnTokens, tok)
+}
+
 func (p *Parser) peekError(t token.TokenType) {
 	msg := fmt.Sprintf("expected next token to be %s, got %s instead", t, p.peekToken.Literal)
-	p.errors = append(p.errors, msg)
+	p.addError(p.peekToken, msg)
+}
+
+func (p *Parser) noPrefixParseFnError(t token.TokenType) {
+	msg := fmt.Sprintf("no prefix parse function for %s found", t)
+	p.addError(p.curToken, msg)
 }
 
 func (p *Parser) curTokenIs(t tkt) bool {
@@ -162,45 +214,166 @@ func (p *Parser) ParseProgram() *ast.Program {
 		}
 		p.nextToken()
 	}
+	p.checkConstRebinding(program.Statements)
 	return program
 }
 
+// 同一作用域中重新绑定const声明的名字
+func (p *Parser) checkConstRebinding(statements []ast.Statement) {
+	consts := map[string]bool{}
+	for _, stmt := range statements {
+		var name *ast.Identifier
+		//解析出错的语句可能是nil指针
+		switch stmt := stmt.(type) {
+		case *ast.LetStatement:
+			if stmt != nil {
+				name = stmt.Name
+			}
+		case *ast.FunctionStatement:
+			if stmt != nil {
+				name = stmt.Name
+			}
+		case *ast.StructStatement:
+			if stmt != nil {
+				name = stmt.Name
+			}
+		}
+		if name == nil {
+			continue
+		}
+		if consts[name.Token.Literal] {
+			p.addError(name.Token, fmt.Sprintf("cannot reassign constant %s", name))
+			continue
+		}
+		if let, ok := stmt.(*ast.LetStatement); ok && let.Const {
+			consts[name.Token.Literal] = true
+		}
+	}
+}
+
 func (p *Parser) parseStatement() ast.Statement {
 	typ := p.curToken.Type
 	switch typ {
 	case token.SEMICOLON:
 		return nil
-	case token.LET:
+	case token.LET, token.CONST:
 		return p.parseLetStatement()
 	case token.RETURN:
 		return p.parseReturnStatement()
+	case token.IMPORT:
+		return p.parseImportStatement()
+	case token.STRUCT:
+		return p.parseStructStatement()
+	case token.THROW:
+		return p.parseThrowStatement()
+	case token.FUNCTION:
+		if p.peekTokenIs(token.IDENT) {
+			return p.parseFunctionStatement()
+		}
+		return p.parseExpressionStatement()
 	default:
 		return p.parseExpressionStatement()
 	}
 }
 
 func (p *Parser) parseLetStatement() *ast.LetStatement {
-	stmt := &ast.LetStatement{}
+	stmt := &ast.LetStatement{Token: p.curToken, Const: p.curTokenIs(token.CONST)}
 	if !p.expectPeek(token.IDENT) {
 		return nil
 	}
 	stmt.Name = &ast.Identifier{Token: p.curToken}
+	stmt.Type = p.parseOptionalType()
 	if !p.expectPeek(token.ASSIGN) {
 		return nil
 	}
 	p.nextToken()
 	stmt.Value = p.parseExpression(LOWEST)
-	if !p.curTokenIs(token.SEMICOLON) {
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+	return stmt
+}
+
+func (p *Parser) parseImportStatement() *ast.ImportStatement {
+	stmt := &ast.ImportStatement{Token: p.curToken}
+	if !p.expectPeek(token.STRING) {
+		return nil
+	}
+	stmt.Path = &ast.StrLiteral{Token: p.curToken}
+	if p.peekTokenIs(token.AS) {
+		p.nextToken()
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		stmt.Alias = &ast.Identifier{Token: p.curToken}
+	}
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+	return stmt
+}
+
+func (p *Parser) parseFunctionStatement() *ast.FunctionStatement {
+	fn := &ast.FunctionLiteral{Token: p.curToken}
+	p.nextToken()
+	stmt := &ast.FunctionStatement{Name: &ast.Identifier{Token: p.curToken}, Function: fn}
+	if !p.parseFunction(fn) {
+		return nil
+	}
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+	return stmt
+}
+
+func (p *Parser) parseThrowStatement() *ast.ThrowStatement {
+	stmt := &ast.ThrowStatement{Token: p.curToken}
+	p.nextToken()
+	stmt.Value = p.parseExpression(LOWEST)
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+	return stmt
+}
+
+func (p *Parser) parseStructStatement() *ast.StructStatement {
+	stmt := &ast.StructStatement{Token: p.curToken}
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	stmt.Name = &ast.Identifier{Token: p.curToken}
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+	seen := map[string]bool{}
+	for !p.peekTokenIs(token.RBRACE) {
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		field := &ast.Identifier{Token: p.curToken}
+		if seen[field.Token.Literal] {
+			msg := fmt.Sprintf("duplicate field %s in struct %s", field.Token.Literal, stmt.Name.Token.Literal)
+			p.addError(p.curToken, msg)
+			return nil
+		}
+		seen[field.Token.Literal] = true
+		stmt.Fields = append(stmt.Fields, field)
+		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+	p.nextToken()
+	if p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
 	}
 	return stmt
 }
 
 func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
-	stmt := &ast.ReturnStatement{}
+	stmt := &ast.ReturnStatement{Token: p.curToken}
 	p.nextToken()
 	stmt.ReturnValue = p.parseExpression(LOWEST)
-	if !p.curTokenIs(token.SEMICOLON) {
+	if p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
 	}
 	return stmt
@@ -209,7 +382,7 @@ func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
 	stmt := ast.ExpressionStatement{}
 	stmt.Expr = p.parseExpression(LOWEST)
-	if p.curTokenIs(token.SEMICOLON) {
+	if p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
 	}
 	return &stmt
@@ -218,6 +391,7 @@ func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
 func (p *Parser) parseExpression(precedence int) ast.Expression {
 	prefix := p.prefixParseFns[p.curToken.Type]
 	if prefix == nil {
+		p.noPrefixParseFnError(p.curToken.Type)
 		return nil
 	}
 	leftExp := prefix()
@@ -233,17 +407,11 @@ func (p *Parser) parseExpression(precedence int) ast.Expression {
 }
 
 func (p *Parser) curPrecedence() int {
-	if p, ok := precedences[p.curToken.Type]; ok {
-		return p
-	}
-	return LOWEST
+	return Precedence(p.curToken.Type)
 }
 
 func (p *Parser) peekPrecedence() int {
-	if p, ok := precedences[p.peekToken.Type]; ok {
-		return p
-	}
-	return LOWEST
+	return Precedence(p.peekToken.Type)
 }
 
 func (p *Parser) parseIdentifier() ast.Expression {
@@ -254,8 +422,8 @@ func (p *Parser) parseIntegerIdentifier() ast.Expression {
 	lit := ast.IntLiteral{Token: p.curToken}
 	i, err := strconv.ParseInt(p.curToken.Literal, 10, 64)
 	if err != nil {
-		msg := fmt.Sprintf("count not parse %q as an integer\n", p.curToken.Literal)
-		p.errors = append(p.errors, msg)
+		msg := fmt.Sprintf("could not parse %q as an integer", p.curToken.Literal)
+		p.addError(p.curToken, msg)
 		return nil
 	}
 	lit.Value = i
@@ -293,27 +461,14 @@ func (p *Parser) parseStrLiteral() ast.Expression {
 }
 
 func (p *Parser) parseArrLiteral() ast.Expression {
-	arr := &ast.ArrLiteral{}
-	p.parseExpressionListUntil(token.RBRACKET)
+	arr := &ast.ArrLiteral{Token: p.curToken}
+	arr.Elements = p.parseExpressionListUntil(token.RBRACKET)
 	return arr
 }
 
 func (p *Parser) parseMapLiteral() ast.Expression {
-	m := ast.MapLiteral{}
-	p.nextToken()
-	key := p.parseExpression(LOWEST)
-	if !p.expectPeek(token.COLON) {
-		return nil
-	}
-	p.nextToken()
-	val := p.parseExpression(LOWEST)
-	m.Pairs[key] = val
-	if p.peekTokenIs(token.RBRACE) {
-		p.nextToken()
-		return &m
-	}
-	for p.peekTokenIs(token.COMMA) {
-		p.nextToken()
+	m := &ast.MapLiteral{Token: p.curToken, Pairs: map[ast.Expression]ast.Expression{}}
+	for !p.peekTokenIs(token.RBRACE) {
 		p.nextToken()
 		key := p.parseExpression(LOWEST)
 		if !p.expectPeek(token.COLON) {
@@ -322,67 +477,105 @@ func (p *Parser) parseMapLiteral() ast.Expression {
 		p.nextToken()
 		val := p.parseExpression(LOWEST)
 		m.Pairs[key] = val
+		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
 	}
 	if !p.expectPeek(token.RBRACE) {
 		return nil
 	}
-	return &m
+	return m
 }
 
 func (p *Parser) parseFuncLiteral() ast.Expression {
-	fn := &ast.FunctionLiteral{}
-	if !p.expectPeek(token.LPAREN) {
+	fn := &ast.FunctionLiteral{Token: p.curToken}
+	if !p.parseFunction(fn) {
 		return nil
 	}
-	fn.Parameters = p.parseFuncParameters()
+	return fn
+}
+
+// 解析参数列表和函数体,调用时peekToken应为(
+func (p *Parser) parseFunction(fn *ast.FunctionLiteral) bool {
+	if !p.expectPeek(token.LPAREN) {
+		return false
+	}
+	p.parseFuncParameters(fn)
 	if !p.expectPeek(token.RPAREN) {
-		return nil
+		return false
+	}
+	fn.ReturnType = p.parseReturnType()
+	if !p.expectPeek(token.LBRACE) {
+		return false
 	}
 	fn.Body = p.parseBlockStatement()
-	return fn
+	return true
 }
 
+// 调用时curToken为{,返回时curToken为}
 func (p *Parser) parseBlockStatement() *ast.BlockStatement {
-	block := &ast.BlockStatement{}
+	block := &ast.BlockStatement{Token: p.curToken}
 	block.Statements = []ast.Statement{}
-	/*if !p.expectPeek(token.LBRACE) {
-		return nil
-	}*/
 	p.nextToken()
-	for !p.curTokenIs(token.RBRACE) || !p.curTokenIs(token.EOF) {
+	for !p.curTokenIs(token.RBRACE) && !p.curTokenIs(token.EOF) {
 		stmt := p.parseStatement()
 		if stmt != nil {
 			block.Statements = append(block.Statements, stmt)
 		}
 		p.nextToken()
 	}
+	if !p.curTokenIs(token.RBRACE) {
+		p.addError(p.curToken, "expected } to close block, got EOF instead")
+	}
+	block.Rbrace = p.curToken
+	p.checkConstRebinding(block.Statements)
 	return block
 }
 
-func (p *Parser) parseFuncParameters() []*ast.Identifier {
-	var identifiers []*ast.Identifier
-
+// 调用时curToken为(,返回时curToken为参数列表的最后一个token
+// 有默认值的参数之后不能再有必需的参数,...rest只能是最后一个参数
+func (p *Parser) parseFuncParameters(fn *ast.FunctionLiteral) {
 	//无参数
 	if p.peekTokenIs(token.RPAREN) {
-		p.nextToken()
-		return nil
+		return
 	}
-	p.nextToken()
-	if !p.curTokenIs(token.IDENT) {
-		return nil
-	}
-	ident := &ast.Identifier{Token: p.curToken}
-	identifiers = append(identifiers, ident)
-	for p.peekTokenIs(token.COMMA) {
+	hasDefault := false
+	for {
 		p.nextToken()
+		if p.curTokenIs(token.ELLIPSIS) {
+			if !p.expectPeek(token.IDENT) {
+				return
+			}
+			fn.Rest = &ast.Identifier{Token: p.curToken}
+			fn.RestType = p.parseOptionalType()
+			if !p.peekTokenIs(token.RPAREN) {
+				p.addError(p.curToken, "rest parameter must be last")
+			}
+			return
+		}
+		if !p.curTokenIs(token.IDENT) {
+			p.addError(p.curToken, fmt.Sprintf("expected parameter name, got %s instead", p.curToken.Literal))
+			return
+		}
+		ident := &ast.Identifier{Token: p.curToken}
+		typ := p.parseOptionalType()
+		var def ast.Expression
+		if p.peekTokenIs(token.ASSIGN) {
+			p.nextToken()
+			p.nextToken()
+			def = p.parseExpression(LOWEST)
+			hasDefault = true
+		} else if hasDefault {
+			p.addError(p.curToken, fmt.Sprintf("parameter %s without default follows parameter with default", ident))
+		}
+		fn.Parameters = append(fn.Parameters, ident)
+		fn.Defaults = append(fn.Defaults, def)
+		fn.ParamTypes = append(fn.ParamTypes, typ)
+		if !p.peekTokenIs(token.COMMA) {
+			return
+		}
 		p.nextToken()
-		ident = &ast.Identifier{Token: p.curToken}
-		identifiers = append(identifiers, ident)
-	}
-	if !p.peekTokenIs(token.RPAREN) {
-		return nil
 	}
-	return identifiers
 }
 
 // 解析表达式
@@ -396,14 +589,14 @@ func (p *Parser) parsePrefixExpression() ast.Expression {
 
 func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
 	expr := ast.InfixExpression{Token: p.curToken, Left: left}
-	p.nextToken()
 	precedence := p.curPrecedence()
+	p.nextToken()
 	expr.Right = p.parseExpression(precedence)
 	return &expr
 }
 
 func (p *Parser) parseIfExpression() ast.Expression {
-	expr := &ast.IfExpression{}
+	expr := &ast.IfExpression{Token: p.curToken}
 	if !p.expectPeek(token.LPAREN) {
 		return nil
 	}
@@ -412,24 +605,54 @@ func (p *Parser) parseIfExpression() ast.Expression {
 	if !p.expectPeek(token.RPAREN) {
 		return nil
 	}
-	/*if !p.expectPeek(token.LBRACE) {
+	if !p.expectPeek(token.LBRACE) {
 		return nil
 	}
-	p.nextToken()*/
 	expr.Consequence = p.parseBlockStatement()
-	if !p.expectPeek(token.RBRACE) {
-		return nil
-	}
 	if p.peekTokenIs(token.ELSE) {
 		p.nextToken()
-		//if !p.expectPeek(token.LBRACE) {
-		//	return nil
-		//}
-		//p.nextToken()
+		if !p.expectPeek(token.LBRACE) {
+			return nil
+		}
 		expr.Alternative = p.parseBlockStatement()
-		//if !p.expectPeek(token.RBRACE) {
-		//	return nil
-		//}
+	}
+	return expr
+}
+
+// try { } catch (e) { } finally { },catch的参数可以省略
+func (p *Parser) parseTryExpression() ast.Expression {
+	expr := &ast.TryExpression{Token: p.curToken}
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+	expr.Body = p.parseBlockStatement()
+	if p.peekTokenIs(token.CATCH) {
+		p.nextToken()
+		if p.peekTokenIs(token.LPAREN) {
+			p.nextToken()
+			if !p.expectPeek(token.IDENT) {
+				return nil
+			}
+			expr.Param = &ast.Identifier{Token: p.curToken}
+			if !p.expectPeek(token.RPAREN) {
+				return nil
+			}
+		}
+		if !p.expectPeek(token.LBRACE) {
+			return nil
+		}
+		expr.Catch = p.parseBlockStatement()
+	}
+	if p.peekTokenIs(token.FINALLY) {
+		p.nextToken()
+		if !p.expectPeek(token.LBRACE) {
+			return nil
+		}
+		expr.Finally = p.parseBlockStatement()
+	}
+	if expr.Catch == nil && expr.Finally == nil {
+		p.addError(p.curToken, "expected catch or finally after try block")
+		return nil
 	}
 	return expr
 }
@@ -437,21 +660,63 @@ func (p *Parser) parseIfExpression() ast.Expression {
 func (p *Parser) parseGroupedExpression() ast.Expression {
 	p.nextToken()
 	exp := p.parseExpression(LOWEST)
-	if !p.expectPeek(token.LPAREN) {
+	if !p.expectPeek(token.RPAREN) {
 		return nil
 	}
 	return exp
 }
 
 func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
-	call := &ast.CallExpression{}
+	call := &ast.CallExpression{Token: p.curToken}
 	call.Function = function
-	call.Arguments = p.parseExpressionListUntil(token.RPAREN)
+	call.Arguments = p.parseCallArguments()
 	return call
 }
 
+// 实参可以是表达式,...arr或者name: value,按名传递的实参必须在最后
+func (p *Parser) parseCallArguments() []ast.Expression {
+	var args []ast.Expression
+	if p.peekTokenIs(token.RPAREN) {
+		p.nextToken()
+		return args
+	}
+	named := false
+	for {
+		p.nextToken()
+		var arg ast.Expression
+		switch {
+		case p.curTokenIs(token.ELLIPSIS):
+			spread := &ast.SpreadExpression{Token: p.curToken}
+			p.nextToken()
+			spread.Value = p.parseExpression(LOWEST)
+			arg = spread
+		case p.curTokenIs(token.IDENT) && p.peekTokenIs(token.COLON):
+			na := &ast.NamedArgument{Name: &ast.Identifier{Token: p.curToken}}
+			p.nextToken()
+			p.nextToken()
+			na.Value = p.parseExpression(LOWEST)
+			arg = na
+			named = true
+		default:
+			arg = p.parseExpression(LOWEST)
+		}
+		if _, ok := arg.(*ast.NamedArgument); !ok && named {
+			p.addError(p.curToken, "positional argument follows named argument")
+		}
+		args = append(args, arg)
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
+		p.nextToken()
+	}
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+	return args
+}
+
 func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
-	var index *ast.IndexExpression
+	index := &ast.IndexExpression{Token: p.curToken}
 	index.Left = left
 	p.nextToken()
 	index.Index = p.parseExpression(LOWEST)
@@ -461,23 +726,31 @@ func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
 	return index
 }
 
-/*func (p *Parser) parseCallArguments() []ast.Expression {
-	var args []ast.Expression
-	if p.peekTokenIs(token.RPAREN) {
-		p.nextToken()
-		return args
-	}
-	p.nextToken()
-	args = append(args, p.parseExpression(LOWEST))
-	for p.peekTokenIs(token.COMMA) {
-		p.nextToken()
-		p.nextToken()
-		args = append(args, p.parseExpression(LOWEST))
+func (p *Parser) parseSelectorExpression(left ast.Expression) ast.Expression {
+	expr := &ast.SelectorExpression{Token: p.curToken, Left: left}
+	if !p.expectPeek(token.IDENT) {
+		return nil
 	}
-	if !p.peekTokenIs(token.RPAREN) {
+	expr.Selector = &ast.Identifier{Token: p.curToken}
+	return expr
+}
+
+func (p *Parser) parseStructLiteral(left ast.Expression) ast.Expression {
+	lit := &ast.StructLiteral{Token: p.curToken, Type: left}
+	for !p.peekTokenIs(token.RBRACE) {
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		lit.Fields = append(lit.Fields, &ast.Identifier{Token: p.curToken})
+		if !p.expectPeek(token.COLON) {
+			return nil
+		}
 		p.nextToken()
-		return nil
+		lit.Values = append(lit.Values, p.parseExpression(LOWEST))
+		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
 	}
-	return args
+	p.nextToken()
+	return lit
 }
-*/
@@ -1,7 +1,10 @@
 package parser
 
 import (
+	"fmt"
 	"my-interpreter/lexer"
+	"my-interpreter/token"
+	"strings"
 	"testing"
 )
 
@@ -142,3 +145,313 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 		}
 	}
 }
+
+func TestImportStatement(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`import "lib/math"`, "import \"lib/math\";\n"},
+		{`import "lib/math" as m;`, "import \"lib/math\" as m;\n"},
+		{`m.add(1, 2)`, "(m.add)(1, 2);\n"},
+		{`a.b.c`, "((a.b).c);\n"},
+		{`-a.b`, "(-(a.b));\n"},
+	}
+	for _, tt := range tests {
+		p := NewParser(lexer.NewLexer(tt.input))
+		program := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			t.Errorf("parser has errors for %q: %v", tt.input, p.Errors())
+			continue
+		}
+		if program.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+	for _, input := range []string{`import lib`, `import "lib" as`, `a.1`} {
+		p := NewParser(lexer.NewLexer(input))
+		p.ParseProgram()
+		if len(p.Errors()) == 0 {
+			t.Errorf("expected parser errors for %q", input)
+		}
+	}
+}
+
+func TestStructParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`struct Point { x, y }`, "struct Point { x, y }\n"},
+		{`struct Empty {}`, "struct Empty {  }\n"},
+		{`Point{x: 1, y: 2 * 3}`, "Point{x: 1, y: (2 * 3)};\n"},
+		{`p{y: 5}.y`, "(p{y: 5}.y);\n"},
+		{`m.Point{x: 1}`, "(m.Point){x: 1};\n"},
+		{`if (a) { b }`, "if a {\n\tb;\n};\n"},
+	}
+	for _, tt := range tests {
+		p := NewParser(lexer.NewLexer(tt.input))
+		program := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			t.Errorf("parser has errors for %q: %v", tt.input, p.Errors())
+			continue
+		}
+		if program.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+	for _, input := range []string{`struct { x }`, `struct P { x, x }`, `struct P { 1 }`, `P{x 1}`} {
+		p := NewParser(lexer.NewLexer(input))
+		p.ParseProgram()
+		if len(p.Errors()) == 0 {
+			t.Errorf("expected parser errors for %q", input)
+		}
+	}
+}
+
+func TestTryExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`throw error(msg, 1);`, "throw error(msg, 1);\n"},
+		{`try { a } catch (e) { b }`, "try {\n\ta;\n} catch (e) {\n\tb;\n};\n"},
+		{`try { a } catch { b }`, "try {\n\ta;\n} catch {\n\tb;\n};\n"},
+		{`try { a } finally { c }`, "try {\n\ta;\n} finally {\n\tc;\n};\n"},
+		{`let x = try { a } catch (e) { b } finally { c };`, "let x = try {\n\ta;\n} catch (e) {\n\tb;\n} finally {\n\tc;\n};\n"},
+	}
+	for _, tt := range tests {
+		p := NewParser(lexer.NewLexer(tt.input))
+		program := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			t.Errorf("parser has errors for %q: %v", tt.input, p.Errors())
+			continue
+		}
+		if program.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+	for _, input := range []string{`try { a }`, `try a catch { b }`, `try { a } catch (1) { b }`, `try { a } catch (e { b }`} {
+		p := NewParser(lexer.NewLexer(input))
+		p.ParseProgram()
+		if len(p.Errors()) == 0 {
+			t.Errorf("expected parser errors for %q", input)
+		}
+	}
+}
+
+func TestMatchExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`match (x) { 1 => a, _ => b }`, "match (x) { 1 => a, _ => b };\n"},
+		{`match (f(x)) { [h, ...t] if h > 1 => h, [] => 0, }`, "match (f(x)) { [h, ...t] if (h > 1) => h, [] => 0 };\n"},
+		{`match (x) { [_, ...] => 1, [...] => 2 }`, "match (x) { [_, ...] => 1, [...] => 2 };\n"},
+		{`match (x) { {"op": "+", "args": [a, b]} => a + b, -1 => y }`, "match (x) { {op: +, args: [a, b]} => (a + b), (-1) => y };\n"},
+		{`let y = match (x) { true => 1, false => 0 };`, "let y = match (x) { true => 1, false => 0 };\n"},
+	}
+	for _, tt := range tests {
+		p := NewParser(lexer.NewLexer(tt.input))
+		program := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			t.Errorf("parser has errors for %q: %v", tt.input, p.Errors())
+			continue
+		}
+		if program.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+	errorTests := []string{
+		`match (x) { }`,
+		`match x { 1 => 2 }`,
+		`match (x) { 1 + 2 => 3 }`,
+		`match (x) { [...t, a] => a }`,
+		`match (x) { {a: 1} => 1 }`,
+		`match (x) { 1 => 2 3 => 4 }`,
+		`match (x) { 1 2 }`,
+	}
+	for _, input := range errorTests {
+		p := NewParser(lexer.NewLexer(input))
+		p.ParseProgram()
+		if len(p.Errors()) == 0 {
+			t.Errorf("expected parser errors for %q", input)
+		}
+	}
+}
+
+func TestUnreachableMatchArms(t *testing.T) {
+	tests := []struct {
+		input    string
+		warnings []string
+	}{
+		{`match (x) { 1 => a, 2 => b, _ => c }`, nil},
+		{`match (x) { _ => a, 1 => b }`, []string{"1:21: unreachable match arm 1"}},
+		{"match (x) {\n  n => a,\n  [] => b,\n  m => c\n}", []string{"3:3: unreachable match arm []", "4:3: unreachable match arm m"}},
+		{`match (x) { n if n > 1 => a, 1 => b }`, nil},
+		{`match (x) { 1 => a, "1" => b, 1 => c }`, []string{"1:31: unreachable match arm 1"}},
+		{`match (x) { [a, ...] => 1, [1, 2] => 2, [] => 3 }`, []string{"1:28: unreachable match arm [1, 2]"}},
+		{`match (x) { [a] => 1, [1, ...] => 2 }`, nil},
+		{`match (x) { {"k": _} => 1, {"k": 1, "j": 2} => 2, {"j": 2} => 3 }`, []string{"1:28: unreachable match arm {k: 1, j: 2}"}},
+	}
+	for _, tt := range tests {
+		p := NewParser(lexer.NewLexer(tt.input))
+		p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			t.Errorf("parser has errors for %q: %v", tt.input, p.Errors())
+			continue
+		}
+		got := positioned(p.Warnings(), p.WarningPositions())
+		if strings.Join(got, "\n") != strings.Join(tt.warnings, "\n") {
+			t.Errorf("%q: expected warnings %q. got=%q", tt.input, tt.warnings, got)
+		}
+	}
+}
+
+func TestFunctionParameters(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`fn(x, y = 10) { x }`, "fn(x, y = 10) {\n\tx;\n};\n"},
+		{`fn(head, ...rest) { rest }`, "fn(head, ...rest) {\n\trest;\n};\n"},
+		{`fn(...all) { all }`, "fn(...all) {\n\tall;\n};\n"},
+		{`fn(a = 1 + 2, ...r) { a }`, "fn(a = (1 + 2), ...r) {\n\ta;\n};\n"},
+		{`f(...arr)`, "f(...arr);\n"},
+		{`f(1, ...a, ...b)`, "f(1, ...a, ...b);\n"},
+		{`f(1, y: 2, z: x + 1)`, "f(1, y: 2, z: (x + 1));\n"},
+		{`f(x)`, "f(x);\n"},
+	}
+	for _, tt := range tests {
+		p := NewParser(lexer.NewLexer(tt.input))
+		program := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			t.Errorf("parser has errors for %q: %v", tt.input, p.Errors())
+			continue
+		}
+		if program.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+	errorTests := []string{
+		`fn(x = 1, y) { x }`,
+		`fn(...rest, x) { x }`,
+		`fn(...) { 1 }`,
+		`fn(1) { 1 }`,
+		`f(y: 1, 2)`,
+		`f(y: 1, ...a)`,
+	}
+	for _, input := range errorTests {
+		p := NewParser(lexer.NewLexer(input))
+		p.ParseProgram()
+		if len(p.Errors()) == 0 {
+			t.Errorf("expected parser errors for %q", input)
+		}
+	}
+}
+
+func TestFunctionStatement(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`fn add(x, y = 1) { x + y }`, "fn add(x, y = 1) {\n\t(x + y);\n}\n"},
+		{`fn f() { 1 }; f()`, "fn f() {\n\t1;\n}\nf();\n"},
+		{`fn(x) { x }(1)`, "fn(x) {\n\tx;\n}(1);\n"},
+	}
+	for _, tt := range tests {
+		p := NewParser(lexer.NewLexer(tt.input))
+		program := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			t.Errorf("parser has errors for %q: %v", tt.input, p.Errors())
+			continue
+		}
+		if program.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+	for _, input := range []string{`fn f { 1 }`, `fn f() 1`, `fn 1() { 1 }`} {
+		p := NewParser(lexer.NewLexer(input))
+		p.ParseProgram()
+		if len(p.Errors()) == 0 {
+			t.Errorf("expected parser errors for %q", input)
+		}
+	}
+}
+
+func TestConstStatement(t *testing.T) {
+	p := NewParser(lexer.NewLexer(`const limit = 10;`))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 || program.String() != "const limit = 10;\n" {
+		t.Errorf("const parsed wrong. errors=%v, got=%q", p.Errors(), program.String())
+	}
+
+	tests := []struct {
+		input  string
+		errors []string
+	}{
+		{"const a = 1;\nlet a = 2;", []string{"2:5: cannot reassign constant a"}},
+		{"const a = 1; const a = 2;", []string{"1:20: cannot reassign constant a"}},
+		{"const f = 1; fn f() { 1 }", []string{"1:17: cannot reassign constant f"}},
+		{"let a = 1; let a = 2; const a = 3;", nil},
+		{"const a = 1; let f = fn() { let a = 2; a };", nil},
+		{"let f = fn() { const a = 1; let a = 2; };", []string{"1:33: cannot reassign constant a"}},
+	}
+	for _, tt := range tests {
+		p := NewParser(lexer.NewLexer(tt.input))
+		p.ParseProgram()
+		got := positioned(p.Errors(), p.ErrorPositions())
+		if strings.Join(got, "\n") != strings.Join(tt.errors, "\n") {
+			t.Errorf("%q: expected errors %q. got=%q", tt.input, tt.errors, got)
+		}
+	}
+}
+
+func TestTypeAnnotations(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let x: int = 5;`, "let x: int = 5;\n"},
+		{`const m: {string: [int]} = {};`, "const m: {string: [int]} = {};\n"},
+		{`fn(a: string, b: [int]) -> bool { true }`, "fn(a: string, b: [int]) -> bool {\n\ttrue;\n};\n"},
+		{`fn(a: int = 1, ...r: [int]) { a }`, "fn(a: int = 1, ...r: [int]) {\n\ta;\n};\n"},
+		{`fn apply(f: fn(int) -> int, x) -> int { f(x) }`, "fn apply(f: fn(int) -> int, x) -> int {\n\tf(x);\n}\n"},
+		{`let g: fn = fn() -> fn() -> null { 1 };`, "let g: fn = fn() -> fn() -> null {\n\t1;\n};\n"},
+		{`f(x: 1)`, "f(x: 1);\n"},
+	}
+	for _, tt := range tests {
+		p := NewParser(lexer.NewLexer(tt.input))
+		program := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			t.Errorf("parser has errors for %q: %v", tt.input, p.Errors())
+			continue
+		}
+		if program.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+	errorTests := []string{
+		`let x: = 5;`,
+		`let x: [int = 5;`,
+		`let x: {string} = {};`,
+		`fn(a: 1) { a }`,
+		`fn() -> { 1 }`,
+	}
+	for _, input := range errorTests {
+		p := NewParser(lexer.NewLexer(input))
+		p.ParseProgram()
+		if len(p.Errors()) == 0 {
+			t.Errorf("expected parser errors for %q", input)
+		}
+	}
+}
+
+// 在消息前加上对应token的"行:列: "
+func positioned(msgs []string, toks []token.Token) []string {
+	var res []string
+	for i, msg := range msgs {
+		res = append(res, fmt.Sprintf("%d:%d: %s", toks[i].Line, toks[i].Column, msg))
+	}
+	return res
+}
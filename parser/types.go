@@ -0,0 +1,84 @@
+package parser
+
+import (
+	"fmt"
+	"my-interpreter/ast"
+	"my-interpreter/token"
+)
+
+// peekToken为:时解析其后的类型注解,否则返回nil
+func (p *Parser) parseOptionalType() ast.TypeExpr {
+	if !p.peekTokenIs(token.COLON) {
+		return nil
+	}
+	p.nextToken()
+	p.nextToken()
+	return p.parseType()
+}
+
+// 调用时curToken为类型的第一个token,返回时为类型的最后一个token
+// 类型有int,[int],{string: int},fn(int) -> bool等形式
+func (p *Parser) parseType() ast.TypeExpr {
+	switch p.curToken.Type {
+	case token.IDENT:
+		return &ast.NamedType{Token: p.curToken}
+	case token.LBRACKET:
+		t := &ast.ArrayType{Token: p.curToken}
+		p.nextToken()
+		if t.Elem = p.parseType(); t.Elem == nil || !p.expectPeek(token.RBRACKET) {
+			return nil
+		}
+		return t
+	case token.LBRACE:
+		t := &ast.MapType{Token: p.curToken}
+		p.nextToken()
+		if t.Key = p.parseType(); t.Key == nil || !p.expectPeek(token.COLON) {
+			return nil
+		}
+		p.nextToken()
+		if t.Value = p.parseType(); t.Value == nil || !p.expectPeek(token.RBRACE) {
+			return nil
+		}
+		return t
+	case token.FUNCTION:
+		if !p.peekTokenIs(token.LPAREN) {
+			//单独的fn表示任意函数
+			return &ast.NamedType{Token: p.curToken}
+		}
+		t := &ast.FunctionType{Token: p.curToken}
+		p.nextToken()
+		for !p.peekTokenIs(token.RPAREN) {
+			p.nextToken()
+			param := p.parseType()
+			if param == nil {
+				return nil
+			}
+			t.Params = append(t.Params, param)
+			if !p.peekTokenIs(token.COMMA) {
+				break
+			}
+			p.nextToken()
+		}
+		if !p.expectPeek(token.RPAREN) {
+			return nil
+		}
+		if p.peekTokenIs(token.RARROW) {
+			if t.Return = p.parseReturnType(); t.Return == nil {
+				return nil
+			}
+		}
+		return t
+	}
+	p.addError(p.curToken, fmt.Sprintf("expected type, got %s instead", p.curToken.Literal))
+	return nil
+}
+
+// peekToken为->时解析返回类型,否则返回nil
+func (p *Parser) parseReturnType() ast.TypeExpr {
+	if !p.peekTokenIs(token.RARROW) {
+		return nil
+	}
+	p.nextToken()
+	p.nextToken()
+	return p.parseType()
+}
@@ -0,0 +1,206 @@
+package profiler
+
+import (
+	"compress/gzip"
+	"io"
+	"time"
+)
+
+// protobuf编码,只用到varint和length-delimited两种类型
+type encoder struct {
+	buf []byte
+}
+
+const (
+	wireVarint = 0
+	wireBytes  = 2
+)
+
+func (e *encoder) varint(x uint64) {
+	for x >= 0x80 {
+		e.buf = append(e.buf, byte(x)|0x80)
+		x >>= 7
+	}
+	e.buf = append(e.buf, byte(x))
+}
+
+func (e *encoder) tag(field, wire int) {
+	e.varint(uint64(field)<<3 | uint64(wire))
+}
+
+// 整数字段,零值省略
+func (e *encoder) int(field int, x int64) {
+	if x == 0 {
+		return
+	}
+	e.tag(field, wireVarint)
+	e.varint(uint64(x))
+}
+
+// 字符串字段,作为repeated的元素时空字符串也要写
+func (e *encoder) string(field int, s string) {
+	e.tag(field, wireBytes)
+	e.varint(uint64(len(s)))
+	e.buf = append(e.buf, s...)
+}
+
+// packed编码的repeated整数
+func (e *encoder) packed(field int, xs []uint64) {
+	var inner encoder
+	for _, x := range xs {
+		inner.varint(x)
+	}
+	e.tag(field, wireBytes)
+	e.varint(uint64(len(inner.buf)))
+	e.buf = append(e.buf, inner.buf...)
+}
+
+func (e *encoder) message(field int, fn func(e *encoder)) {
+	var inner encoder
+	fn(&inner)
+	e.tag(field, wireBytes)
+	e.varint(uint64(len(inner.buf)))
+	e.buf = append(e.buf, inner.buf...)
+}
+
+// profile.proto中Profile的字段
+const (
+	profileSampleType    = 1
+	profileSample        = 2
+	profileLocation      = 4
+	profileFunction      = 5
+	profileStringTable   = 6
+	profileTimeNanos     = 9
+	profileDurationNanos = 10
+	profilePeriodType    = 11
+	profilePeriod        = 12
+)
+
+// 构造一个Profile,字符串,函数和位置都去重并编号
+type builder struct {
+	profile   encoder
+	strings   []string
+	stringIDs map[string]int64
+	functions map[frame]uint64 //只用到name,file和start
+	locations map[frame]uint64
+}
+
+func newBuilder() *builder {
+	b := &builder{stringIDs: map[string]int64{}, functions: map[frame]uint64{}, locations: map[frame]uint64{}}
+	b.str("") //字符串表的第一项必须是空字符串
+	return b
+}
+
+func (b *builder) str(s string) int64 {
+	if id, ok := b.stringIDs[s]; ok {
+		return id
+	}
+	id := int64(len(b.strings))
+	b.strings = append(b.strings, s)
+	b.stringIDs[s] = id
+	return id
+}
+
+func (b *builder) valueType(field int, typ, unit string) {
+	b.profile.message(field, func(e *encoder) {
+		e.int(1, b.str(typ))
+		e.int(2, b.str(unit))
+	})
+}
+
+func (b *builder) function(f frame) uint64 {
+	key := frame{name: f.name, file: f.file, start: f.start}
+	if id, ok := b.functions[key]; ok {
+		return id
+	}
+	id := uint64(len(b.functions) + 1)
+	b.functions[key] = id
+	b.profile.message(profileFunction, func(e *encoder) {
+		e.int(1, int64(id))
+		e.int(2, b.str(f.name))
+		e.int(3, b.str(f.name))
+		e.int(4, b.str(f.file))
+		e.int(5, int64(f.start))
+	})
+	return id
+}
+
+func (b *builder) location(f frame) uint64 {
+	if id, ok := b.locations[f]; ok {
+		return id
+	}
+	fn := b.function(f)
+	id := uint64(len(b.locations) + 1)
+	b.locations[f] = id
+	b.profile.message(profileLocation, func(e *encoder) {
+		e.int(1, int64(id))
+		e.message(4, func(e *encoder) {
+			e.int(1, int64(fn))
+			e.int(2, int64(f.line))
+		})
+	})
+	return id
+}
+
+// 按第一次记录的顺序写出样本,labelKey不为空时把样本的label作为该键的标签
+func (b *builder) samples(samples samples, labelKey string, values func(s *sample) []int64) {
+	for _, s := range samples.list {
+		var ids []uint64
+		for _, f := range s.stack {
+			ids = append(ids, b.location(f))
+		}
+		var vals []uint64
+		for _, v := range values(s) {
+			vals = append(vals, uint64(v))
+		}
+		b.profile.message(profileSample, func(e *encoder) {
+			e.packed(1, ids)
+			e.packed(2, vals)
+			if labelKey != "" {
+				e.message(3, func(e *encoder) {
+					e.int(1, b.str(labelKey))
+					e.int(2, b.str(s.label))
+				})
+			}
+		})
+	}
+}
+
+// 写出gzip压缩的Profile
+func (b *builder) write(w io.Writer, start time.Time, duration time.Duration) error {
+	b.profile.int(profileTimeNanos, start.UnixNano())
+	b.profile.int(profileDurationNanos, int64(duration))
+	for _, s := range b.strings {
+		b.profile.string(profileStringTable, s)
+	}
+	zw := gzip.NewWriter(w)
+	if _, err := zw.Write(b.profile.buf); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// 以pprof格式写出CPU采样,每个样本的值为采样次数和估计的CPU时间
+func (p *Profiler) WriteCPU(w io.Writer) error {
+	b := newBuilder()
+	b.valueType(profileSampleType, "samples", "count")
+	b.valueType(profileSampleType, "cpu", "nanoseconds")
+	b.valueType(profilePeriodType, "cpu", "nanoseconds")
+	b.profile.int(profilePeriod, int64(p.interval))
+	b.samples(p.cpu, "", func(s *sample) []int64 {
+		return []int64{s.count, s.count * int64(p.interval)}
+	})
+	return b.write(w, p.started, p.duration)
+}
+
+// 以pprof格式写出对象的分配次数,样本带有对象类型的标签object
+func (p *Profiler) WriteAllocs(w io.Writer) error {
+	b := newBuilder()
+	b.valueType(profileSampleType, "alloc_objects", "count")
+	b.valueType(profilePeriodType, "alloc_objects", "count")
+	b.profile.int(profilePeriod, 1)
+	b.samples(p.allocs, "object", func(s *sample) []int64 {
+		return []int64{s.count}
+	})
+	return b.write(w, p.started, p.duration)
+}
@@ -0,0 +1,221 @@
+// profiler包在求值钩子上采样脚本的调用栈,统计对象的分配,并输出pprof格式的性能分析文件
+// 栈帧是脚本中的函数和行,go tool pprof看到的是脚本代码而不是解释器本身
+package profiler
+
+import (
+	"my-interpreter/ast"
+	evaluator "my-interpreter/evaluator"
+	"my-interpreter/object"
+	"my-interpreter/token"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// 默认的采样间隔
+const DefaultInterval = 10 * time.Millisecond
+
+// 脚本调用栈中的一帧,pprof会省略尖括号中的内容,所以顶层叫main,匿名函数叫anonymous@定义的行
+type frame struct {
+	name  string
+	file  string
+	start int //函数定义所在的行
+	line  int //正在求值的行
+}
+
+// 调用栈中的一帧及其外层的调用栈
+type active struct {
+	frame
+	outer int //外层各帧组成的调用栈的编号,-1表示还没有编号
+}
+
+// 调用栈按前缀编号,编号为0的是空栈,每个调用栈由外层调用栈的编号和最内层的帧确定
+type stackKey struct {
+	outer int
+	frame frame
+}
+
+// 同一调用栈(和对象类型)的样本
+type sample struct {
+	stack []frame //最内层在前
+	label string  //分配的对象类型,CPU样本为空
+	count int64
+}
+
+type sampleKey struct {
+	stack int
+	label string
+}
+
+// 按第一次记录的顺序保存的样本
+type samples struct {
+	index map[sampleKey]*sample
+	list  []*sample
+}
+
+type Profiler struct {
+	interval time.Duration
+	ticks    atomic.Int64  //计时器触发但还没有记录的采样次数
+	done     chan struct{} //关闭时通知采样协程退出
+	stopped  chan struct{} //采样协程退出时关闭
+	started  time.Time
+	duration time.Duration
+
+	frames []active //最外层在前
+	stacks map[stackKey]int
+	keys   []stackKey //按编号排列,第0项不用
+	cpu    samples
+	allocs samples
+}
+
+// interval为CPU采样间隔,不大于0时使用DefaultInterval
+// file为被分析的脚本,作为最外层帧<main>的文件
+func New(file string, interval time.Duration) *Profiler {
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	return &Profiler{
+		interval: interval,
+		frames:   []active{{frame: frame{name: "main", file: file, start: 1}}},
+		stacks:   map[stackKey]int{},
+		keys:     []stackKey{{}},
+		cpu:      samples{index: map[sampleKey]*sample{}},
+		allocs:   samples{index: map[sampleKey]*sample{}},
+	}
+}
+
+// 同时统计对象分配的钩子
+type allocProfiler struct {
+	*Profiler
+}
+
+// Alloc实现evaluator.AllocHook,按调用栈,行和对象类型计数
+func (p allocProfiler) Alloc(obj object.Object, site token.Token) {
+	p.record(&p.allocs, string(obj.Type()), site.Line, 1)
+}
+
+// 作为Config.Hook使用的钩子,allocs为true时同时统计对象的分配
+// 只做CPU采样时不统计分配,求值器不必在每次分配时调用钩子
+func (p *Profiler) Hook(allocs bool) evaluator.Hook {
+	if allocs {
+		return allocProfiler{p}
+	}
+	return p
+}
+
+// 开始CPU采样,计时器每隔interval触发一次,求值到下一个语句或调用时记录调用栈
+func (p *Profiler) Start() {
+	p.started = time.Now()
+	done, stopped := make(chan struct{}), make(chan struct{})
+	p.done, p.stopped = done, stopped
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(p.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				p.ticks.Add(1)
+			case <-done:
+				return
+			}
+		}
+	}()
+}
+
+// 停止CPU采样,求值结束后调用,返回时采样协程已经退出
+func (p *Profiler) Stop() {
+	if p.done == nil {
+		return
+	}
+	close(p.done)
+	<-p.stopped
+	p.done, p.stopped = nil, nil
+	p.duration = time.Since(p.started)
+	//最后一次触发之后可能没有再求值语句
+	p.sampleCPU()
+}
+
+// 记录计时器触发以来的采样,记在当前的调用栈上
+func (p *Profiler) sampleCPU() {
+	if n := p.ticks.Swap(0); n > 0 {
+		p.record(&p.cpu, "", -1, n)
+	}
+}
+
+// 帧记入样本时的行,还没有求值函数体中的语句时为函数定义的行
+func (f frame) sampled() frame {
+	if f.line == 0 {
+		f.line = f.start
+	}
+	return f
+}
+
+// 调用栈的编号,第一次出现时分配
+func (p *Profiler) stackID(outer int, f frame) int {
+	key := stackKey{outer: outer, frame: f}
+	id, ok := p.stacks[key]
+	if !ok {
+		id = len(p.keys)
+		p.stacks[key] = id
+		p.keys = append(p.keys, key)
+	}
+	return id
+}
+
+// 第i帧外层的调用栈的编号,外层的帧在第i帧返回之前不会改变,所以编号只计算一次
+func (p *Profiler) outer(i int) int {
+	if i == 0 {
+		return 0
+	}
+	if p.frames[i].outer < 0 {
+		p.frames[i].outer = p.stackID(p.outer(i-1), p.frames[i-1].frame.sampled())
+	}
+	return p.frames[i].outer
+}
+
+// 在当前调用栈上记录count个样本,line大于0时替换最内层帧的行
+func (p *Profiler) record(ss *samples, label string, line int, count int64) {
+	top := len(p.frames) - 1
+	f := p.frames[top].frame
+	if line > 0 {
+		f.line = line
+	}
+	key := sampleKey{stack: p.stackID(p.outer(top), f.sampled()), label: label}
+	s, ok := ss.index[key]
+	if !ok {
+		s = &sample{label: label}
+		for id := key.stack; id != 0; id = p.keys[id].outer {
+			s.stack = append(s.stack, p.keys[id].frame)
+		}
+		ss.index[key] = s
+		ss.list = append(ss.list, s)
+	}
+	s.count += count
+}
+
+// Statement实现evaluator.Hook,记录当前求值的行
+func (p *Profiler) Statement(file string, stmt ast.Statement, env *object.Environment) *object.Error {
+	p.sampleCPU()
+	top := &p.frames[len(p.frames)-1]
+	top.file, top.line = file, ast.Pos(stmt).Line
+	return nil
+}
+
+// Call实现evaluator.Hook,压入函数的帧
+func (p *Profiler) Call(fn *object.Function, args []object.Object, env *object.Environment) {
+	p.sampleCPU()
+	name := fn.Name
+	if name == "" {
+		name = "anonymous@" + strconv.Itoa(fn.Token.Line)
+	}
+	p.frames = append(p.frames, active{frame: frame{name: name, file: fn.File, start: fn.Token.Line}, outer: -1})
+}
+
+// Return实现evaluator.Hook,弹出函数的帧
+func (p *Profiler) Return(fn *object.Function, result object.Object) {
+	p.sampleCPU()
+	if len(p.frames) > 1 {
+		p.frames = p.frames[:len(p.frames)-1]
+	}
+}
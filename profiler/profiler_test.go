@@ -0,0 +1,290 @@
+package profiler
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"my-interpreter/ast"
+	evaluator "my-interpreter/evaluator"
+	"my-interpreter/lexer"
+	"my-interpreter/object"
+	"my-interpreter/parser"
+	"my-interpreter/token"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 解码后的Profile,只保留测试用到的字段
+type decoded struct {
+	sampleTypes []string //type/unit
+	periodType  string
+	period      int64
+	samples     []string //"值 标签 函数:行 函数:行...",按字典序排列
+	functions   map[uint64]string
+}
+
+// 读取protobuf的字段,返回字段号,varint的值或者length-delimited的内容
+func readField(buf []byte) (field int, x uint64, data []byte, rest []byte) {
+	tag, n := readVarint(buf)
+	buf = buf[n:]
+	field = int(tag >> 3)
+	switch tag & 7 {
+	case wireVarint:
+		x, n = readVarint(buf)
+		return field, x, nil, buf[n:]
+	case wireBytes:
+		length, n := readVarint(buf)
+		buf = buf[n:]
+		return field, 0, buf[:length], buf[length:]
+	}
+	panic(fmt.Sprintf("unexpected wire type %d", tag&7))
+}
+
+func readVarint(buf []byte) (uint64, int) {
+	var x uint64
+	for i, b := range buf {
+		x |= uint64(b&0x7f) << (7 * i)
+		if b < 0x80 {
+			return x, i + 1
+		}
+	}
+	panic("truncated varint")
+}
+
+// 读取message中的所有整数字段,packed的字段展开
+func readInts(buf []byte) map[int][]uint64 {
+	res := map[int][]uint64{}
+	for len(buf) > 0 {
+		field, x, data, rest := readField(buf)
+		buf = rest
+		if data == nil {
+			res[field] = append(res[field], x)
+		} else {
+			res[field] = append(res[field], readPacked(data)...)
+		}
+	}
+	return res
+}
+
+func readPacked(data []byte) []uint64 {
+	var res []uint64
+	for len(data) > 0 {
+		v, n := readVarint(data)
+		res = append(res, v)
+		data = data[n:]
+	}
+	return res
+}
+
+func decode(t *testing.T, data []byte) decoded {
+	t.Helper()
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var strs []string
+	var sampleTypes, samples, locations, functions [][]byte
+	var periodType []byte
+	d := decoded{functions: map[uint64]string{}}
+	for len(buf) > 0 {
+		field, x, data, rest := readField(buf)
+		buf = rest
+		switch field {
+		case profileSampleType:
+			sampleTypes = append(sampleTypes, data)
+		case profileSample:
+			samples = append(samples, data)
+		case profileLocation:
+			locations = append(locations, data)
+		case profileFunction:
+			functions = append(functions, data)
+		case profileStringTable:
+			strs = append(strs, string(data))
+		case profilePeriodType:
+			periodType = data
+		case profilePeriod:
+			d.period = int64(x)
+		}
+	}
+	if len(strs) == 0 || strs[0] != "" {
+		t.Fatalf("string table = %q, want a leading empty string", strs)
+	}
+	valueType := func(data []byte) string {
+		ints := readInts(data)
+		return strs[ints[1][0]] + "/" + strs[ints[2][0]]
+	}
+	for _, st := range sampleTypes {
+		d.sampleTypes = append(d.sampleTypes, valueType(st))
+	}
+	d.periodType = valueType(periodType)
+	for _, fn := range functions {
+		ints := readInts(fn)
+		d.functions[ints[1][0]] = fmt.Sprintf("%s %s:%d", strs[ints[2][0]], strs[ints[4][0]], ints[5][0])
+	}
+	//location的line是嵌套的message,单独读取
+	lines := map[uint64]string{}
+	for _, loc := range locations {
+		var id uint64
+		var line string
+		for len(loc) > 0 {
+			field, x, data, rest := readField(loc)
+			loc = rest
+			switch field {
+			case 1:
+				id = x
+			case 4:
+				ints := readInts(data)
+				name, _, _ := strings.Cut(d.functions[ints[1][0]], " ")
+				line = fmt.Sprintf("%s:%d", name, ints[2][0])
+			}
+		}
+		lines[id] = line
+	}
+	for _, s := range samples {
+		var parts []string
+		var label string
+		for len(s) > 0 {
+			field, _, data, rest := readField(s)
+			s = rest
+			switch field {
+			case 1:
+				for _, id := range readPacked(data) {
+					parts = append(parts, lines[id])
+				}
+			case 2:
+				var vals []string
+				for _, v := range readPacked(data) {
+					vals = append(vals, fmt.Sprint(v))
+				}
+				parts = append([]string{strings.Join(vals, ",")}, parts...)
+			case 3:
+				ints := readInts(data)
+				label = strs[ints[1][0]] + "=" + strs[ints[2][0]]
+			}
+		}
+		if label != "" {
+			parts = append(parts[:1], append([]string{label}, parts[1:]...)...)
+		}
+		d.samples = append(d.samples, strings.Join(parts, " "))
+	}
+	sort.Strings(d.samples)
+	return d
+}
+
+func run(t *testing.T, hook evaluator.Hook, src string) object.Object {
+	t.Helper()
+	prs := parser.NewParser(lexer.NewLexer(src))
+	program := prs.ParseProgram()
+	if len(prs.Errors()) != 0 {
+		t.Fatal(prs.Errors())
+	}
+	return evaluator.EvalContext(context.Background(), program, object.NewEnvironment(), evaluator.Config{File: "t.mi", Hook: hook})
+}
+
+func TestAllocs(t *testing.T) {
+	p := New("t.mi", 0)
+	res := run(t, p.Hook(true), `let pair = fn(x) {
+	[x, x]
+};
+let a = pair(1);
+let b = pair("s" + "t");
+push(a, first(b))`)
+	if res.Inspect() != "[1, 1, st]" {
+		t.Fatalf("result = %s", res.Inspect())
+	}
+	var buf bytes.Buffer
+	if err := p.WriteAllocs(&buf); err != nil {
+		t.Fatal(err)
+	}
+	d := decode(t, buf.Bytes())
+	if strings.Join(d.sampleTypes, " ") != "alloc_objects/count" || d.periodType != "alloc_objects/count" || d.period != 1 {
+		t.Errorf("sample types = %v, period = %s %d", d.sampleTypes, d.periodType, d.period)
+	}
+	got := strings.Join(d.samples, "\n")
+	want := strings.Join([]string{
+		"1 object=ARRAY main:6",
+		"1 object=ARRAY pair:2 main:4",
+		"1 object=ARRAY pair:2 main:5",
+		"1 object=FUNCTION main:1",
+		"1 object=INTEGER main:4",
+		"3 object=STRING main:5",
+	}, "\n")
+	if got != want {
+		t.Errorf("samples:\n%s\nwant:\n%s", got, want)
+	}
+	if d.functions[1] != "main t.mi:1" {
+		t.Errorf("functions = %v", d.functions)
+	}
+}
+
+func TestCPUSamples(t *testing.T) {
+	p := New("t.mi", time.Millisecond)
+	stmt := func(line int) ast.Statement {
+		return &ast.ExpressionStatement{Expr: &ast.IntLiteral{Token: token.Token{Line: line, Column: 1}}}
+	}
+	fn := &object.Function{Token: token.Token{Line: 3}, File: "t.mi"}
+	p.Statement("t.mi", stmt(7), nil)
+	p.Call(fn, nil, nil)
+	p.ticks.Add(1) //还没有求值函数体中的语句,记在定义的行上
+	p.Statement("t.mi", stmt(4), nil)
+	p.ticks.Add(2)
+	p.Statement("t.mi", stmt(5), nil)
+	p.ticks.Add(1)
+	p.Return(fn, object.Object(nil))
+	p.ticks.Add(4)
+	p.Statement("t.mi", stmt(8), nil)
+
+	var buf bytes.Buffer
+	if err := p.WriteCPU(&buf); err != nil {
+		t.Fatal(err)
+	}
+	d := decode(t, buf.Bytes())
+	if strings.Join(d.sampleTypes, " ") != "samples/count cpu/nanoseconds" || d.periodType != "cpu/nanoseconds" || d.period != int64(time.Millisecond) {
+		t.Errorf("sample types = %v, period = %s %d", d.sampleTypes, d.periodType, d.period)
+	}
+	got := strings.Join(d.samples, "\n")
+	want := strings.Join([]string{
+		"1,1000000 anonymous@3:3 main:7",
+		"1,1000000 anonymous@3:5 main:7",
+		"2,2000000 anonymous@3:4 main:7",
+		"4,4000000 main:7",
+	}, "\n")
+	if got != want {
+		t.Errorf("samples:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestStartStop(t *testing.T) {
+	p := New("t.mi", time.Millisecond)
+	if _, ok := p.Hook(false).(evaluator.AllocHook); ok {
+		t.Error("the CPU profiling hook should not count allocations")
+	}
+	p.Start()
+	run(t, p.Hook(false), "fn fib(n) {\n\tif (n < 2) { return n; }\n\tfib(n - 1) + fib(n - 2)\n}\nfib(18)")
+	p.ticks.Add(1) //最后一次触发之后没有再求值语句
+	p.Stop()
+	p.Stop()
+	if p.duration <= 0 {
+		t.Errorf("duration = %v", p.duration)
+	}
+	if p.ticks.Load() != 0 {
+		t.Errorf("Stop left %d ticks unrecorded", p.ticks.Load())
+	}
+	var buf bytes.Buffer
+	if err := p.WriteCPU(&buf); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range decode(t, buf.Bytes()).samples {
+		if !strings.Contains(s, "main:5") {
+			t.Errorf("sample %q is not under the call on line 5", s)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package repl
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"my-interpreter/ast"
+	evaluator "my-interpreter/evaluator" //不要动
+	"my-interpreter/lexer"
+	"my-interpreter/object"
+	"my-interpreter/parser"
+	"my-interpreter/token"
+	"os"
+	"strings"
+	"time"
+)
+
+// 一次REPL会话的状态
+type session struct {
+	out      io.Writer
+	env      *object.Environment
+	importer *evaluator.Importer
+	echo     bool     //求值前是否回显program.String()
+	inputs   []string //成功求值过的输入,供:save使用
+}
+
+func newSession(out io.Writer) *session {
+	return &session{out: out, env: object.NewEnvironment(), importer: evaluator.NewImporterFromEnv()}
+}
+
+// 以冒号开头的元命令
+type command struct {
+	name  string
+	args  string
+	usage string
+	run   func(s *session, arg string)
+}
+
+var commands []command
+
+func init() {
+	//在init中赋值,避免:help引用commands时的初始化循环
+	commands = []command{
+		{"help", "", "show this help", (*session).help},
+		{"env", "", "list bindings in the session environment", (*session).listEnv},
+		{"type", "expr", "evaluate expr and show its type", (*session).showType},
+		{"ast", "expr", "show the parse tree of expr", (*session).showAST},
+		{"tokens", "expr", "show the tokens of expr", (*session).showTokens},
+		{"load", "file", "evaluate a file in the session", (*session).load},
+		{"save", "file", "save the inputs of the session to a file", (*session).save},
+		{"reset", "", "clear all bindings", (*session).reset},
+		{"time", "expr", "evaluate expr and show the elapsed time", (*session).timeExpr},
+		{"echo", "", "toggle echoing the parsed program before its result", (*session).toggleEcho},
+	}
+}
+
+func (s *session) runCommand(line string) {
+	name, arg, _ := strings.Cut(strings.TrimPrefix(strings.TrimSpace(line), ":"), " ")
+	arg = strings.TrimSpace(arg)
+	for _, cmd := range commands {
+		if cmd.name != name {
+			continue
+		}
+		if cmd.args != "" && arg == "" {
+			fmt.Fprintf(s.out, "usage: :%s %s\n", cmd.name, cmd.args)
+			return
+		}
+		cmd.run(s, arg)
+		return
+	}
+	fmt.Fprintf(s.out, "unknown command: :%s, type :help for a list of commands\n", name)
+}
+
+// 解析并求值一段输入,解析出错时返回nil
+func (s *session) eval(input string) object.Object {
+	return s.evalFile(input, "")
+}
+
+// 求值用户直接输入的一段代码,没有出错时记下它供:save使用
+func (s *session) evalInput(input string) object.Object {
+	program := s.parse(input)
+	if program == nil {
+		return nil
+	}
+	res := s.run(program, "")
+	if _, failed := res.(*object.Error); !failed {
+		s.inputs = append(s.inputs, strings.TrimRight(input, "\n"))
+	}
+	return res
+}
+
+// file不为空时,输入中的import相对于file所在的目录解析
+func (s *session) evalFile(input, file string) object.Object {
+	program := s.parse(input)
+	if program == nil {
+		return nil
+	}
+	return s.run(program, file)
+}
+
+func (s *session) run(program *ast.Program, file string) object.Object {
+	if s.echo {
+		io.WriteString(s.out, program.String())
+		io.WriteString(s.out, "\n")
+	}
+	cfg := evaluator.Config{Importer: s.importer, File: file}
+	return evaluator.EvalContext(context.Background(), program, s.env, cfg)
+}
+
+func (s *session) parse(input string) *ast.Program {
+	l := lexer.NewLexer(input)
+	p := parser.NewParser(l)
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		printParserErrors(s.out, p.Errors())
+		return nil
+	}
+	for i, msg := range p.Warnings() {
+		tok := p.WarningPositions()[i]
+		fmt.Fprintf(s.out, "warning: %d:%d: %s\n", tok.Line, tok.Column, msg)
+	}
+	return program
+}
+
+func (s *session) print(obj object.Object) {
+	if obj != nil {
+		io.WriteString(s.out, obj.Inspect()+"\n")
+	}
+}
+
+func (s *session) help(string) {
+	for _, cmd := range commands {
+		usage := ":" + cmd.name
+		if cmd.args != "" {
+			usage += " " + cmd.args
+		}
+		fmt.Fprintf(s.out, "  %-14s %s\n", usage, cmd.usage)
+	}
+}
+
+func (s *session) listEnv(string) {
+	for _, name := range s.env.Names() {
+		val, _ := s.env.Get(name)
+		fmt.Fprintf(s.out, "%s: %s = %s\n", name, val.Type(), val.Inspect())
+	}
+}
+
+func (s *session) showType(arg string) {
+	if obj := s.eval(arg); obj != nil {
+		fmt.Fprintf(s.out, "%s\n", obj.Type())
+	}
+}
+
+func (s *session) showAST(arg string) {
+	if program := s.parse(arg); program != nil {
+		io.WriteString(s.out, ast.Dump(program))
+	}
+}
+
+func (s *session) showTokens(arg string) {
+	l := lexer.NewLexer(arg)
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		fmt.Fprintf(s.out, "%-10s %q\n", tok.Type, tok.Literal)
+	}
+}
+
+func (s *session) load(path string) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintf(s.out, "%s\n", err)
+		return
+	}
+	s.print(s.evalFile(string(data), path))
+}
+
+func (s *session) save(path string) {
+	data := strings.Join(s.inputs, "\n") + "\n"
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		fmt.Fprintf(s.out, "%s\n", err)
+		return
+	}
+	fmt.Fprintf(s.out, "saved %s to %s\n", plural(len(s.inputs), "input"), path)
+}
+
+// n个word,n不为1时word用复数
+func plural(n int, word string) string {
+	if n != 1 {
+		word += "s"
+	}
+	return fmt.Sprintf("%d %s", n, word)
+}
+
+func (s *session) reset(string) {
+	s.env = object.NewEnvironment()
+	s.importer = evaluator.NewImporterFromEnv()
+	s.inputs = nil
+}
+
+func (s *session) timeExpr(arg string) {
+	begin := time.Now()
+	obj := s.eval(arg)
+	elapsed := time.Since(begin)
+	s.print(obj)
+	fmt.Fprintf(s.out, "time: %s\n", elapsed)
+}
+
+func (s *session) toggleEcho(string) {
+	s.echo = !s.echo
+	if s.echo {
+		io.WriteString(s.out, "echo on\n")
+	} else {
+		io.WriteString(s.out, "echo off\n")
+	}
+}
@@ -0,0 +1,120 @@
+package repl
+
+import (
+	evaluator "my-interpreter/evaluator" //不要动
+	"my-interpreter/object"
+	"my-interpreter/token"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// 根据光标前的内容给出补全候选,word是光标前将被候选替换的部分
+type completeFunc func(line string, pos int) (word string, candidates []string)
+
+// 光标位于m["之后时补全映射的字符串键
+var mapKeyPattern = regexp.MustCompile(`([A-Za-z_]+)\["([^"]*)$`)
+
+// 光标位于m.之后时补全映射中可以用点访问的键,或者模块导出的名字
+var selectorPattern = regexp.MustCompile(`([A-Za-z_]+)\.([A-Za-z_]*)$`)
+
+var identPattern = regexp.MustCompile(`^[A-Za-z_]+$`)
+
+func (s *session) complete(line string, pos int) (string, []string) {
+	before := line[:pos]
+	if match := mapKeyPattern.FindStringSubmatch(before); match != nil {
+		return match[2], s.completeMapKey(match[1], match[2])
+	}
+	if match := selectorPattern.FindStringSubmatch(before); match != nil {
+		return match[2], s.completeSelector(match[1], match[2])
+	}
+	start := len(before)
+	for start > 0 && token.IsLetter(before[start-1]) {
+		start--
+	}
+	word := before[start:]
+	//行首的冒号命令
+	if strings.TrimSpace(before[:start]) == ":" {
+		var names []string
+		for _, cmd := range commands {
+			names = append(names, cmd.name)
+		}
+		return word, filterPrefix(names, word)
+	}
+	if word == "" {
+		return word, nil
+	}
+	names := append(token.Keywords(), evaluator.BuiltinNames()...)
+	for env := s.env; env != nil; env = env.Outer() {
+		names = append(names, env.Names()...)
+	}
+	return word, filterPrefix(names, word)
+}
+
+func (s *session) completeMapKey(name, prefix string) []string {
+	val, ok := s.env.Get(name)
+	if !ok {
+		return nil
+	}
+	m, ok := val.(*object.Map)
+	if !ok {
+		return nil
+	}
+	var keys []string
+	for _, pair := range m.Mappings {
+		if key, ok := pair.Key.(*object.String); ok {
+			keys = append(keys, key.Value)
+		}
+	}
+	candidates := filterPrefix(keys, prefix)
+	for i := range candidates {
+		candidates[i] += `"]`
+	}
+	return candidates
+}
+
+func (s *session) completeSelector(name, prefix string) []string {
+	val, ok := s.env.Get(name)
+	if !ok {
+		return nil
+	}
+	var names []string
+	switch val := val.(type) {
+	case *object.Map:
+		for _, pair := range val.Mappings {
+			if key, ok := pair.Key.(*object.String); ok && identPattern.MatchString(key.Value) {
+				names = append(names, key.Value)
+			}
+		}
+	case *object.Module:
+		names = val.Exports()
+	}
+	return filterPrefix(names, prefix)
+}
+
+// 去重,排序,只保留以prefix开头的名字
+func filterPrefix(names []string, prefix string) []string {
+	seen := map[string]bool{}
+	var res []string
+	for _, name := range names {
+		if strings.HasPrefix(name, prefix) && !seen[name] {
+			seen[name] = true
+			res = append(res, name)
+		}
+	}
+	sort.Strings(res)
+	return res
+}
+
+func commonPrefix(words []string) string {
+	if len(words) == 0 {
+		return ""
+	}
+	prefix := words[0]
+	for _, w := range words[1:] {
+		for !strings.HasPrefix(w, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	return prefix
+}
@@ -0,0 +1,144 @@
+package repl
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+const keyCtrlC = 3
+
+// Ctrl-C放弃当前输入时ReadLine返回的错误
+var errInterrupt = errors.New("interrupt")
+
+// 读取用户输入的一行,终端和普通输入流各有一种实现
+type console interface {
+	ReadLine(prompt string) (string, error)
+	SetCompleter(fn completeFunc)
+	Close() error
+}
+
+func newConsole(in io.Reader, out io.Writer) console {
+	if f, ok := in.(*os.File); ok && term.IsTerminal(int(f.Fd())) {
+		return newTermConsole(f, out)
+	}
+	return &plainConsole{scanner: bufio.NewScanner(in), out: out}
+}
+
+// 管道或文件输入,逐行读取,没有行编辑
+type plainConsole struct {
+	scanner *bufio.Scanner
+	out     io.Writer
+}
+
+func (c *plainConsole) ReadLine(prompt string) (string, error) {
+	io.WriteString(c.out, prompt)
+	if !c.scanner.Scan() {
+		if err := c.scanner.Err(); err != nil {
+			return "", err
+		}
+		return "", io.EOF
+	}
+	return c.scanner.Text(), nil
+}
+
+func (c *plainConsole) SetCompleter(completeFunc) {}
+
+func (c *plainConsole) Close() error {
+	return nil
+}
+
+// 交互式终端,支持方向键编辑,历史记录和Ctrl-C
+type termConsole struct {
+	fd       int
+	out      io.Writer
+	reader   *interruptReader
+	term     *term.Terminal
+	history  *fileHistory
+	complete completeFunc
+}
+
+func newTermConsole(f *os.File, out io.Writer) *termConsole {
+	c := &termConsole{fd: int(f.Fd()), out: out, reader: &interruptReader{r: f}}
+	c.history = loadHistory(historyPath())
+	c.reset()
+	return c
+}
+
+// term.Terminal没有清空当前行的方法,Ctrl-C之后换一个新的
+func (c *termConsole) reset() {
+	c.term = term.NewTerminal(struct {
+		io.Reader
+		io.Writer
+	}{c.reader, c.out}, "")
+	c.term.History = c.history
+	c.term.AutoCompleteCallback = c.autoComplete
+}
+
+func (c *termConsole) SetCompleter(fn completeFunc) {
+	c.complete = fn
+}
+
+// 按Tab时补全,唯一候选直接补全,多个候选先补全公共前缀,没有公共前缀可补时列出所有候选
+func (c *termConsole) autoComplete(line string, pos int, key rune) (string, int, bool) {
+	if key != '\t' || c.complete == nil {
+		return "", 0, false
+	}
+	word, candidates := c.complete(line, pos)
+	if len(candidates) == 0 {
+		return line, pos, true
+	}
+	insert := commonPrefix(candidates)[len(word):]
+	if insert == "" && len(candidates) > 1 {
+		c.term.Write([]byte(strings.Join(candidates, "  ") + "\n"))
+		return line, pos, true
+	}
+	return line[:pos] + insert + line[pos:], pos + len(insert), true
+}
+
+// 只在读取时进入raw模式,求值期间prints等输出照常换行,Ctrl-C也照常生效
+func (c *termConsole) ReadLine(prompt string) (string, error) {
+	state, err := term.MakeRaw(c.fd)
+	if err != nil {
+		return "", err
+	}
+	defer term.Restore(c.fd, state)
+	if width, height, err := term.GetSize(c.fd); err == nil && width > 0 {
+		c.term.SetSize(width, height)
+	}
+	c.term.SetPrompt(prompt)
+	line, err := c.term.ReadLine()
+	if err == io.EOF && c.reader.interrupted {
+		c.reader.interrupted = false
+		io.WriteString(c.out, "^C\r\n")
+		c.reset()
+		return "", errInterrupt
+	}
+	if err == term.ErrPasteIndicator {
+		err = nil
+	}
+	return line, err
+}
+
+func (c *termConsole) Close() error {
+	return c.history.Close()
+}
+
+// term.Terminal把Ctrl-C和Ctrl-D都当作io.EOF返回,这里记下读到过Ctrl-C以便区分
+type interruptReader struct {
+	r           io.Reader
+	interrupted bool
+}
+
+func (ir *interruptReader) Read(p []byte) (int, error) {
+	n, err := ir.r.Read(p)
+	if bytes.IndexByte(p[:n], keyCtrlC) >= 0 {
+		ir.interrupted = true
+	}
+	return n, err
+}
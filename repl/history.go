@@ -0,0 +1,81 @@
+package repl
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	HISTORY_FILE = ".my_interpreter_history"
+	HISTORY_SIZE = 1000
+)
+
+// 历史记录文件放在用户主目录下,取不到主目录时只在内存中保存
+func historyPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, HISTORY_FILE)
+}
+
+// 持久化的历史记录,实现了term.History接口
+type fileHistory struct {
+	entries []string //最旧的在前
+	file    *os.File
+}
+
+func loadHistory(path string) *fileHistory {
+	h := &fileHistory{}
+	if path == "" {
+		return h
+	}
+	if f, err := os.Open(path); err == nil {
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			h.entries = append(h.entries, scanner.Text())
+		}
+		f.Close()
+	}
+	//文件过长时只保留最近的HISTORY_SIZE条并重写
+	if len(h.entries) > HISTORY_SIZE {
+		h.entries = h.entries[len(h.entries)-HISTORY_SIZE:]
+		os.WriteFile(path, []byte(strings.Join(h.entries, "\n")+"\n"), 0600)
+	}
+	h.file, _ = os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	return h
+}
+
+func (h *fileHistory) Add(entry string) {
+	if strings.TrimSpace(entry) == "" {
+		return
+	}
+	if len(h.entries) > 0 && h.entries[len(h.entries)-1] == entry {
+		return
+	}
+	h.entries = append(h.entries, entry)
+	if len(h.entries) > HISTORY_SIZE {
+		h.entries = h.entries[1:]
+	}
+	if h.file != nil {
+		h.file.WriteString(entry + "\n")
+	}
+}
+
+func (h *fileHistory) Len() int {
+	return len(h.entries)
+}
+
+// 下标0是最近添加的一条
+func (h *fileHistory) At(idx int) string {
+	return h.entries[len(h.entries)-1-idx]
+}
+
+func (h *fileHistory) Close() error {
+	if h.file == nil {
+		return nil
+	}
+	return h.file.Close()
+}
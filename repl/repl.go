@@ -1,46 +1,93 @@
 package repl
 
 import (
-	"bufio"
-	"fmt"
 	"io"
-	evaluator "my-interpreter/evaluator" //不要动
 	"my-interpreter/lexer"
-	"my-interpreter/object"
-	"my-interpreter/parser"
+	"my-interpreter/token"
+	"strings"
 )
 
-const PROMPT = "code>> "
+const (
+	PROMPT          = "code>> "
+	CONTINUE_PROMPT = "  ...> "
+)
 
 func Start(in io.Reader, out io.Writer) {
-	scanner := bufio.NewScanner(in)
-	env := object.NewEnvironment()
+	c := newConsole(in, out)
+	defer c.Close()
+	s := newSession(out)
+	c.SetCompleter(s.complete)
 	for {
-		fmt.Fprintf(out, PROMPT)
-		if ok := scanner.Scan(); !ok {
-			return
-		}
-		line := scanner.Text()
-		l := lexer.NewLexer(line)
-		p := parser.NewParser(l)
-		program := p.ParseProgram()
-		if len(p.Errors()) != 0 {
-			printParserErrors(out, p.Errors())
+		input, err := readInput(c)
+		if err == errInterrupt {
 			continue
 		}
-		io.WriteString(out, program.String())
-		io.WriteString(out, "\n")
-		if evaluated := evaluator.Eval(program, env); evaluated != nil {
-			io.WriteString(out, evaluated.Inspect()+"\n")
+		if err != nil {
+			return
+		}
+		input = strings.TrimSpace(input)
+		switch {
+		case input == "":
+		case strings.HasPrefix(input, ":"):
+			s.runCommand(input)
+		default:
+			s.print(s.evalInput(input))
 		}
-		/*
-			tok := l.NextToken()
-			for tok.Type != token.EOF {
-				tok = l.NextToken()
-				fmt.Fprintf(out, "%s\n", tok)
+	}
+}
+
+// 读取一条完整的输入,输入不完整时显示续行提示符继续读取
+func readInput(c console) (string, error) {
+	var buf strings.Builder
+	prompt := PROMPT
+	for {
+		line, err := c.ReadLine(prompt)
+		if err != nil {
+			//输入中途遇到EOF,把已读到的部分交给解析器报错
+			if err == io.EOF && buf.Len() > 0 {
+				return buf.String(), nil
 			}
-		*/
+			return "", err
+		}
+		buf.WriteString(line)
+		buf.WriteString("\n")
+		if !isIncomplete(buf.String()) {
+			return buf.String(), nil
+		}
+		prompt = CONTINUE_PROMPT
+	}
+}
+
+// 括号未闭合,字符串未结束,或者以运算符等结尾,说明输入还没写完
+func isIncomplete(input string) bool {
+	if strings.Count(input, `"`)%2 == 1 {
+		return true
+	}
+	l := lexer.NewLexer(input)
+	depth := 0
+	var last token.TokenType
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		switch tok.Type {
+		case token.LPAREN, token.LBRACKET, token.LBRACE:
+			depth++
+		case token.RPAREN, token.RBRACKET, token.RBRACE:
+			depth--
+		}
+		last = tok.Type
+	}
+	if depth > 0 {
+		return true
+	}
+	switch last {
+	case token.ASSIGN, token.PLUS, token.MINUS, token.BANG, token.ASTERISK, token.SLASH,
+		token.LT, token.GT, token.EQ, token.NEQ, token.COMMA, token.COLON,
+		token.ARROW, token.RARROW, token.DOT, token.ELLIPSIS,
+		token.LET, token.FUNCTION, token.IF, token.ELSE, token.RETURN, token.CONST,
+		token.TRY, token.CATCH, token.FINALLY, token.THROW, token.MATCH,
+		token.IMPORT, token.AS, token.STRUCT:
+		return true
 	}
+	return false
 }
 
 func printParserErrors(out io.Writer, errors []string) {
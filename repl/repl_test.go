@@ -0,0 +1,172 @@
+package repl
+
+import (
+	"my-interpreter/object"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestIsIncomplete(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"let a = 5;", false},
+		{"let add = fn(x, y) {", true},
+		{"let add = fn(x, y) {\n x + y;\n};", false},
+		{"[1, 2,", true},
+		{"add(1,\n 2)", false},
+		{"let a = 5 +", true},
+		{"let a =", true},
+		{`"hello`, true},
+		{`"hello"`, false},
+		{"if (a) { 1 } else", true},
+		{"}", false},
+		{"match (x) { 1 =>", true},
+		{"let f = fn(x: int) ->", true},
+		{"point.", true},
+		{"sum(...", true},
+		{"try", true},
+		{"try { f() } catch", true},
+		{"try { f() } finally", true},
+		{"throw", true},
+		{"match", true},
+		{"const", true},
+		{"import", true},
+		{`import "lib" as`, true},
+		{"struct", true},
+		{"match (x) { 1 => 2 }", false},
+		{`import "lib";`, false},
+	}
+	for _, tt := range tests {
+		if got := isIncomplete(tt.input); got != tt.expected {
+			t.Errorf("isIncomplete(%q) wrong. expected=%t, got=%t", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestStartMultiLine(t *testing.T) {
+	input := `let add = fn(x, y) {
+	x + y;
+};
+add(2,
+	3)
+`
+	var out strings.Builder
+	Start(strings.NewReader(input), &out)
+	if !strings.Contains(out.String(), CONTINUE_PROMPT) {
+		t.Errorf("continuation prompt not shown. got=%q", out.String())
+	}
+	if !strings.HasSuffix(out.String(), "5\n"+PROMPT) {
+		t.Errorf("multi-line call not evaluated. got=%q", out.String())
+	}
+}
+
+func TestFileHistory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), HISTORY_FILE)
+	h := loadHistory(path)
+	h.Add("let a = 1;")
+	h.Add("")
+	h.Add("a + 1")
+	h.Add("a + 1")
+	h.Close()
+
+	h = loadHistory(path)
+	defer h.Close()
+	if h.Len() != 2 {
+		t.Fatalf("history has wrong length. got=%d", h.Len())
+	}
+	if h.At(0) != "a + 1" || h.At(1) != "let a = 1;" {
+		t.Errorf("history has wrong entries. got=%q, %q", h.At(0), h.At(1))
+	}
+	data, _ := os.ReadFile(path)
+	if string(data) != "let a = 1;\na + 1\n" {
+		t.Errorf("history file wrong. got=%q", data)
+	}
+}
+
+func TestCommands(t *testing.T) {
+	dir := t.TempDir()
+	path, path2 := filepath.Join(dir, "session.mi"), filepath.Join(dir, "session2.mi")
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{":type 1 + 2", "INTEGER\n"},
+		{`:type "a"`, "STRING\n"},
+		{":tokens let x", "LET        \"let\"\nIDENT      \"x\"\n"},
+		{":ast -a", "Program\n  Statements:\n    - ExpressionStatement\n      Expr: PrefixExpression \"-\"\n        Right: Identifier \"a\"\n"},
+		{"let a = 5; let b = true;", ""},
+		{"undefinedName", "identifier not found: undefinedName\n"},
+		{":env", "a: INTEGER = 5\nb: BOOLEAN = true\n"},
+		{":save " + path, "saved 1 input to " + path + "\n"},
+		{"let c = 1;", ""},
+		{":save " + path2, "saved 2 inputs to " + path2 + "\n"},
+		{":reset", ""},
+		{":env", ""},
+		{":load " + path, ""},
+		{":env", "a: INTEGER = 5\nb: BOOLEAN = true\n"},
+		{":echo", "echo on\n"},
+		{"a", "a;\n\n5\n"},
+		{":type", "usage: :type expr\n"},
+		{":nope", "unknown command: :nope, type :help for a list of commands\n"},
+	}
+	var out strings.Builder
+	s := newSession(&out)
+	for _, tt := range tests {
+		out.Reset()
+		if strings.HasPrefix(tt.input, ":") {
+			s.runCommand(tt.input)
+		} else {
+			s.print(s.evalInput(tt.input))
+		}
+		if out.String() != tt.expected {
+			t.Errorf("%s wrong. expected=%q, got=%q", tt.input, tt.expected, out.String())
+		}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	//:type的参数和出错的输入不保存
+	if string(data) != "let a = 5; let b = true;\n" {
+		t.Errorf("saved file wrong. got=%q", data)
+	}
+}
+
+func TestComplete(t *testing.T) {
+	s := newSession(&strings.Builder{})
+	s.eval(`let length = 3; let config = {"name": "x", "nested": 1, 2: 3};`)
+	s.env = object.NewEnclosedEnvironment(s.env)
+	s.env.Set("lengthy", &object.Integer{Value: 1})
+	tests := []struct {
+		line       string
+		word       string
+		candidates []string
+	}{
+		{"le", "le", []string{"len", "length", "lengthy", "let"}},
+		{"1 + fi", "fi", []string{"finally", "first"}},
+		{"re", "re", []string{"return"}},
+		{"conf", "conf", []string{"config"}},
+		{`config["n`, "n", []string{`name"]`, `nested"]`}},
+		{`config["na`, "na", []string{`name"]`}},
+		{`length["`, "", nil},
+		{`config.n`, "n", []string{"name", "nested"}},
+		{`1 + config.`, "", []string{"name", "nested"}},
+		{`length.`, "", nil},
+		{":ty", "ty", []string{"type"}},
+		{"1 + ", "", nil},
+	}
+	for _, tt := range tests {
+		word, candidates := s.complete(tt.line, len(tt.line))
+		if word != tt.word || strings.Join(candidates, ",") != strings.Join(tt.candidates, ",") {
+			t.Errorf("complete(%q) wrong. expected=%q %q, got=%q %q",
+				tt.line, tt.word, tt.candidates, word, candidates)
+		}
+	}
+	if prefix := commonPrefix([]string{"length", "lengthy", "len"}); prefix != "len" {
+		t.Errorf("commonPrefix wrong. got=%q", prefix)
+	}
+}
@@ -0,0 +1,359 @@
+// resolver包在求值之前静态检查名字的作用域
+// 报告未定义的名字,未使用的局部变量,遮蔽和重复的参数,并在ast.Identifier上记下其声明所在的作用域
+package resolver
+
+import (
+	"fmt"
+	"my-interpreter/ast"
+	"my-interpreter/token"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+type Severity string
+
+const (
+	ERROR   Severity = "error"
+	WARNING Severity = "warning"
+)
+
+// 诊断的种类
+const (
+	UNDEFINED       = "undefined"
+	UNUSED          = "unused"
+	SHADOW          = "shadow"
+	DUPLICATE_PARAM = "duplicate-param"
+)
+
+type Diagnostic struct {
+	Line     int
+	Column   int
+	Severity Severity
+	Code     string
+	Msg      string
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%d:%d: %s: %s", d.Line, d.Column, d.Severity, d.Msg)
+}
+
+// 声明的种类,参数不报告未使用
+const (
+	kindVariable  = "variable"
+	kindFunction  = "function"
+	kindStruct    = "struct"
+	kindImport    = "import"
+	kindParameter = "parameter"
+	kindBinding   = "binding"
+)
+
+type symbol struct {
+	name string
+	decl *ast.Identifier
+	kind string
+	used bool
+}
+
+// 作用域与求值时的object.Environment一一对应:程序或模块,函数调用,catch和match分支各有一个
+// 语句块不产生作用域,其中的let绑定到所在的函数
+type scope struct {
+	outer    *scope
+	symbols  map[string]*symbol
+	order    []*symbol
+	global   bool
+	function bool      //函数或程序的作用域,其中的函数字面量在作用域结束时解析
+	pending  []closure //等待解析的函数字面量
+}
+
+type closure struct {
+	fn    *ast.FunctionLiteral
+	scope *scope
+}
+
+type resolver struct {
+	globals     map[string]bool //求值环境中已有的名字,如内置函数
+	scopes      []*scope
+	diagnostics []Diagnostic
+	definitions map[*ast.Identifier]*ast.Identifier
+}
+
+// 解析没有语法错误的program,globals是求值环境中已有的名字,按位置返回诊断
+// 函数体在其外层函数或程序结束时才解析,所以函数可以引用在它之后声明的名字
+func Resolve(program *ast.Program, globals []string) []Diagnostic {
+	return newResolver(program, globals).diagnostics
+}
+
+// 解析program,除诊断外还返回每个名字的引用所对应的声明,声明本身对应它自己,供编辑器跳转到定义和查找引用
+// 同一作用域中重新绑定的名字对应第一次声明,globals中的名字不在结果中
+// 没有别名的import声明的名字是以路径的位置构造的标识符
+func Analyze(program *ast.Program, globals []string) ([]Diagnostic, map[*ast.Identifier]*ast.Identifier) {
+	r := newResolver(program, globals)
+	return r.diagnostics, r.definitions
+}
+
+func newResolver(program *ast.Program, globals []string) *resolver {
+	r := &resolver{globals: map[string]bool{}, definitions: map[*ast.Identifier]*ast.Identifier{}}
+	for _, name := range globals {
+		r.globals[name] = true
+	}
+	global := r.newScope(nil, true)
+	global.global = true
+	//顶层的具名函数在求值时先于其他语句绑定
+	for _, stmt := range program.Statements {
+		if fn, ok := stmt.(*ast.FunctionStatement); ok {
+			r.declare(global, fn.Name, kindFunction)
+		}
+	}
+	r.statements(global, program.Statements)
+	r.finish(global)
+	r.reportUnused()
+	sort.SliceStable(r.diagnostics, func(i, j int) bool {
+		a, b := r.diagnostics[i], r.diagnostics[j]
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+	return r
+}
+
+func (r *resolver) newScope(outer *scope, function bool) *scope {
+	s := &scope{outer: outer, symbols: map[string]*symbol{}, function: function}
+	r.scopes = append(r.scopes, s)
+	return s
+}
+
+func (r *resolver) report(tok token.Token, severity Severity, code, format string, a ...any) {
+	r.diagnostics = append(r.diagnostics, Diagnostic{
+		Line:     tok.Line,
+		Column:   tok.Column,
+		Severity: severity,
+		Code:     code,
+		Msg:      fmt.Sprintf(format, a...),
+	})
+}
+
+// 在s中声明name,同一作用域中重复声明是重新绑定,沿用原来的声明
+func (r *resolver) declare(s *scope, name *ast.Identifier, kind string) {
+	literal := name.Token.Literal
+	if prev, ok := s.symbols[literal]; ok {
+		r.definitions[name] = prev.decl
+		return
+	}
+	if !s.global {
+		for outer := s.outer; outer != nil; outer = outer.outer {
+			if prev, ok := outer.symbols[literal]; ok {
+				r.report(name.Token, WARNING, SHADOW, "%s shadows declaration at %d:%d", literal, prev.decl.Token.Line, prev.decl.Token.Column)
+				break
+			}
+		}
+	}
+	sym := &symbol{name: literal, decl: name, kind: kind}
+	s.symbols[literal] = sym
+	r.definitions[name] = name
+	s.order = append(s.order, sym)
+}
+
+// 解析对名字的引用
+func (r *resolver) use(s *scope, ident *ast.Identifier) {
+	literal := ident.Token.Literal
+	depth := 0
+	for cur := s; cur != nil; cur = cur.outer {
+		if sym, ok := cur.symbols[literal]; ok {
+			sym.used = true
+			r.definitions[ident] = sym.decl
+			ident.Binding = &ast.Binding{Depth: depth}
+			return
+		}
+		depth++
+	}
+	if !r.globals[literal] {
+		r.report(ident.Token, ERROR, UNDEFINED, "undefined: %s", literal)
+	}
+}
+
+// 作用域结束,解析其中等待的函数字面量
+func (r *resolver) finish(s *scope) {
+	for len(s.pending) > 0 {
+		c := s.pending[0]
+		s.pending = s.pending[1:]
+		r.function(c.scope, c.fn)
+	}
+}
+
+// 函数字面量交给最近的函数或程序作用域,在那里结束时解析
+func (r *resolver) deferFunction(s *scope, fn *ast.FunctionLiteral) {
+	owner := s
+	for !owner.function {
+		owner = owner.outer
+	}
+	owner.pending = append(owner.pending, closure{fn: fn, scope: s})
+}
+
+func (r *resolver) function(outer *scope, fn *ast.FunctionLiteral) {
+	s := r.newScope(outer, true)
+	seen := map[string]bool{}
+	params := append([]*ast.Identifier{}, fn.Parameters...)
+	if fn.Rest != nil {
+		params = append(params, fn.Rest)
+	}
+	for _, param := range params {
+		if seen[param.Token.Literal] {
+			r.report(param.Token, ERROR, DUPLICATE_PARAM, "duplicate parameter %s", param)
+			continue
+		}
+		seen[param.Token.Literal] = true
+		r.declare(s, param, kindParameter)
+	}
+	for _, def := range fn.Defaults {
+		if def != nil {
+			r.expression(s, def)
+		}
+	}
+	r.statements(s, fn.Body.Statements)
+	r.finish(s)
+}
+
+func (r *resolver) statements(s *scope, statements []ast.Statement) {
+	for _, stmt := range statements {
+		r.statement(s, stmt)
+	}
+}
+
+func (r *resolver) statement(s *scope, stmt ast.Statement) {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		r.expression(s, stmt.Value)
+		r.declare(s, stmt.Name, kindVariable)
+	case *ast.FunctionStatement:
+		//顶层的已经在Resolve中声明过
+		r.declare(s, stmt.Name, kindFunction)
+		r.deferFunction(s, stmt.Function)
+	case *ast.StructStatement:
+		r.declare(s, stmt.Name, kindStruct)
+	case *ast.ImportStatement:
+		name := stmt.Alias
+		if name == nil {
+			path := stmt.Path.Token.Literal
+			tok := stmt.Path.Token
+			tok.Literal = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+			name = &ast.Identifier{Token: tok}
+		}
+		r.declare(s, name, kindImport)
+	case *ast.ReturnStatement:
+		r.expression(s, stmt.ReturnValue)
+	case *ast.ThrowStatement:
+		r.expression(s, stmt.Value)
+	case *ast.ExpressionStatement:
+		r.expression(s, stmt.Expr)
+	case *ast.BlockStatement:
+		r.statements(s, stmt.Statements)
+	}
+}
+
+func (r *resolver) block(s *scope, block *ast.BlockStatement) {
+	if block != nil {
+		r.statements(s, block.Statements)
+	}
+}
+
+func (r *resolver) expression(s *scope, expr ast.Expression) {
+	switch expr := expr.(type) {
+	case *ast.Identifier:
+		r.use(s, expr)
+	case *ast.ArrLiteral:
+		for _, e := range expr.Elements {
+			r.expression(s, e)
+		}
+	case *ast.MapLiteral:
+		for key, val := range expr.Pairs {
+			r.expression(s, key)
+			r.expression(s, val)
+		}
+	case *ast.PrefixExpression:
+		r.expression(s, expr.Right)
+	case *ast.InfixExpression:
+		r.expression(s, expr.Left)
+		r.expression(s, expr.Right)
+	case *ast.IfExpression:
+		r.expression(s, expr.Condition)
+		r.block(s, expr.Consequence)
+		r.block(s, expr.Alternative)
+	case *ast.TryExpression:
+		r.block(s, expr.Body)
+		if expr.Catch != nil {
+			catch := r.newScope(s, false)
+			if expr.Param != nil {
+				r.declare(catch, expr.Param, kindBinding)
+			}
+			r.block(catch, expr.Catch)
+		}
+		r.block(s, expr.Finally)
+	case *ast.MatchExpression:
+		r.expression(s, expr.Value)
+		for _, arm := range expr.Arms {
+			armScope := r.newScope(s, false)
+			r.pattern(armScope, arm.Pattern)
+			if arm.Guard != nil {
+				r.expression(armScope, arm.Guard)
+			}
+			r.expression(armScope, arm.Body)
+		}
+	case *ast.CallExpression:
+		r.expression(s, expr.Function)
+		for _, arg := range expr.Arguments {
+			r.expression(s, arg)
+		}
+	case *ast.SpreadExpression:
+		r.expression(s, expr.Value)
+	case *ast.NamedArgument:
+		r.expression(s, expr.Value)
+	case *ast.IndexExpression:
+		r.expression(s, expr.Left)
+		r.expression(s, expr.Index)
+	case *ast.SelectorExpression:
+		r.expression(s, expr.Left)
+	case *ast.StructLiteral:
+		r.expression(s, expr.Type)
+		for _, val := range expr.Values {
+			r.expression(s, val)
+		}
+	case *ast.FunctionLiteral:
+		r.deferFunction(s, expr)
+	}
+}
+
+func (r *resolver) pattern(s *scope, pattern ast.Pattern) {
+	switch pattern := pattern.(type) {
+	case *ast.BindingPattern:
+		r.declare(s, pattern.Name, kindBinding)
+	case *ast.ArrayPattern:
+		for _, e := range pattern.Elements {
+			r.pattern(s, e)
+		}
+		if pattern.Rest != nil {
+			r.declare(s, pattern.Rest, kindBinding)
+		}
+	case *ast.MapPattern:
+		for _, v := range pattern.Values {
+			r.pattern(s, v)
+		}
+	}
+}
+
+// 全局作用域中的名字可能被其他模块或宿主程序使用,参数是函数签名的一部分,都不报告
+// 以下划线开头的名字表示有意不使用
+func (r *resolver) reportUnused() {
+	for _, s := range r.scopes {
+		if s.global {
+			continue
+		}
+		for _, sym := range s.order {
+			if sym.used || sym.kind == kindParameter || strings.HasPrefix(sym.name, "_") {
+				continue
+			}
+			r.report(sym.decl.Token, WARNING, UNUSED, "%s %s declared and not used", sym.kind, sym.name)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package resolver
+
+import (
+	"fmt"
+	"my-interpreter/ast"
+	"my-interpreter/lexer"
+	"my-interpreter/parser"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func resolve(t *testing.T, input string) (*ast.Program, []Diagnostic) {
+	t.Helper()
+	p := parser.NewParser(lexer.NewLexer(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser has errors for %q: %v", input, p.Errors())
+	}
+	return program, Resolve(program, []string{"len", "push"})
+}
+
+func TestDiagnostics(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{`let a = 1; len([a])`, nil},
+		{`let a = b;`, []string{"1:9: error: undefined: b"}},
+		{"let f = fn(x) {\n  if (x) { y } else { x }\n};", []string{"2:12: error: undefined: y"}},
+		{`let f = fn() { g() }; let g = fn() { 1 };`, nil},
+		{`f(); fn f() { 1 }`, nil},
+		{`x; let x = 1;`, []string{"1:1: error: undefined: x"}},
+		{`let f = fn() { let unused = 1; 2 };`, []string{"1:20: warning: variable unused declared and not used"}},
+		{`let f = fn() { let _tmp = 1; 2 };`, nil},
+		{`let f = fn(unusedParam) { 2 };`, nil},
+		{`let x = 1; let f = fn(x) { x };`, []string{"1:23: warning: x shadows declaration at 1:5"}},
+		{`let f = fn(x, y, x) { x + y };`, []string{"1:18: error: duplicate parameter x"}},
+		{`let f = fn(x, ...x) { x };`, []string{"1:18: error: duplicate parameter x"}},
+		{`let x = 1; let x = 2; x`, nil},
+		{`let f = fn(x) { let x = x + 1; x };`, nil},
+		{`try { 1 } catch (e) { 2 }`, []string{"1:18: warning: binding e declared and not used"}},
+		{`try { 1 } catch (e) { e }; e`, []string{"1:28: error: undefined: e"}},
+		{`match ([1]) { [h, ...t] if h > 0 => t, n => 0, _ => 1 }`, []string{"1:40: warning: binding n declared and not used"}},
+		{`struct P { x }; P{x: 1}.x`, nil},
+		{`Q{x: 1}`, []string{"1:1: error: undefined: Q"}},
+		{`import "lib/math"; math.add(1, 2)`, nil},
+		{`import "lib/math" as m; math.add(1, 2)`, []string{"1:25: error: undefined: math"}},
+		{`let f = fn(x, y = x + z) { y };`, []string{"1:23: error: undefined: z"}},
+		{`let f = fn(x) { f(...[x], x: 1) };`, nil},
+		{`let f = fn() { let g = fn() { h }; g };`, []string{"1:31: error: undefined: h"}},
+	}
+	for _, tt := range tests {
+		_, diags := resolve(t, tt.input)
+		var got []string
+		for _, d := range diags {
+			got = append(got, d.String())
+		}
+		if strings.Join(got, "\n") != strings.Join(tt.expected, "\n") {
+			t.Errorf("%q: expected %q. got=%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestBindings(t *testing.T) {
+	program, diags := resolve(t, `let a = 1; let f = fn(x, y) { let g = fn() { [x, y, a] }; g };`)
+	if len(diags) != 0 {
+		t.Fatalf("unexpected diagnostics: %v", diags)
+	}
+	f := program.Statements[1].(*ast.LetStatement).Value.(*ast.FunctionLiteral)
+	g := f.Body.Statements[0].(*ast.LetStatement).Value.(*ast.FunctionLiteral)
+	arr := g.Body.Statements[0].(*ast.ExpressionStatement).Expr.(*ast.ArrLiteral)
+	expected := []ast.Binding{{Depth: 1}, {Depth: 1}, {Depth: 2}}
+	for i, e := range arr.Elements {
+		ident := e.(*ast.Identifier)
+		if ident.Binding == nil || *ident.Binding != expected[i] {
+			t.Errorf("%s: expected binding %+v. got=%+v", ident, expected[i], ident.Binding)
+		}
+	}
+	ret := f.Body.Statements[1].(*ast.ExpressionStatement).Expr.(*ast.Identifier)
+	if ret.Binding == nil || *ret.Binding != (ast.Binding{Depth: 0}) {
+		t.Errorf("g: wrong binding. got=%+v", ret.Binding)
+	}
+
+	program, _ = resolve(t, `len`)
+	if ident := program.Statements[0].(*ast.ExpressionStatement).Expr.(*ast.Identifier); ident.Binding != nil {
+		t.Errorf("globals should not be bound. got=%+v", ident.Binding)
+	}
+}
+
+func TestAnalyze(t *testing.T) {
+	input := `let a = 1; let f = fn(a) { a + b }; let a = 2; fn b() { a } len(a)`
+	p := parser.NewParser(lexer.NewLexer(input))
+	program := p.ParseProgram()
+	diagnostics, defs := Analyze(program, []string{"len"})
+	if !reflect.DeepEqual(diagnostics, Resolve(program, []string{"len"})) {
+		t.Errorf("Analyze and Resolve report different diagnostics: %v", diagnostics)
+	}
+	//每个名字引用的位置对应声明的位置
+	expected := map[string]string{
+		"1:5":  "1:5",
+		"1:16": "1:16",
+		"1:23": "1:23",
+		"1:28": "1:23",
+		"1:32": "1:51",
+		"1:41": "1:5",
+		"1:51": "1:51",
+		"1:57": "1:5",
+		"1:65": "1:5",
+	}
+	got := map[string]string{}
+	pos := func(ident *ast.Identifier) string {
+		return fmt.Sprintf("%d:%d", ident.Token.Line, ident.Token.Column)
+	}
+	for ident, decl := range defs {
+		got[pos(ident)] = pos(decl)
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("wrong definitions.\nexpected=%v\ngot=     %v", expected, got)
+	}
+}
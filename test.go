@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"my-interpreter/coverage"
+	evaluator "my-interpreter/evaluator"
+	"my-interpreter/testrunner"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+// 运行参数中的测试文件,或参数中目录下的*_test.mi,没有参数时为当前目录
+func testCommand(args []string) int {
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	run := flags.String("run", "", "run only tests whose name matches `regexp`")
+	verbose := flags.Bool("v", false, "also list passing tests")
+	junit := flags.String("junit", "", "write results to `file` in JUnit XML format")
+	var cover coverOptions
+	cover.register(flags)
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	opts := testrunner.Options{SearchPath: filepath.SplitList(os.Getenv(evaluator.PATH_ENV))}
+	if *run != "" {
+		re, err := regexp.Compile(*run)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+		opts.Run = re
+	}
+	paths := flags.Args()
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+	files, err := testrunner.Discover(paths)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if len(files) == 0 {
+		fmt.Println("no test files")
+		return 0
+	}
+
+	var cov *coverage.Coverage
+	if cover.enabled() {
+		cov = coverage.New()
+		opts.Hook = cov
+	}
+	var suites []*testrunner.Suite
+	code, tests, failed := 0, 0, 0
+	for _, path := range files {
+		program, ok := loadFile(path)
+		if !ok {
+			code = 1
+			continue
+		}
+		if cov != nil {
+			cov.Add(path, program)
+		}
+		suite := testrunner.Run(context.Background(), path, program, opts)
+		suite.WriteText(os.Stdout, *verbose)
+		suites = append(suites, suite)
+		tests += len(suite.Results)
+		failed += suite.Failed()
+	}
+	if failed > 0 {
+		code = 1
+		fmt.Printf("FAIL: %d of %s failed\n", failed, plural(tests, "test"))
+	} else if code == 0 {
+		fmt.Printf("PASS: %s\n", plural(tests, "test"))
+	}
+	if !writeOutput(*junit, func(w io.Writer) error { return testrunner.WriteJUnit(w, suites) }) {
+		code = 1
+	}
+	if cov != nil && !cover.write(cov) {
+		code = 1
+	}
+	return code
+}
+
+// n个word,n不为1时word用复数
+func plural(n int, word string) string {
+	if n != 1 {
+		word += "s"
+	}
+	return fmt.Sprintf("%d %s", n, word)
+}
@@ -0,0 +1,130 @@
+package testrunner
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"my-interpreter/object"
+	"strings"
+	"time"
+)
+
+// 错误的位置,消息和经过的函数,用于报告失败的测试
+func Describe(file string, err *object.Error) string {
+	var out strings.Builder
+	if err.Line > 0 {
+		if err.File != "" {
+			file = err.File
+		}
+		fmt.Fprintf(&out, "%s:%d:%d: ", file, err.Line, err.Column)
+	}
+	out.WriteString(err.Msg)
+	for _, frame := range err.Trace {
+		out.WriteString("\n  in " + frame)
+	}
+	return out.String()
+}
+
+func seconds(d time.Duration) string {
+	return fmt.Sprintf("%.3fs", d.Seconds())
+}
+
+// 输出失败的测试和文件的结果,verbose时也输出通过的测试
+func (s *Suite) WriteText(w io.Writer, verbose bool) {
+	for _, r := range s.Results {
+		if r.Err == nil {
+			if verbose {
+				fmt.Fprintf(w, "--- PASS: %s (%s)\n", r.Name, seconds(r.Duration))
+			}
+			continue
+		}
+		fmt.Fprintf(w, "--- FAIL: %s (%s)\n", r.Name, seconds(r.Duration))
+		for _, line := range strings.Split(Describe(s.File, r.Err), "\n") {
+			fmt.Fprintf(w, "    %s\n", line)
+		}
+	}
+	status, note := "ok  ", ""
+	if s.Failed() > 0 {
+		status = "FAIL"
+	}
+	if len(s.Results) == 0 {
+		note = " [no tests to run]"
+	}
+	fmt.Fprintf(w, "%s\t%s\t%s%s\n", status, s.File, seconds(s.Duration), note)
+}
+
+// JUnit XML的元素,只包含CI系统常用的属性
+type junitSuites struct {
+	XMLName  xml.Name     `xml:"testsuites"`
+	Tests    int          `xml:"tests,attr"`
+	Failures int          `xml:"failures,attr"`
+	Errors   int          `xml:"errors,attr"`
+	Time     string       `xml:"time,attr"`
+	Suites   []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name     string      `xml:"name,attr"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Errors   int         `xml:"errors,attr"`
+	Time     string      `xml:"time,attr"`
+	Cases    []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Error     *junitFailure `xml:"error,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+func junitTime(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
+
+// 以JUnit XML格式写出所有测试的结果,断言失败记为failure,其余错误记为error
+func WriteJUnit(w io.Writer, suites []*Suite) error {
+	var total time.Duration
+	res := junitSuites{Suites: []junitSuite{}}
+	for _, s := range suites {
+		js := junitSuite{Name: s.File, Tests: len(s.Results), Time: junitTime(s.Duration)}
+		for _, r := range s.Results {
+			jc := junitCase{Name: r.Name, ClassName: s.File, Time: junitTime(r.Duration)}
+			if r.Err != nil {
+				f := &junitFailure{Message: r.Err.Msg, Type: string(r.Err.Kind), Text: Describe(s.File, r.Err)}
+				if r.Err.Kind == object.ASSERTION_ERROR {
+					jc.Failure = f
+					js.Failures++
+				} else {
+					jc.Error = f
+					js.Errors++
+				}
+			}
+			js.Cases = append(js.Cases, jc)
+		}
+		res.Tests += js.Tests
+		res.Failures += js.Failures
+		res.Errors += js.Errors
+		total += s.Duration
+		res.Suites = append(res.Suites, js)
+	}
+	res.Time = junitTime(total)
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(res); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
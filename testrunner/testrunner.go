@@ -0,0 +1,134 @@
+// testrunner包运行用脚本语言写的测试:*_test.mi文件中名字以test开头的顶层函数
+// 每个测试在新的环境中重新求值整个文件,再不带参数调用测试函数,测试之间互不影响
+package testrunner
+
+import (
+	"context"
+	"io/fs"
+	"my-interpreter/ast"
+	evaluator "my-interpreter/evaluator"
+	"my-interpreter/object"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 测试文件名的后缀
+const SUFFIX = "_test" + evaluator.FILE_EXT
+
+// 测试函数名的前缀
+const PREFIX = "test"
+
+type Options struct {
+	Run        *regexp.Regexp //只运行名字匹配的测试,nil时运行全部
+	SearchPath []string       //模块搜索路径,每个测试使用新的模块加载器
+	Hook       evaluator.Hook //每个测试都会重新求值文件的顶层语句,覆盖率等计数是所有测试的总和
+}
+
+// 一个测试函数的结果
+type Result struct {
+	Name     string
+	Err      *object.Error //nil表示通过
+	Duration time.Duration
+}
+
+// 一个测试文件的结果
+type Suite struct {
+	File     string
+	Results  []Result
+	Duration time.Duration
+}
+
+func (s *Suite) Failed() int {
+	n := 0
+	for _, r := range s.Results {
+		if r.Err != nil {
+			n++
+		}
+	}
+	return n
+}
+
+// 查找测试文件,paths中的目录递归查找,文件直接使用,结果按路径排序
+func Discover(paths []string) ([]string, error) {
+	var files []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		err = filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() && strings.HasSuffix(d.Name(), SUFFIX) {
+				files = append(files, p)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// 顶层的测试函数名,包括具名函数声明和let绑定的函数,按出现的顺序排列
+func Tests(program *ast.Program) []string {
+	var names []string
+	for _, stmt := range program.Statements {
+		var name string
+		switch stmt := stmt.(type) {
+		case *ast.FunctionStatement:
+			name = stmt.Name.Token.Literal
+		case *ast.LetStatement:
+			if _, ok := stmt.Value.(*ast.FunctionLiteral); ok {
+				name = stmt.Name.Token.Literal
+			}
+		}
+		if strings.HasPrefix(name, PREFIX) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// 运行path中的测试,program是解析好的path
+func Run(ctx context.Context, path string, program *ast.Program, opts Options) *Suite {
+	suite := &Suite{File: path}
+	start := time.Now()
+	for _, name := range Tests(program) {
+		if opts.Run != nil && !opts.Run.MatchString(name) {
+			continue
+		}
+		suite.Results = append(suite.Results, runTest(ctx, path, program, name, opts))
+	}
+	suite.Duration = time.Since(start)
+	return suite
+}
+
+func runTest(ctx context.Context, path string, program *ast.Program, name string, opts Options) Result {
+	start := time.Now()
+	cfg := evaluator.Config{Importer: evaluator.NewImporter(opts.SearchPath...), File: path, Hook: opts.Hook}
+	env := object.NewEnvironment()
+	res := evaluator.EvalContext(ctx, program, env, cfg)
+	if _, ok := res.(*object.Error); !ok {
+		//顶层的return可能使测试函数的定义没有执行
+		if fn, ok := env.Get(name); ok {
+			res = evaluator.ApplyFunctionContext(ctx, fn, nil, cfg)
+		} else {
+			res = &object.Error{Kind: object.RUNTIME_ERROR, Msg: "test function " + name + " not defined"}
+		}
+	}
+	result := Result{Name: name, Duration: time.Since(start)}
+	result.Err, _ = res.(*object.Error)
+	return result
+}
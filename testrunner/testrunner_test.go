@@ -0,0 +1,181 @@
+package testrunner
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"my-interpreter/ast"
+	"my-interpreter/lexer"
+	"my-interpreter/object"
+	"my-interpreter/parser"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+const src = `import "lib";
+let calls = [];
+fn testPass() {
+	assertEq(lib.double(2), 4)
+}
+fn testIsolated() {
+	let calls = push(calls, 1);
+	assertEq(len(calls), 1)
+}
+let testFail = fn() {
+	assert(false, "nope")
+};
+let testThrow = fn() { throw error("boom") };
+let testHelper = 1;
+fn helper() { 1 }`
+
+func writeFiles(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func parse(t *testing.T, src string) *ast.Program {
+	t.Helper()
+	p := parser.NewParser(lexer.NewLexer(src))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatal(p.Errors())
+	}
+	return program
+}
+
+func TestDiscover(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"a_test.mi":       "",
+		"a.mi":            "",
+		"sub/b_test.mi":   "",
+		"sub/c_test.txt":  "",
+		"other/d_test.mi": "",
+	})
+	files, err := Discover([]string{filepath.Join(dir, "sub"), filepath.Join(dir, "a.mi"), filepath.Join(dir, "other")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, f := range files {
+		rel, _ := filepath.Rel(dir, f)
+		got = append(got, filepath.ToSlash(rel))
+	}
+	if strings.Join(got, " ") != "a.mi other/d_test.mi sub/b_test.mi" {
+		t.Errorf("files = %v", got)
+	}
+	if _, err := Discover([]string{filepath.Join(dir, "missing")}); err == nil {
+		t.Error("expected an error for a missing path")
+	}
+}
+
+func TestTests(t *testing.T) {
+	got := strings.Join(Tests(parse(t, src)), " ")
+	if got != "testPass testIsolated testFail testThrow" {
+		t.Errorf("tests = %s", got)
+	}
+}
+
+func TestRun(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"lib.mi":      "let double = fn(x) { x * 2 };",
+		"lib_test.mi": src,
+	})
+	path := filepath.Join(dir, "lib_test.mi")
+	suite := Run(context.Background(), path, parse(t, src), Options{})
+	if len(suite.Results) != 4 || suite.Failed() != 2 {
+		t.Fatalf("results = %+v", suite.Results)
+	}
+	for i, want := range []struct {
+		name string
+		kind object.ErrorKind
+		msg  string
+	}{
+		{"testPass", "", ""},
+		{"testIsolated", "", ""},
+		{"testFail", object.ASSERTION_ERROR, "nope: assertion failed"},
+		{"testThrow", object.USER_ERROR, "boom"},
+	} {
+		r := suite.Results[i]
+		if r.Name != want.name {
+			t.Errorf("result %d: name = %s, want %s", i, r.Name, want.name)
+		}
+		if r.Err == nil && want.msg != "" || r.Err != nil && (r.Err.Kind != want.kind || r.Err.Msg != want.msg) {
+			t.Errorf("%s: error = %+v, want %s %q", r.Name, r.Err, want.kind, want.msg)
+		}
+	}
+
+	suite = Run(context.Background(), path, parse(t, src), Options{Run: regexp.MustCompile("Pass|Isolated")})
+	if len(suite.Results) != 2 || suite.Failed() != 0 {
+		t.Errorf("filtered results = %+v", suite.Results)
+	}
+
+	//顶层的return之后定义的测试函数没有绑定
+	suite = Run(context.Background(), path, parse(t, "fn testOne() { 1 }\nreturn 0;\nlet testTwo = fn() { 2 };"), Options{})
+	if len(suite.Results) != 2 || suite.Results[0].Err != nil {
+		t.Fatalf("results = %+v", suite.Results)
+	}
+	if err := suite.Results[1].Err; err == nil || err.Msg != "test function testTwo not defined" {
+		t.Errorf("testTwo: error = %+v", err)
+	}
+}
+
+func TestReports(t *testing.T) {
+	suites := []*Suite{
+		{File: "a_test.mi", Results: []Result{
+			{Name: "testOk"},
+			{Name: "testBad", Err: &object.Error{Kind: object.ASSERTION_ERROR, Msg: "values differ\n- 1\n+ 2", Line: 3, Column: 2, Trace: []string{"testBad (2:1)"}}},
+			{Name: "testErr", Err: &object.Error{Kind: object.RUNTIME_ERROR, Msg: "type mismatch"}},
+		}},
+		{File: "b_test.mi"},
+	}
+	var buf bytes.Buffer
+	suites[0].WriteText(&buf, false)
+	suites[1].WriteText(&buf, false)
+	want := `--- FAIL: testBad (0.000s)
+    a_test.mi:3:2: values differ
+    - 1
+    + 2
+      in testBad (2:1)
+--- FAIL: testErr (0.000s)
+    type mismatch
+FAIL	a_test.mi	0.000s
+ok  	b_test.mi	0.000s [no tests to run]
+`
+	if buf.String() != want {
+		t.Errorf("text:\n%s\nwant:\n%s", buf.String(), want)
+	}
+
+	buf.Reset()
+	if err := WriteJUnit(&buf, suites); err != nil {
+		t.Fatal(err)
+	}
+	var doc junitSuites
+	if err := xml.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.Tests != 3 || doc.Failures != 1 || doc.Errors != 1 || len(doc.Suites) != 2 {
+		t.Fatalf("testsuites = %+v", doc)
+	}
+	cases := doc.Suites[0].Cases
+	if cases[0].Failure != nil || cases[0].Error != nil {
+		t.Errorf("testOk = %+v", cases[0])
+	}
+	if f := cases[1].Failure; f == nil || f.Type != "ASSERTION_ERROR" || !strings.HasPrefix(f.Text, "a_test.mi:3:2: values differ\n- 1") {
+		t.Errorf("testBad failure = %+v", f)
+	}
+	if e := cases[2].Error; e == nil || e.Message != "type mismatch" || cases[2].ClassName != "a_test.mi" {
+		t.Errorf("testErr = %+v", cases[2])
+	}
+}
@@ -1,5 +1,7 @@
 package token
 
+import "sort"
+
 const (
 	//
 	ILLEGAL = "ILLEGAL"
@@ -24,10 +26,15 @@ const (
 	EQ  = "=="
 	NEQ = "!="
 
+	ARROW    = "=>"
+	ELLIPSIS = "..."
+	RARROW   = "->" //函数类型注解中的返回类型
+
 	//分隔符
 	COMMA     = ","
 	SEMICOLON = ";"
 	COLON     = ":"
+	DOT       = "."
 
 	LPAREN   = "("
 	RPAREN   = ")"
@@ -43,6 +50,15 @@ const (
 	IF       = "IF"
 	ELSE     = "ELSE"
 	RETURN   = "RETURN"
+	IMPORT   = "IMPORT"
+	AS       = "AS"
+	STRUCT   = "STRUCT"
+	TRY      = "TRY"
+	CATCH    = "CATCH"
+	FINALLY  = "FINALLY"
+	THROW    = "THROW"
+	MATCH    = "MATCH"
+	CONST    = "CONST"
 )
 
 type TokenType string
@@ -50,16 +66,37 @@ type TokenType string
 type Token struct {
 	Type    TokenType
 	Literal string
+	Line    int //从1开始,0表示位置未知
+	Column  int //从1开始,按字节计
 }
 
 var keywords = map[string]TokenType{
-	"let":    LET,
-	"fn":     FUNCTION,
-	"true":   TRUE,
-	"false":  FALSE,
-	"if":     IF,
-	"else":   ELSE,
-	"return": RETURN,
+	"let":     LET,
+	"fn":      FUNCTION,
+	"true":    TRUE,
+	"false":   FALSE,
+	"if":      IF,
+	"else":    ELSE,
+	"return":  RETURN,
+	"import":  IMPORT,
+	"as":      AS,
+	"struct":  STRUCT,
+	"try":     TRY,
+	"catch":   CATCH,
+	"finally": FINALLY,
+	"throw":   THROW,
+	"match":   MATCH,
+	"const":   CONST,
+}
+
+// 所有关键字,按字典序排列
+func Keywords() []string {
+	words := make([]string, 0, len(keywords))
+	for word := range keywords {
+		words = append(words, word)
+	}
+	sort.Strings(words)
+	return words
 }
 
 func LookupIdentifier(s string) TokenType {
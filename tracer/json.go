@@ -0,0 +1,97 @@
+package tracer
+
+import (
+	"encoding/json"
+	"io"
+	"my-interpreter/object"
+)
+
+// JSON Lines中的一个事件,Event为call,return,builtin或error
+type Event struct {
+	Seq      int     `json:"seq"`
+	Event    string  `json:"event"`
+	Depth    int     `json:"depth"`
+	Function string  `json:"function,omitempty"`
+	Args     []Value `json:"args,omitempty"`
+	Result   *Value  `json:"result,omitempty"`
+	Tail     bool    `json:"tail,omitempty"` //return事件,尾调用了另一个函数
+	Kind     string  `json:"kind,omitempty"` //error事件
+	Message  string  `json:"message,omitempty"`
+	File     string  `json:"file,omitempty"` //call事件为函数定义的位置,error事件为出错的位置
+	Line     int     `json:"line,omitempty"`
+	Column   int     `json:"column,omitempty"`
+}
+
+// 事件中的值,Value是值的单行表示
+type Value struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+func newValue(obj object.Object) *Value {
+	v := &Value{Type: string(obj.Type()), Value: value(obj)}
+	if s, ok := obj.(*object.String); ok {
+		v.Value = s.Value
+	}
+	return v
+}
+
+func newValues(objs []object.Object) []Value {
+	vals := make([]Value, len(objs))
+	for i, obj := range objs {
+		vals[i] = *newValue(obj)
+	}
+	return vals
+}
+
+// 每个事件写出一行JSON,便于日志系统收集和检索
+type JSON struct {
+	base
+	seq int
+}
+
+func NewJSON(w io.Writer) *JSON {
+	return &JSON{base: base{w: w}}
+}
+
+func (j *JSON) event(e Event) {
+	j.seq++
+	e.Seq = j.seq
+	e.Depth = j.depth
+	data, err := json.Marshal(e)
+	if err != nil {
+		if j.err == nil {
+			j.err = err
+		}
+		return
+	}
+	j.write(append(data, '\n'))
+}
+
+// Call实现evaluator.Hook
+func (j *JSON) Call(fn *object.Function, args []object.Object, env *object.Environment) {
+	j.event(Event{Event: "call", Function: name(fn), Args: newValues(args), File: fn.File, Line: fn.Token.Line})
+	j.depth++
+}
+
+// Return实现evaluator.Hook
+func (j *JSON) Return(fn *object.Function, result object.Object) {
+	if j.depth > 0 {
+		j.depth--
+	}
+	e := Event{Event: "return", Function: name(fn), Tail: result == nil}
+	if result != nil {
+		e.Result = newValue(result)
+	}
+	j.event(e)
+}
+
+// Builtin实现evaluator.TraceHook
+func (j *JSON) Builtin(fn *object.Builtins, args []object.Object, result object.Object) {
+	j.event(Event{Event: "builtin", Function: fn.Name, Args: newValues(args), Result: newValue(result)})
+}
+
+// Error实现evaluator.TraceHook
+func (j *JSON) Error(err *object.Error) {
+	j.event(Event{Event: "error", Kind: string(err.Kind), Message: err.Msg, File: err.File, Line: err.Line, Column: err.Column})
+}
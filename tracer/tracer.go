@@ -0,0 +1,124 @@
+// tracer包跟踪脚本的执行:函数的调用和返回,内置函数的调用和产生的错误
+// 跟踪器实现evaluator.TraceHook,作为Config.Hook传入,不传时求值器不做任何额外的工作
+package tracer
+
+import (
+	"io"
+	"my-interpreter/ast"
+	"my-interpreter/object"
+	"strconv"
+	"strings"
+)
+
+// 两种跟踪器共用的部分,记录调用深度和写出时的第一个错误
+type base struct {
+	w     io.Writer
+	depth int
+	err   error
+}
+
+func (b *base) write(p []byte) {
+	if b.err == nil {
+		_, b.err = b.w.Write(p)
+	}
+}
+
+// 写出跟踪记录时的第一个错误,之后的记录不再写出
+func (b *base) Err() error {
+	return b.err
+}
+
+// Statement实现evaluator.Hook,不跟踪语句
+func (b *base) Statement(file string, stmt ast.Statement, env *object.Environment) *object.Error {
+	return nil
+}
+
+func name(fn *object.Function) string {
+	if fn.Name == "" {
+		return "<anonymous>"
+	}
+	return fn.Name
+}
+
+// 值的单行表示,字符串加引号,函数只保留签名
+func value(obj object.Object) string {
+	switch obj := obj.(type) {
+	case *object.String:
+		return strconv.Quote(obj.Value)
+	case *object.Function:
+		return "fn " + name(obj) + "(" + ast.ParametersString(obj.Parameters, obj.Defaults, obj.Rest) + ")"
+	case *object.Builtins:
+		return "builtin " + obj.Name
+	case *object.Error:
+		return "error: " + obj.Msg
+	}
+	return strings.ReplaceAll(obj.Inspect(), "\n", `\n`)
+}
+
+func values(objs []object.Object) string {
+	strs := make([]string, len(objs))
+	for i, obj := range objs {
+		strs[i] = value(obj)
+	}
+	return strings.Join(strs, ", ")
+}
+
+// 人类可读的跟踪,每个事件一行,按调用深度缩进
+//
+//	call fact(2) at t.mi:1
+//	  builtin len([1]) = 1
+//	  return fact = 2
+type Text struct {
+	base
+}
+
+func NewText(w io.Writer) *Text {
+	return &Text{base{w: w}}
+}
+
+func (t *Text) line(s string) {
+	t.write([]byte(strings.Repeat("  ", t.depth) + s + "\n"))
+}
+
+// Call实现evaluator.Hook
+func (t *Text) Call(fn *object.Function, args []object.Object, env *object.Environment) {
+	t.line("call " + name(fn) + "(" + values(args) + ") at " + position(fn.File, fn.Token.Line, 0))
+	t.depth++
+}
+
+// Return实现evaluator.Hook
+func (t *Text) Return(fn *object.Function, result object.Object) {
+	if t.depth > 0 {
+		t.depth--
+	}
+	switch result.(type) {
+	case nil:
+		t.line("return " + name(fn) + " by tail call")
+	case *object.Error:
+		t.line("return " + name(fn) + " with " + value(result))
+	default:
+		t.line("return " + name(fn) + " = " + value(result))
+	}
+}
+
+// Builtin实现evaluator.TraceHook
+func (t *Text) Builtin(fn *object.Builtins, args []object.Object, result object.Object) {
+	t.line("builtin " + fn.Name + "(" + values(args) + ") = " + value(result))
+}
+
+// Error实现evaluator.TraceHook
+func (t *Text) Error(err *object.Error) {
+	s := "error " + string(err.Kind) + ": " + err.Msg
+	if err.Line > 0 {
+		s += " at " + position(err.File, err.Line, err.Column)
+	}
+	t.line(strings.ReplaceAll(s, "\n", `\n`))
+}
+
+func position(file string, line, column int) string {
+	s := file + ":" + strconv.Itoa(line)
+	if column > 0 {
+		s += ":" + strconv.Itoa(column)
+	}
+	return s
+}
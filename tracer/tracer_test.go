@@ -0,0 +1,151 @@
+package tracer
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	evaluator "my-interpreter/evaluator"
+	"my-interpreter/lexer"
+	"my-interpreter/object"
+	"my-interpreter/parser"
+	"strings"
+	"testing"
+)
+
+const src = `fn fact(n) {
+	if (n < 2) { return 1; }
+	n * fact(n - 1)
+}
+let loop = fn(i, acc) { if (i == 0) { acc } else { loop(i - 1, push(acc, i)) } };
+fact(2);
+loop(1, []);
+let bad = fn(x) { x + 1 };
+let msg = try { bad([1]) } catch (e) { e.message };
+len(msg);
+throw error("done")`
+
+func run(t *testing.T, hook evaluator.Hook, src string) object.Object {
+	t.Helper()
+	p := parser.NewParser(lexer.NewLexer(src))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatal(p.Errors())
+	}
+	return evaluator.EvalContext(context.Background(), program, object.NewEnvironment(), evaluator.Config{File: "t.mi", Hook: hook})
+}
+
+func TestText(t *testing.T) {
+	var buf bytes.Buffer
+	tr := NewText(&buf)
+	run(t, tr, src)
+	if tr.Err() != nil {
+		t.Fatal(tr.Err())
+	}
+	want := `call fact(2) at t.mi:1
+  call fact(1) at t.mi:1
+  return fact = 1
+return fact = 2
+call loop(1, []) at t.mi:5
+  builtin push([], 1) = [1]
+return loop by tail call
+call loop(0, [1]) at t.mi:5
+return loop = [1]
+call bad([1]) at t.mi:8
+  error RUNTIME_ERROR: type mismatch: ARRAY + INTEGER at t.mi:8:21
+return bad with error: type mismatch: ARRAY + INTEGER
+builtin len("type mismatch: ARRAY + INTEGER") = 30
+builtin error("done") = error: done
+error USER_ERROR: done at t.mi:11:1
+`
+	if buf.String() != want {
+		t.Errorf("trace:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
+
+func TestJSON(t *testing.T) {
+	var buf bytes.Buffer
+	tr := NewJSON(&buf)
+	run(t, tr, `let f = fn(s, xs) { len(xs) + s };
+f(1, ["a"]);
+f("x", [])`)
+	var events []Event
+	sc := bufio.NewScanner(&buf)
+	for sc.Scan() {
+		var e Event
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			t.Fatalf("%s: %v", sc.Text(), err)
+		}
+		events = append(events, e)
+	}
+	var got []string
+	for i, e := range events {
+		if e.Seq != i+1 {
+			t.Errorf("event %d has seq %d", i, e.Seq)
+		}
+		s := e.Event + " " + e.Function
+		for _, a := range e.Args {
+			s += " " + a.Type + ":" + a.Value
+		}
+		if e.Result != nil {
+			s += " -> " + e.Result.Type + ":" + e.Result.Value
+		}
+		if e.Kind != "" {
+			s += " " + e.Kind + " " + e.Message
+		}
+		got = append(got, strings.Repeat(">", e.Depth)+s)
+	}
+	want := []string{
+		"call f INTEGER:1 ARRAY:[a]",
+		">builtin len ARRAY:[a] -> INTEGER:1",
+		"return f -> INTEGER:2",
+		"call f STRING:x ARRAY:[]",
+		">builtin len ARRAY:[] -> INTEGER:0",
+		">error  RUNTIME_ERROR type mismatch: INTEGER + STRING",
+		"return f -> ERROR:error: type mismatch: INTEGER + STRING",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("events:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if e := events[5]; e.File != "t.mi" || e.Line != 1 || e.Column != 29 {
+		t.Errorf("error at %s:%d:%d", e.File, e.Line, e.Column)
+	}
+	if e := events[0]; e.File != "t.mi" || e.Line != 1 {
+		t.Errorf("call at %s:%d", e.File, e.Line)
+	}
+}
+
+// 每个错误只报告一次,没有位置的错误在求值结束时报告
+func TestErrorsOnce(t *testing.T) {
+	var buf bytes.Buffer
+	tr := NewText(&buf)
+	run(t, tr, "let f = fn() { g() };\nlet g = fn() { throw error(\"x\") };\ntry { f() } catch (e) { throw e }")
+	if n := strings.Count(buf.String(), "error USER_ERROR"); n != 2 {
+		t.Errorf("trace has %d errors, want the throw and the rethrow:\n%s", n, buf.String())
+	}
+
+	buf.Reset()
+	builtin, _ := run(t, nil, "len").(*object.Builtins)
+	evaluator.ApplyFunctionContext(context.Background(), builtin, nil, evaluator.Config{Hook: tr})
+	want := "builtin len() = error: wrong number of arguments. got=0, want=1\nerror RUNTIME_ERROR: wrong number of arguments. got=0, want=1\n"
+	if buf.String() != want {
+		t.Errorf("trace:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
+
+type failingWriter struct{ n int }
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	w.n++
+	return 0, errors.New("disk full")
+}
+
+func TestWriteError(t *testing.T) {
+	w := &failingWriter{}
+	tr := NewJSON(w)
+	run(t, tr, "len([1]); len([2])")
+	if tr.Err() == nil || tr.Err().Error() != "disk full" || w.n != 1 {
+		t.Errorf("err = %v after %d writes", tr.Err(), w.n)
+	}
+}
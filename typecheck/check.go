@@ -0,0 +1,727 @@
+// typecheck包在求值之前根据可选的类型注解和字面量做局部的类型推断,报告一定会在求值时出错的类型不匹配
+// 没有注解且推断不出的值是Any,与任何类型兼容,所以没有注解的代码仍然是动态的
+package typecheck
+
+import (
+	"fmt"
+	"my-interpreter/ast"
+	"my-interpreter/token"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+type Diagnostic struct {
+	Line   int
+	Column int
+	Msg    string
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%d:%d: error: %s", d.Line, d.Column, d.Msg)
+}
+
+// 结构体类型本身的类型,即struct声明绑定的名字的类型
+type structType struct {
+	s *Struct
+}
+
+func (st structType) String() string {
+	return "struct " + st.s.Name
+}
+
+// 带注解的名字在同一作用域中重新绑定时也要符合注解
+type variable struct {
+	typ       Type
+	annotated bool
+}
+
+// 作用域与求值时的object.Environment对应,语句块不产生作用域
+type scope struct {
+	outer *scope
+	vars  map[string]*variable
+}
+
+func (s *scope) lookup(name string) (*variable, bool) {
+	for cur := s; cur != nil; cur = cur.outer {
+		if v, ok := cur.vars[name]; ok {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+// 正在检查的函数,returns收集其中return语句的类型
+type function struct {
+	name    string
+	ret     Type //注解的返回类型,没有时为nil
+	returns []Type
+}
+
+type checker struct {
+	scope       *scope
+	fn          *function
+	structs     map[string]*Struct
+	diagnostics []Diagnostic
+	reported    map[Diagnostic]bool //具名函数的签名会检查两次
+}
+
+// 检查没有语法错误的program,按位置返回诊断
+func Check(program *ast.Program) []Diagnostic {
+	c := &checker{
+		scope:    &scope{vars: map[string]*variable{}},
+		structs:  map[string]*Struct{},
+		reported: map[Diagnostic]bool{},
+	}
+	c.hoist(program.Statements)
+	c.statements(program.Statements)
+	sort.SliceStable(c.diagnostics, func(i, j int) bool {
+		a, b := c.diagnostics[i], c.diagnostics[j]
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+	return c.diagnostics
+}
+
+func (c *checker) report(node ast.Node, format string, a ...any) {
+	c.reportAt(ast.Pos(node), format, a...)
+}
+
+func (c *checker) reportAt(tok token.Token, format string, a ...any) {
+	d := Diagnostic{Line: tok.Line, Column: tok.Column, Msg: fmt.Sprintf(format, a...)}
+	if !c.reported[d] {
+		c.reported[d] = true
+		c.diagnostics = append(c.diagnostics, d)
+	}
+}
+
+// 具名函数和结构体在检查其他语句之前声明,函数的类型只来自注解,以便相互调用
+func (c *checker) hoist(statements []ast.Statement) {
+	for _, stmt := range statements {
+		switch stmt := stmt.(type) {
+		case *ast.StructStatement:
+			c.declareStruct(stmt)
+		case *ast.FunctionStatement:
+			c.declare(stmt.Name.Token.Literal, c.signature(stmt.Function, Any), false)
+		}
+	}
+}
+
+func (c *checker) declare(name string, typ Type, annotated bool) {
+	c.scope.vars[name] = &variable{typ: typ, annotated: annotated}
+}
+
+func (c *checker) declareStruct(stmt *ast.StructStatement) {
+	s := &Struct{Name: stmt.Name.Token.Literal}
+	for _, f := range stmt.Fields {
+		s.Fields = append(s.Fields, f.Token.Literal)
+	}
+	c.structs[s.Name] = s
+	c.declare(s.Name, structType{s}, false)
+}
+
+// 类型注解表示的类型,nil表示没有注解
+func (c *checker) resolveType(t ast.TypeExpr) Type {
+	switch t := t.(type) {
+	case nil:
+		return nil
+	case *ast.NamedType:
+		switch name := t.Token.Literal; name {
+		case "int":
+			return Int
+		case "bool":
+			return Bool
+		case "string":
+			return String
+		case "null":
+			return Null
+		case "any":
+			return Any
+		case "fn":
+			return AnyFunc
+		default:
+			if s, ok := c.structs[name]; ok {
+				return s
+			}
+			c.report(t, "unknown type %s", name)
+			return Any
+		}
+	case *ast.ArrayType:
+		return &Array{Elem: c.resolveType(t.Elem)}
+	case *ast.MapType:
+		return &Map{Key: c.resolveType(t.Key), Value: c.resolveType(t.Value)}
+	case *ast.FunctionType:
+		f := &Func{Return: Any}
+		for _, p := range t.Params {
+			f.Params = append(f.Params, c.resolveType(p))
+		}
+		f.Required = len(f.Params)
+		if t.Return != nil {
+			f.Return = c.resolveType(t.Return)
+		}
+		return f
+	}
+	return Any
+}
+
+func orAny(t Type) Type {
+	if t == nil {
+		return Any
+	}
+	return t
+}
+
+// 由函数字面量的注解得到的类型,没有注解返回类型时用ret
+func (c *checker) signature(fn *ast.FunctionLiteral, ret Type) *Func {
+	f := &Func{Return: ret}
+	for i, param := range fn.Parameters {
+		var t Type
+		if i < len(fn.ParamTypes) {
+			t = c.resolveType(fn.ParamTypes[i])
+		}
+		f.Params = append(f.Params, orAny(t))
+		f.Names = append(f.Names, param.Token.Literal)
+		if i >= len(fn.Defaults) || fn.Defaults[i] == nil {
+			f.Required = i + 1
+		}
+	}
+	if fn.Rest != nil {
+		f.Rest = Any
+		if rest := c.resolveType(fn.RestType); rest != nil {
+			if arr, ok := rest.(*Array); ok {
+				f.Rest = arr.Elem
+			} else if rest != Any {
+				c.report(fn.RestType, "rest parameter %s must have an array type, got %s", fn.Rest, rest)
+			}
+		}
+	}
+	if t := c.resolveType(fn.ReturnType); t != nil {
+		f.Return = t
+	}
+	return f
+}
+
+func (c *checker) statements(statements []ast.Statement) Type {
+	var last Type = Null
+	for _, stmt := range statements {
+		last = c.statement(stmt)
+	}
+	return last
+}
+
+// 返回语句作为语句块最后一条时的值的类型
+func (c *checker) statement(stmt ast.Statement) Type {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		c.let(stmt)
+	case *ast.FunctionStatement:
+		//已经在hoist中按注解声明过,检查函数体后换成推断出的类型
+		name := stmt.Name.Token.Literal
+		c.declare(name, c.function(stmt.Function, name), false)
+	case *ast.StructStatement:
+		c.declareStruct(stmt)
+	case *ast.ImportStatement:
+		name := ""
+		if stmt.Alias != nil {
+			name = stmt.Alias.Token.Literal
+		} else {
+			name = importName(stmt.Path.Token.Literal)
+		}
+		c.declare(name, Any, false)
+	case *ast.ReturnStatement:
+		t := c.expression(stmt.ReturnValue)
+		if c.fn != nil {
+			c.checkReturn(stmt.ReturnValue, t)
+			c.fn.returns = append(c.fn.returns, t)
+		}
+		return Any
+	case *ast.ThrowStatement:
+		c.expression(stmt.Value)
+		return Any
+	case *ast.ExpressionStatement:
+		return c.expression(stmt.Expr)
+	case *ast.BlockStatement:
+		return c.statements(stmt.Statements)
+	}
+	return Any
+}
+
+func (c *checker) let(stmt *ast.LetStatement) {
+	name := stmt.Name.Token.Literal
+	want := c.resolveType(stmt.Type)
+	var got Type
+	if fn, ok := stmt.Value.(*ast.FunctionLiteral); ok {
+		got = c.function(fn, name)
+	} else {
+		got = c.expression(stmt.Value)
+	}
+	if want == nil {
+		if prev, ok := c.scope.vars[name]; ok && prev.annotated {
+			want = prev.typ
+		}
+	}
+	if want == nil {
+		c.declare(name, got, false)
+		return
+	}
+	if !Assignable(want, got) {
+		c.report(stmt.Value, "cannot use %s as %s in let %s", got, want, name)
+	}
+	c.declare(name, want, true)
+}
+
+func (c *checker) checkReturn(node ast.Node, t Type) {
+	if c.fn.ret != nil && !Assignable(c.fn.ret, t) {
+		name := c.fn.name
+		if name == "" {
+			name = "function"
+		}
+		c.report(node, "cannot use %s as %s in return from %s", t, c.fn.ret, name)
+	}
+}
+
+// 检查函数体,没有注解返回类型时由return语句和最后一条语句推断
+func (c *checker) function(fn *ast.FunctionLiteral, name string) Type {
+	sig := c.signature(fn, Any)
+	outer, outerFn := c.scope, c.fn
+	c.scope = &scope{outer: outer, vars: map[string]*variable{}}
+	c.fn = &function{name: name}
+	if fn.ReturnType != nil {
+		c.fn.ret = sig.Return
+	}
+	defer func() {
+		c.scope, c.fn = outer, outerFn
+	}()
+	for i, param := range fn.Parameters {
+		if i < len(fn.Defaults) && fn.Defaults[i] != nil {
+			if t := c.expression(fn.Defaults[i]); !Assignable(sig.Params[i], t) {
+				c.report(fn.Defaults[i], "cannot use %s as %s in default of parameter %s", t, sig.Params[i], param)
+			}
+		}
+		c.declare(param.Token.Literal, sig.Params[i], i < len(fn.ParamTypes) && fn.ParamTypes[i] != nil)
+	}
+	if fn.Rest != nil {
+		c.declare(fn.Rest.Token.Literal, &Array{Elem: sig.Rest}, fn.RestType != nil)
+	}
+	c.hoist(fn.Body.Statements)
+	body := c.statements(fn.Body.Statements)
+	if n := len(fn.Body.Statements); n > 0 {
+		if _, ok := fn.Body.Statements[n-1].(*ast.ExpressionStatement); ok {
+			c.checkReturn(fn.Body.Statements[n-1], body)
+		}
+	}
+	if fn.ReturnType == nil {
+		ret := body
+		for _, t := range c.fn.returns {
+			ret = join(ret, t)
+		}
+		sig.Return = ret
+	}
+	return sig
+}
+
+// 依次检查各分支,分支中重新绑定的名字在之后取各分支类型的并
+func (c *checker) branches(branches ...func() Type) Type {
+	before := c.scope.vars
+	var result Type
+	var after []map[string]*variable
+	for _, branch := range branches {
+		c.scope.vars = copyVars(before)
+		result = join(result, branch())
+		after = append(after, c.scope.vars)
+	}
+	changed := map[string]bool{}
+	for _, vars := range after {
+		for name, v := range vars {
+			if v != before[name] {
+				changed[name] = true
+			}
+		}
+	}
+	merged := copyVars(before)
+	for name := range changed {
+		res := &variable{}
+		for _, vars := range after {
+			if v, ok := vars[name]; ok {
+				res.typ = join(res.typ, v.typ)
+				res.annotated = res.annotated || v.annotated
+			}
+		}
+		merged[name] = res
+	}
+	c.scope.vars = merged
+	return result
+}
+
+func copyVars(vars map[string]*variable) map[string]*variable {
+	res := make(map[string]*variable, len(vars))
+	for k, v := range vars {
+		res[k] = v
+	}
+	return res
+}
+
+func (c *checker) enclosed(f func()) {
+	outer := c.scope
+	c.scope = &scope{outer: outer, vars: map[string]*variable{}}
+	f()
+	c.scope = outer
+}
+
+func (c *checker) block(block *ast.BlockStatement) Type {
+	if block == nil {
+		return Null
+	}
+	return c.statements(block.Statements)
+}
+
+func (c *checker) expression(expr ast.Expression) Type {
+	switch expr := expr.(type) {
+	case *ast.IntLiteral:
+		return Int
+	case *ast.BoolLiteral:
+		return Bool
+	case *ast.StrLiteral:
+		return String
+	case *ast.Identifier:
+		if v, ok := c.scope.lookup(expr.Token.Literal); ok {
+			return v.typ
+		}
+		return Any
+	case *ast.ArrLiteral:
+		var elem Type
+		for _, e := range expr.Elements {
+			elem = join(elem, c.expression(e))
+		}
+		return &Array{Elem: orAny(elem)}
+	case *ast.MapLiteral:
+		var key, value Type
+		for _, k := range ast.SortedKeys(expr) {
+			key = join(key, c.expression(k))
+			value = join(value, c.expression(expr.Pairs[k]))
+		}
+		return &Map{Key: orAny(key), Value: orAny(value)}
+	case *ast.FunctionLiteral:
+		return c.function(expr, "")
+	case *ast.PrefixExpression:
+		return c.prefix(expr)
+	case *ast.InfixExpression:
+		return c.infix(expr)
+	case *ast.IfExpression:
+		c.expression(expr.Condition)
+		return c.branches(
+			func() Type { return c.block(expr.Consequence) },
+			func() Type { return c.block(expr.Alternative) },
+		)
+	case *ast.TryExpression:
+		c.branches(
+			func() Type { return c.block(expr.Body) },
+			func() Type {
+				c.enclosed(func() {
+					if expr.Param != nil {
+						c.declare(expr.Param.Token.Literal, Any, false)
+					}
+					c.block(expr.Catch)
+				})
+				return Any
+			},
+		)
+		c.block(expr.Finally)
+		return Any
+	case *ast.MatchExpression:
+		c.expression(expr.Value)
+		for _, arm := range expr.Arms {
+			c.enclosed(func() {
+				c.pattern(arm.Pattern)
+				if arm.Guard != nil {
+					c.expression(arm.Guard)
+				}
+				c.expression(arm.Body)
+			})
+		}
+		return Any
+	case *ast.CallExpression:
+		return c.call(expr)
+	case *ast.IndexExpression:
+		return c.index(expr)
+	case *ast.SelectorExpression:
+		left := c.expression(expr.Left)
+		name := expr.Selector.Token.Literal
+		switch left := left.(type) {
+		case *Struct:
+			if !left.HasField(name) {
+				c.report(expr.Selector, "unknown field %s in struct %s", name, left.Name)
+			}
+		case *Map:
+			if Assignable(left.Key, String) {
+				return left.Value
+			}
+		case *Array, *Func:
+			c.report(expr, "selector not supported: %s", left)
+		case basic:
+			if left != Any {
+				c.report(expr, "selector not supported: %s", left)
+			}
+		}
+		return Any
+	case *ast.StructLiteral:
+		return c.structLiteral(expr)
+	case *ast.SpreadExpression:
+		return c.expression(expr.Value)
+	case *ast.NamedArgument:
+		return c.expression(expr.Value)
+	}
+	return Any
+}
+
+func (c *checker) pattern(pattern ast.Pattern) {
+	ast.Inspect(pattern, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.BindingPattern:
+			c.declare(n.Name.Token.Literal, Any, false)
+		case *ast.ArrayPattern:
+			if n.Rest != nil {
+				c.declare(n.Rest.Token.Literal, &Array{Elem: Any}, false)
+			}
+		case *ast.LiteralPattern:
+			return false
+		}
+		return true
+	})
+}
+
+func (c *checker) prefix(expr *ast.PrefixExpression) Type {
+	right := c.expression(expr.Right)
+	switch expr.Token.Literal {
+	case "!":
+		return Bool
+	case "-":
+		if !Assignable(Int, right) {
+			c.report(expr, "unknown operator: -%s", right)
+		}
+		return Int
+	}
+	return Any
+}
+
+// 与evalInfixExpr一致:整数支持算术和比较,字符串只支持+,其他类型只支持==和!=
+func (c *checker) infix(expr *ast.InfixExpression) Type {
+	left, right := c.expression(expr.Left), c.expression(expr.Right)
+	op := expr.Token.Literal
+	if op == "==" || op == "!=" {
+		return Bool
+	}
+	if lo, ro := ObjectType(left), ObjectType(right); lo != "" && ro != "" && lo != ro {
+		c.reportAt(expr.Token, "type mismatch: %s %s %s", left, op, right)
+		return Any
+	}
+	//一方未知时按另一方的类型检查
+	known := left
+	if known == Any {
+		known = right
+	}
+	switch op {
+	case "+":
+		if known == Any || known == Int || known == String {
+			return known
+		}
+	case "-", "*", "/":
+		if Assignable(Int, known) {
+			return Int
+		}
+	case "<", ">":
+		if Assignable(Int, known) {
+			return Bool
+		}
+	}
+	c.reportAt(expr.Token, "unknown operator: %s %s %s", left, op, right)
+	return Any
+}
+
+func (c *checker) index(expr *ast.IndexExpression) Type {
+	left, index := c.expression(expr.Left), c.expression(expr.Index)
+	switch left := left.(type) {
+	case *Array:
+		if !Assignable(Int, index) {
+			c.reportAt(expr.Token, "array index must be int, got %s", index)
+		}
+		return left.Elem
+	case *Map:
+		if !Assignable(left.Key, index) {
+			c.reportAt(expr.Token, "cannot use %s as %s in map index", index, left.Key)
+		}
+		return left.Value
+	}
+	if left != Any {
+		c.reportAt(expr.Token, "index operator not supported: %s", left)
+	}
+	return Any
+}
+
+func (c *checker) structLiteral(expr *ast.StructLiteral) Type {
+	var s *Struct
+	switch t := c.expression(expr.Type).(type) {
+	case structType:
+		s = t.s
+	case *Struct:
+		s = t
+	default:
+		if t != Any {
+			c.report(expr, "not a struct type: %s", t)
+		}
+	}
+	for i, f := range expr.Fields {
+		c.expression(expr.Values[i])
+		if s != nil && !s.HasField(f.Token.Literal) {
+			c.report(f, "unknown field %s in struct %s", f, s.Name)
+		}
+	}
+	if s == nil {
+		return Any
+	}
+	return s
+}
+
+func (c *checker) call(expr *ast.CallExpression) Type {
+	callee := c.expression(expr.Function)
+	var positional []Type
+	var positionalNodes []ast.Node
+	named := map[string]*ast.NamedArgument{}
+	namedTypes := map[string]Type{}
+	spread := false
+	for _, arg := range expr.Arguments {
+		t := c.expression(arg)
+		switch arg := arg.(type) {
+		case *ast.SpreadExpression:
+			spread = true
+			if _, ok := t.(*Array); !ok && t != Any {
+				c.report(arg, "cannot spread %s", t)
+			}
+		case *ast.NamedArgument:
+			named[arg.Name.Token.Literal] = arg
+			namedTypes[arg.Name.Token.Literal] = t
+		default:
+			positional = append(positional, t)
+			positionalNodes = append(positionalNodes, arg)
+		}
+	}
+	if ident, ok := expr.Function.(*ast.Identifier); ok && callee == Any {
+		if _, declared := c.scope.lookup(ident.Token.Literal); !declared {
+			return c.builtinCall(ident.Token.Literal, positional, positionalNodes)
+		}
+	}
+	fn, ok := callee.(*Func)
+	if !ok {
+		if callee != Any && callee != AnyFunc {
+			c.reportAt(expr.Token, "cannot call %s", callee)
+		}
+		return Any
+	}
+	name := expr.Function.String()
+	for i, t := range positional {
+		want := fn.Rest
+		param := "..."
+		if i < len(fn.Params) {
+			want = fn.Params[i]
+			param = fmt.Sprintf("%d", i+1)
+			if i < len(fn.Names) {
+				param = fn.Names[i]
+			}
+		}
+		if want == nil {
+			if !spread {
+				c.reportAt(expr.Token, "too many arguments in call to %s: got=%d, want at most %d", name, len(positional), len(fn.Params))
+			}
+			break
+		}
+		if !Assignable(want, t) {
+			c.report(positionalNodes[i], "cannot use %s as %s in argument %s to %s", t, want, param, name)
+		}
+	}
+	if fn.Names == nil {
+		return fn.Return
+	}
+	for argName, arg := range named {
+		i := indexOf(fn.Names, argName)
+		if i < 0 {
+			c.report(arg, "unknown parameter %s in call to %s", argName, name)
+			continue
+		}
+		if t := namedTypes[argName]; !Assignable(fn.Params[i], t) {
+			c.report(arg.Value, "cannot use %s as %s in argument %s to %s", t, fn.Params[i], argName, name)
+		}
+	}
+	if !spread {
+		for i := len(positional); i < fn.Required; i++ {
+			if named[fn.Names[i]] == nil {
+				c.reportAt(expr.Token, "missing argument for parameter %s in call to %s", fn.Names[i], name)
+			}
+		}
+	}
+	return fn.Return
+}
+
+// 没有别名的import绑定的名字,与Importer一致
+func importName(path string) string {
+	return strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+}
+
+func indexOf(names []string, name string) int {
+	for i, n := range names {
+		if n == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// 内置函数的参数个数由lint检查,这里只检查参数类型并推断结果的类型
+func (c *checker) builtinCall(name string, args []Type, nodes []ast.Node) Type {
+	arg := func(i int) Type {
+		if i < len(args) {
+			return args[i]
+		}
+		return Any
+	}
+	wantArray := func() *Array {
+		switch t := arg(0).(type) {
+		case *Array:
+			return t
+		default:
+			if t != Any {
+				c.report(nodes[0], "argument to `%s` must be array, got %s", name, t)
+			}
+		}
+		return nil
+	}
+	switch name {
+	case "len":
+		if t := arg(0); t != Any && t != String {
+			if _, ok := t.(*Array); !ok {
+				c.report(nodes[0], "argument to `len` not supported, got %s", t)
+			}
+		}
+		return Int
+	case "first", "last":
+		if arr := wantArray(); arr != nil {
+			return arr.Elem
+		}
+	case "push":
+		if arr := wantArray(); arr != nil {
+			return &Array{Elem: join(arr.Elem, arg(1))}
+		}
+	case "pop":
+		if arr := wantArray(); arr != nil {
+			return arr
+		}
+	case "freeze":
+		return arg(0)
+	case "isFrozen":
+		return Bool
+	case "prints", "assert", "assertEq":
+		return Null
+	}
+	return Any
+}
@@ -0,0 +1,110 @@
+package typecheck
+
+import (
+	"my-interpreter/lexer"
+	"my-interpreter/parser"
+	"strings"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{`let x: int = 5; let y = x + 1;`, nil},
+		{`let x: int = "a";`, []string{`1:14: error: cannot use string as int in let x`}},
+		{`let x: int = 5; let x = "a";`, []string{`1:25: error: cannot use string as int in let x`}},
+		{`let x = 5; let x = "a"; x + "b"`, nil},
+		{`1 + "a"`, []string{`1:3: error: type mismatch: int + string`}},
+		{`"a" - "b"`, []string{`1:5: error: unknown operator: string - string`}},
+		{`-true`, []string{`1:1: error: unknown operator: -bool`}},
+		{`let f = fn(a, b) { a + b }; f(1, "a") == 1`, nil},
+		{`let f = fn(a: string, b: [int]) -> bool { len(b) > len(a) }; f(1, [2])`, []string{
+			`1:64: error: cannot use int as string in argument a to f`,
+		}},
+		{`let f = fn(a: int) -> string { a }`, []string{`1:32: error: cannot use int as string in return from f`}},
+		{`let f = fn(a: int) -> string { if (a > 1) { return "a" }; return a; }`, []string{
+			`1:66: error: cannot use int as string in return from f`,
+		}},
+		{`let f = fn(a: int) { a * 2 }; f(2) + "x"`, []string{`1:36: error: type mismatch: int + string`}},
+		{`fn g() { f(1) + 1 } fn f(x: int) -> string { "s" }`, []string{`1:15: error: type mismatch: string + int`}},
+		{`let f = fn(a: int, b: int = "x") { a + b };`, []string{`1:29: error: cannot use string as int in default of parameter b`}},
+		{`let f = fn(a: int, ...r: [int]) { a }; f(1, 2, "3")`, []string{`1:48: error: cannot use string as int in argument ... to f`}},
+		{`let f = fn(a: int) { a }; f(1, 2); f(); f(b: 1); f(a: "x")`, []string{
+			`1:28: error: too many arguments in call to f: got=2, want at most 1`,
+			`1:37: error: missing argument for parameter a in call to f`,
+			`1:42: error: missing argument for parameter a in call to f`,
+			`1:43: error: unknown parameter b in call to f`,
+			`1:55: error: cannot use string as int in argument a to f`,
+		}},
+		{`let f = fn(a: int) { a }; f(...[1])`, nil},
+		{`let x = 5; x(1)`, []string{`1:13: error: cannot call int`}},
+		{`let a = [1, 2]; a["x"]; a[0] + 1`, []string{`1:18: error: array index must be int, got string`}},
+		{`let m = {"a": 1}; m[1]; m["a"] + "b"`, []string{
+			`1:20: error: cannot use int as string in map index`,
+			`1:32: error: type mismatch: int + string`,
+		}},
+		{`let m: {string: int} = {"a": "b"};`, []string{`1:24: error: cannot use {string: string} as {string: int} in let m`}},
+		{`let a: [int] = []; let b: [int] = push(a, 1); first(b) + "x"`, []string{`1:56: error: type mismatch: int + string`}},
+		{`len(1)`, []string{`1:5: error: argument to ` + "`len`" + ` not supported, got int`}},
+		{`let len = fn(x) { x }; len(1)`, nil},
+		{`let x = 1; if (x > 0) { let x = "a" }; x + 1`, nil},
+		{`let x = 1; if (x > 0) { let x = 2 } else { let x = 3 }; x + "a"`, []string{`1:59: error: type mismatch: int + string`}},
+		{`let apply = fn(f: fn(int) -> int, x: int) -> int { f(x) }; apply(fn(s: string) -> int { 1 }, 2)`, []string{
+			`1:66: error: cannot use fn(string) -> int as fn(int) -> int in argument f to apply`,
+		}},
+		{`let apply = fn(f: fn(int) -> int, x: int) -> int { f(x) }; apply(fn(n) { n }, 2)`, nil},
+		{`struct P { x, y }; let p: P = P{x: 1, z: 2}; p.w; let q: int = p;`, []string{
+			`1:39: error: unknown field z in struct P`,
+			`1:48: error: unknown field w in struct P`,
+			`1:64: error: cannot use P as int in let q`,
+		}},
+		{`let x: Foo = 1; let f = fn(...r: int) { r };`, []string{
+			`1:8: error: unknown type Foo`,
+			`1:34: error: rest parameter r must have an array type, got int`,
+		}},
+		{`try { 1 + "a" } catch (e) { e.message + 1 }; match (1) { [a] => a + 1, _ => 0 }`, []string{
+			`1:9: error: type mismatch: int + string`,
+		}},
+		{`import "lib/math"; math.add(1, 2) + 1`, nil},
+	}
+	for _, tt := range tests {
+		p := parser.NewParser(lexer.NewLexer(tt.input))
+		program := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			t.Errorf("parser has errors for %q: %v", tt.input, p.Errors())
+			continue
+		}
+		var got []string
+		for _, d := range Check(program) {
+			got = append(got, d.String())
+		}
+		if strings.Join(got, "\n") != strings.Join(tt.expected, "\n") {
+			t.Errorf("%q:\nexpected %q\ngot=%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestAssignable(t *testing.T) {
+	tests := []struct {
+		dst, src Type
+		expected bool
+	}{
+		{Int, Any, true},
+		{Any, String, true},
+		{Int, String, false},
+		{&Array{Elem: Int}, &Array{Elem: Any}, true},
+		{&Array{Elem: Int}, &Array{Elem: String}, false},
+		{AnyFunc, &Func{Return: Int}, true},
+		{&Func{Params: []Type{Int}, Required: 1, Return: Any}, &Func{Params: []Type{Int, Int}, Required: 1, Return: Int}, true},
+		{&Func{Params: []Type{Int}, Required: 1, Return: Any}, &Func{Params: []Type{Int, Int}, Required: 2, Return: Int}, false},
+		{&Func{Params: []Type{Int, Int}, Required: 2, Return: Any}, &Func{Rest: Int, Return: Any}, true},
+		{&Map{Key: String, Value: Int}, Null, false},
+	}
+	for _, tt := range tests {
+		if got := Assignable(tt.dst, tt.src); got != tt.expected {
+			t.Errorf("Assignable(%s, %s) wrong. expected=%t, got=%t", tt.dst, tt.src, tt.expected, got)
+		}
+	}
+}
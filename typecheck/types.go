@@ -0,0 +1,179 @@
+package typecheck
+
+import (
+	"my-interpreter/object"
+	"strings"
+)
+
+// 静态类型,与求值时的object.ObjectType对应,Any表示要到求值时才知道
+type Type interface {
+	String() string
+}
+
+type basic string
+
+func (b basic) String() string {
+	return string(b)
+}
+
+var (
+	Int     Type = basic("int")
+	Bool    Type = basic("bool")
+	String  Type = basic("string")
+	Null    Type = basic("null")
+	Any     Type = basic("any")
+	AnyFunc Type = basic("fn") //参数和返回值未知的函数
+)
+
+// 元素类型相同的数组
+type Array struct {
+	Elem Type
+}
+
+func (a *Array) String() string {
+	return "[" + a.Elem.String() + "]"
+}
+
+type Map struct {
+	Key   Type
+	Value Type
+}
+
+func (m *Map) String() string {
+	return "{" + m.Key.String() + ": " + m.Value.String() + "}"
+}
+
+// 函数,前Required个参数是必需的,Rest为...rest参数的元素类型,没有rest参数时为nil
+// Names为参数名,来自函数类型注解时为空
+type Func struct {
+	Params   []Type
+	Names    []string
+	Required int
+	Rest     Type
+	Return   Type
+}
+
+func (f *Func) String() string {
+	var params []string
+	for _, p := range f.Params {
+		params = append(params, p.String())
+	}
+	if f.Rest != nil {
+		params = append(params, "..."+(&Array{Elem: f.Rest}).String())
+	}
+	return "fn(" + strings.Join(params, ", ") + ") -> " + f.Return.String()
+}
+
+// 由struct声明的结构体类型
+type Struct struct {
+	Name   string
+	Fields []string
+}
+
+func (s *Struct) String() string {
+	return s.Name
+}
+
+func (s *Struct) HasField(name string) bool {
+	for _, f := range s.Fields {
+		if f == name {
+			return true
+		}
+	}
+	return false
+}
+
+// 类型对应的求值时的对象类型,Any没有对应的类型
+func ObjectType(t Type) object.ObjectType {
+	switch t := t.(type) {
+	case *Array:
+		return object.ARRAY
+	case *Map:
+		return object.MAP
+	case *Func:
+		return object.FUNCTION
+	case *Struct:
+		return object.STRUCT
+	case basic:
+		switch t {
+		case Int:
+			return object.INTEGER
+		case Bool:
+			return object.BOOLEAN
+		case String:
+			return object.STRING
+		case Null:
+			return object.NULL
+		case AnyFunc:
+			return object.FUNCTION
+		}
+	}
+	return ""
+}
+
+// src类型的值能否用在需要dst类型的地方,任一方为Any时总是可以
+func Assignable(dst, src Type) bool {
+	if dst == Any || src == Any {
+		return true
+	}
+	switch d := dst.(type) {
+	case basic:
+		if d == AnyFunc {
+			_, ok := src.(*Func)
+			return ok || src == AnyFunc
+		}
+		return d == src
+	case *Array:
+		s, ok := src.(*Array)
+		return ok && Assignable(d.Elem, s.Elem)
+	case *Map:
+		s, ok := src.(*Map)
+		return ok && Assignable(d.Key, s.Key) && Assignable(d.Value, s.Value)
+	case *Func:
+		if src == AnyFunc {
+			return true
+		}
+		s, ok := src.(*Func)
+		if !ok || len(d.Params) < s.Required || len(d.Params) > len(s.Params) && s.Rest == nil {
+			return false
+		}
+		//参数逆变,返回值协变
+		for i, p := range d.Params {
+			sp := s.Rest
+			if i < len(s.Params) {
+				sp = s.Params[i]
+			}
+			if !Assignable(sp, p) {
+				return false
+			}
+		}
+		return Assignable(d.Return, s.Return)
+	case *Struct:
+		return d == src
+	}
+	return false
+}
+
+// 两个分支的值的类型,不同时为Any
+func join(a, b Type) Type {
+	switch {
+	case a == nil:
+		return b
+	case b == nil:
+		return a
+	case a.String() == b.String():
+		return a
+	}
+	//空数组字面量的元素类型是Any
+	if a, ok := a.(*Array); ok {
+		if b, ok := b.(*Array); ok {
+			if a.Elem == Any {
+				return b
+			}
+			if b.Elem == Any {
+				return a
+			}
+		}
+	}
+	return Any
+}